@@ -11,3 +11,11 @@ var NilPointerError = errors.New("NilPointerError: attempt to store a nil into Q
 var NoSuchElementError = errors.New("NoSuchElementError")
 var IllegalStateError = errors.New("IllegalStateError, could cause by container full")
 var IllegalArgumentError = errors.New("IllegalArgumentError ")
+var UnsupportedOperationError = errors.New("UnsupportedOperationError: the operation is not supported by this container")
+var TimeoutError = errors.New("TimeoutError: timed out while waiting")
+var AlertError = errors.New("AlertError: the sequence barrier has been alerted")
+var DuplicateElementError = errors.New("DuplicateElementError: the element is already in the container")
+var InvalidReceiptError = errors.New("InvalidReceiptError: the receipt handle is unknown, or has expired")
+var InvalidSnapshotError = errors.New("InvalidSnapshotError: bad magic number, version or checksum")
+var UnregisteredTypeError = errors.New("UnregisteredTypeError: the type or the tag is not in the type registry")
+var PeerDeadError = errors.New("PeerDeadError: a process attached to the shared queue has died")
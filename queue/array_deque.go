@@ -0,0 +1,387 @@
+package queue
+
+import (
+	"fmt"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+// the smallest capacity of an ArrayDeque, it must be a power of 2.
+const arrayDequeMinCapacity = 8
+
+/**
+ * An unbounded Deque backed by a growable circular array, for use by a single
+ * goroutine: there is no locking at all, ArrayDeque is <b>not</b> safe for
+ * concurrent use. it's faster than LinkedBlockingQueue and LinkedBlockingDeque
+ * as a plain queue or stack, for breadth-first searches for example.
+ *
+ * <p>Insertions and removals at both ends take amortized constant time: the
+ * array doubles when it's full, and halves when it's less than a quarter full.
+ * removals from the middle (Remove, RemoveIf ...) take linear time.
+ *
+ * <p>Nil elements are not permitted.
+ */
+type ArrayDeque struct {
+	// the length is always a power of 2, the empty slots are nil.
+	elements []interface{}
+	// the index of the first element.
+	head int
+	size int
+}
+
+/**
+ * @Description: create an ArrayDeque.
+ * @param numElements the number of elements the deque should hold without growing,
+ *        if it's less than 0, IllegalArgumentError will be panic
+ * @return *ArrayDeque
+ */
+func NewArrayDeque(numElements int) *ArrayDeque {
+	if numElements < 0 {
+		panic(IllegalArgumentError)
+	}
+	capacity := arrayDequeMinCapacity
+	for capacity < numElements {
+		capacity <<= 1
+	}
+	return &ArrayDeque{elements: make([]interface{}, capacity)}
+}
+
+// helper functions below.
+
+// the index in elements of the k-th element.
+func (d *ArrayDeque) index(k int) int {
+	return (d.head + k) & (len(d.elements) - 1)
+}
+
+// moves the elements into a new array of the given capacity, head first.
+func (d *ArrayDeque) resize(capacity int) {
+	a := make([]interface{}, capacity)
+	n := copy(a, d.elements[d.head:])
+	if n < d.size {
+		copy(a[n:], d.elements[:d.size-n])
+	}
+	d.elements = a
+	d.head = 0
+}
+
+func (d *ArrayDeque) growIfFull() {
+	if d.size == len(d.elements) {
+		d.resize(len(d.elements) << 1)
+	}
+}
+
+func (d *ArrayDeque) shrinkIfSparse() {
+	if len(d.elements) > arrayDequeMinCapacity && d.size < len(d.elements)>>2 {
+		d.resize(len(d.elements) >> 1)
+	}
+}
+
+// removes the k-th element, moving the elements between it and the nearest end.
+func (d *ArrayDeque) removeAt(k int) interface{} {
+	x := d.elements[d.index(k)]
+	if k < d.size/2 {
+		for j := k; j > 0; j-- {
+			d.elements[d.index(j)] = d.elements[d.index(j-1)]
+		}
+		d.elements[d.head] = nil
+		d.head = d.index(1)
+	} else {
+		for j := k; j < d.size-1; j++ {
+			d.elements[d.index(j)] = d.elements[d.index(j+1)]
+		}
+		d.elements[d.index(d.size-1)] = nil
+	}
+	d.size--
+	d.shrinkIfSparse()
+	return x
+}
+
+func (d *ArrayDeque) OfferFirst(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	d.growIfFull()
+	d.head = d.index(len(d.elements) - 1)
+	d.elements[d.head] = i
+	d.size++
+	return true
+}
+
+func (d *ArrayDeque) OfferLast(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	d.growIfFull()
+	d.elements[d.index(d.size)] = i
+	d.size++
+	return true
+}
+
+func (d *ArrayDeque) AddFirst(i interface{}) {
+	d.OfferFirst(i)
+}
+
+func (d *ArrayDeque) AddLast(i interface{}) {
+	d.OfferLast(i)
+}
+
+func (d *ArrayDeque) PollFirst() interface{} {
+	if d.size == 0 {
+		return nil
+	}
+	x := d.elements[d.head]
+	d.elements[d.head] = nil
+	d.head = d.index(1)
+	d.size--
+	d.shrinkIfSparse()
+	return x
+}
+
+func (d *ArrayDeque) PollLast() interface{} {
+	if d.size == 0 {
+		return nil
+	}
+	last := d.index(d.size - 1)
+	x := d.elements[last]
+	d.elements[last] = nil
+	d.size--
+	d.shrinkIfSparse()
+	return x
+}
+
+func (d *ArrayDeque) RemoveFirst() interface{} {
+	if x := d.PollFirst(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (d *ArrayDeque) RemoveLast() interface{} {
+	if x := d.PollLast(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (d *ArrayDeque) PeekFirst() interface{} {
+	return d.elements[d.head]
+}
+
+func (d *ArrayDeque) PeekLast() interface{} {
+	if d.size == 0 {
+		return nil
+	}
+	return d.elements[d.index(d.size-1)]
+}
+
+func (d *ArrayDeque) GetFirst() interface{} {
+	if x := d.PeekFirst(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (d *ArrayDeque) GetLast() interface{} {
+	if x := d.PeekLast(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (d *ArrayDeque) Push(i interface{}) {
+	d.AddFirst(i)
+}
+
+func (d *ArrayDeque) Pop() interface{} {
+	return d.RemoveFirst()
+}
+
+// Queue methods
+
+func (d *ArrayDeque) Offer(i interface{}) bool {
+	return d.OfferLast(i)
+}
+
+func (d *ArrayDeque) Add(i interface{}) bool {
+	return d.OfferLast(i)
+}
+
+func (d *ArrayDeque) Poll() interface{} {
+	return d.PollFirst()
+}
+
+func (d *ArrayDeque) RemoveHead() interface{} {
+	return d.RemoveFirst()
+}
+
+func (d *ArrayDeque) Peek() interface{} {
+	return d.PeekFirst()
+}
+
+func (d *ArrayDeque) Element() interface{} {
+	return d.GetFirst()
+}
+
+// Collection methods
+
+func (d *ArrayDeque) Len() int {
+	return d.size
+}
+
+func (d *ArrayDeque) IsEmpty() bool {
+	return d.size == 0
+}
+
+func (d *ArrayDeque) Contains(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	for k := 0; k < d.size; k++ {
+		if d.elements[d.index(k)] == i {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @Description: iterate through the deque from first (head) to last (tail).
+ *               f must not modify the deque.
+ * @receiver d
+ * @param f return false to stop the iteration
+ */
+func (d *ArrayDeque) Range(f func(value interface{}) bool) {
+	for k := 0; k < d.size; k++ {
+		if !f(d.elements[d.index(k)]) {
+			return
+		}
+	}
+}
+
+/**
+ * @Description: iterate through the deque from last (tail) to first (head).
+ *               f must not modify the deque.
+ * @receiver d
+ * @param f return false to stop the iteration
+ */
+func (d *ArrayDeque) DescendingRange(f func(value interface{}) bool) {
+	for k := d.size - 1; k >= 0; k-- {
+		if !f(d.elements[d.index(k)]) {
+			return
+		}
+	}
+}
+
+func (d *ArrayDeque) ToSlice() []interface{} {
+	ret := make([]interface{}, d.size)
+	for k := range ret {
+		ret[k] = d.elements[d.index(k)]
+	}
+	return ret
+}
+
+func (d *ArrayDeque) String() string {
+	return fmt.Sprintf("%v", d.ToSlice())
+}
+
+// removes the first occurrence of i.
+func (d *ArrayDeque) Remove(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	for k := 0; k < d.size; k++ {
+		if d.elements[d.index(k)] == i {
+			d.removeAt(k)
+			return true
+		}
+	}
+	return false
+}
+
+func (d *ArrayDeque) ContainsAll(c Collection) bool {
+	containsAll := true
+	c.Range(func(value interface{}) bool {
+		if !d.Contains(value) {
+			containsAll = false
+			return false
+		}
+		return true
+	})
+	return containsAll
+}
+
+/**
+ * @Description: adds all non-nil elements of c at the end of the deque.
+ *               nil element in c will be skipped and NilPointerError returned.
+ * @receiver d
+ * @param c if c is nil, NilPointerError is returned
+ * @return bool indicates whether the deque has been changed or not when the func return
+ */
+func (d *ArrayDeque) AddAll(c Collection) (modified bool, err error) {
+	if c == nil {
+		return false, NilPointerError
+	}
+	// c may be d itself.
+	for _, e := range c.ToSlice() {
+		if e == nil {
+			err = NilPointerError
+			continue
+		}
+		d.OfferLast(e)
+		modified = true
+	}
+	return
+}
+
+func (d *ArrayDeque) RemoveAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	elements := c.ToSlice()
+	return d.RemoveIf(func(value interface{}) bool {
+		return sliceContains(elements, value)
+	})
+}
+
+func (d *ArrayDeque) RemoveIf(filter func(value interface{}) bool) bool {
+	if filter == nil {
+		panic(NilPointerError)
+	}
+	// compacts the kept elements towards the head.
+	kept := 0
+	for k := 0; k < d.size; k++ {
+		x := d.elements[d.index(k)]
+		if !filter(x) {
+			d.elements[d.index(kept)] = x
+			kept++
+		}
+	}
+	if kept == d.size {
+		return false
+	}
+	for k := kept; k < d.size; k++ {
+		d.elements[d.index(k)] = nil
+	}
+	d.size = kept
+	for len(d.elements) > arrayDequeMinCapacity && d.size < len(d.elements)>>2 {
+		d.resize(len(d.elements) >> 1)
+	}
+	return true
+}
+
+func (d *ArrayDeque) RetainAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	elements := c.ToSlice()
+	return d.RemoveIf(func(value interface{}) bool {
+		return !sliceContains(elements, value)
+	})
+}
+
+// removes all of the elements, the deque goes back to the smallest capacity.
+func (d *ArrayDeque) Clear() {
+	d.elements = make([]interface{}, arrayDequeMinCapacity)
+	d.head = 0
+	d.size = 0
+}
@@ -0,0 +1,123 @@
+package queue
+
+import (
+	"math/rand"
+	"testing"
+)
+
+var _ Deque = (*ArrayDeque)(nil)
+
+// checks an ArrayDeque against a slice under random operations at both ends.
+func TestArrayDeque_Model(t *testing.T) {
+	d := NewArrayDeque(0)
+	var model []interface{}
+	r := rand.New(rand.NewSource(1))
+	for step := 0; step < 20000; step++ {
+		// grow for a while, then shrink.
+		growing := step%4000 < 2500
+		switch op := r.Intn(4); {
+		case op == 0 && growing:
+			d.AddFirst(step)
+			model = append([]interface{}{step}, model...)
+		case op == 1 && growing:
+			d.AddLast(step)
+			model = append(model, step)
+		case op == 2:
+			x := d.PollFirst()
+			if len(model) == 0 {
+				if x != nil {
+					t.Fatalf("step %d: PollFirst on empty deque returned %v", step, x)
+				}
+				continue
+			}
+			if x != model[0] {
+				t.Fatalf("step %d: PollFirst want %v, got %v", step, model[0], x)
+			}
+			model = model[1:]
+		case op == 3:
+			x := d.PollLast()
+			if len(model) == 0 {
+				if x != nil {
+					t.Fatalf("step %d: PollLast on empty deque returned %v", step, x)
+				}
+				continue
+			}
+			if x != model[len(model)-1] {
+				t.Fatalf("step %d: PollLast want %v, got %v", step, model[len(model)-1], x)
+			}
+			model = model[:len(model)-1]
+		}
+		if d.Len() != len(model) {
+			t.Fatalf("step %d: Len want %d, got %d", step, len(model), d.Len())
+		}
+		if c := len(d.elements); c > arrayDequeMinCapacity && d.Len() < c/4 {
+			t.Fatalf("step %d: %d elements in a capacity of %d", step, d.Len(), c)
+		}
+	}
+	got := d.ToSlice()
+	for k := range model {
+		if got[k] != model[k] {
+			t.Fatalf("ToSlice want %v, got %v", model, got)
+		}
+	}
+}
+
+func TestArrayDeque_RemoveFromTheMiddle(t *testing.T) {
+	d := NewArrayDeque(0)
+	for i := 0; i < 6; i++ {
+		d.Add(i)
+	}
+	// wrap the elements around the end of the array.
+	d.Poll()
+	d.Poll()
+	d.Push(1)
+	d.Push(0)
+	for i := 6; i < 8; i++ {
+		d.Add(i)
+	}
+	if !d.Remove(2) || !d.Remove(6) || d.Remove(42) {
+		t.Fatal("Remove")
+	}
+	if !d.RemoveIf(func(value interface{}) bool { return value.(int)%2 == 1 }) {
+		t.Fatal("RemoveIf")
+	}
+	want := []interface{}{0, 4}
+	var got []interface{}
+	d.DescendingRange(func(value interface{}) bool {
+		got = append([]interface{}{value}, got...)
+		return true
+	})
+	if d.String() != "[0 4]" || len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("want %v, got %v and %v", want, d, got)
+	}
+	if d.GetFirst() != 0 || d.GetLast() != 4 || d.Pop() != 0 || d.RemoveLast() != 4 || !d.IsEmpty() {
+		t.Fatal("ends")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("RemoveHead on empty deque should panic")
+		}
+	}()
+	d.RemoveHead()
+}
+
+func benchmarkBreadthFirst(b *testing.B, q Queue) {
+	for n := 0; n < b.N; n++ {
+		// visit a complete binary tree of 1<<12 nodes.
+		q.Offer(1)
+		for x := q.Poll(); x != nil; x = q.Poll() {
+			if node := x.(int); node < 1<<11 {
+				q.Offer(2 * node)
+				q.Offer(2*node + 1)
+			}
+		}
+	}
+}
+
+func BenchmarkArrayDeque(b *testing.B) {
+	benchmarkBreadthFirst(b, NewArrayDeque(0))
+}
+
+func BenchmarkLinkedBlockingQueue_BreadthFirst(b *testing.B) {
+	benchmarkBreadthFirst(b, NewLinkedBlockingQueue(0))
+}
@@ -0,0 +1,227 @@
+package queue
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * BatcherOptions tells a Batcher when to emit a batch, and where to. at least
+ * one of the limits must be set, and exactly one of Output and OnFlush.
+ */
+type BatcherOptions struct {
+	// emit once the batch holds MaxItems elements, 0 means no limit
+	MaxItems int
+	// emit once the batch holds MaxBytes bytes as measured by SizeOf, 0 means no limit.
+	// an element which would take the batch over MaxBytes goes to the next batch.
+	MaxBytes int
+	// the size of an element in bytes, required with MaxBytes
+	SizeOf func(i interface{}) int
+	// emit once MaxDelay has passed since the first element of the batch was added, 0 means no limit
+	MaxDelay time.Duration
+
+	// the batches are Put into Output, as []interface{}
+	Output BlockingQueue
+	// or passed to OnFlush, which owns them. OnFlush is called by one goroutine at a time,
+	// without any lock held, so it may Add elements to the Batcher.
+	OnFlush func(batch []interface{})
+}
+
+/**
+ * A Batcher groups the elements added to it into batches, by count, by size in
+ * bytes, or by time, and emits every batch to a BlockingQueue of slices or to a
+ * callback. the batches are emitted one at a time, in order, by the goroutine
+ * adding the element that fills the batch, or by a timer goroutine.
+ *
+ * <p>With OnFlush, a batch filled while another goroutine is running OnFlush is
+ * handed to that goroutine, which emits it next, so Add, Flush and Close may
+ * return before their batch is emitted, and OnFlush may call Add.
+ *
+ * <p>Call Close on shutdown, to emit what's left.
+ */
+type Batcher struct {
+	opts BatcherOptions
+
+	lock  *sync.Mutex
+	batch []interface{}
+	bytes int
+	// tells the batches apart, so that the timer of a batch doesn't emit the next one
+	generation uint64
+	timer      *time.Timer
+	closed     bool
+
+	// held while a batch is Put into Output, taken before lock is released so that the batches keep their order.
+	// it's only taken when there is a batch to Put.
+	emitLock *sync.Mutex
+	// the batches cut but not passed to OnFlush yet, and whether a goroutine is passing them
+	ready    [][]interface{}
+	emitting bool
+}
+
+/**
+ * @Description: create a Batcher.
+ * @param opts if they are not valid, IllegalArgumentError will be panic
+ * @return *Batcher
+ */
+func NewBatcher(opts BatcherOptions) *Batcher {
+	if opts.MaxItems < 0 || opts.MaxBytes < 0 || opts.MaxDelay < 0 ||
+		opts.MaxItems == 0 && opts.MaxBytes == 0 && opts.MaxDelay == 0 ||
+		opts.MaxBytes > 0 && opts.SizeOf == nil ||
+		(opts.Output == nil) == (opts.OnFlush == nil) {
+		panic(IllegalArgumentError)
+	}
+	return &Batcher{
+		opts:     opts,
+		lock:     new(sync.Mutex),
+		emitLock: new(sync.Mutex),
+	}
+}
+
+/**
+ * @Description: add an element to the current batch, and emit the batch if it's full.
+ *               if emitting fails, the batch is lost, but i is kept in the next batch.
+ * @return error NilPointerError, IllegalStateError if the batcher is closed,
+ *         or the error of the Output queue if a batch was emitted.
+ */
+func (b *Batcher) Add(i interface{}) error {
+	if i == nil {
+		return NilPointerError
+	}
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return IllegalStateError
+	}
+	var batches [][]interface{}
+	size := 0
+	if b.opts.MaxBytes > 0 {
+		size = b.opts.SizeOf(i)
+		if len(b.batch) > 0 && b.bytes+size > b.opts.MaxBytes {
+			batches = append(batches, b.cut())
+		}
+	}
+	b.batch = append(b.batch, i)
+	b.bytes += size
+	if len(b.batch) == 1 && b.opts.MaxDelay > 0 {
+		generation := b.generation
+		b.timer = time.AfterFunc(b.opts.MaxDelay, func() {
+			b.flushGeneration(generation)
+		})
+	}
+	if b.opts.MaxItems > 0 && len(b.batch) >= b.opts.MaxItems ||
+		b.opts.MaxBytes > 0 && b.bytes >= b.opts.MaxBytes {
+		batches = append(batches, b.cut())
+	}
+	return b.emitLocked(batches...)
+}
+
+// takes the current batch out, and starts a new one, with lock held.
+func (b *Batcher) cut() []interface{} {
+	batch := b.batch
+	b.batch = nil
+	b.bytes = 0
+	b.generation++
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+// emits the batches cut, it's called with lock held and releases it.
+func (b *Batcher) emitLocked(batches ...[]interface{}) (err error) {
+	if b.opts.OnFlush != nil {
+		b.handOver(batches)
+		return nil
+	}
+	nonEmpty := batches[:0]
+	for _, batch := range batches {
+		if len(batch) > 0 {
+			nonEmpty = append(nonEmpty, batch)
+		}
+	}
+	// nothing to wait for Output for, a Put blocked on a full Output must not hold the others up.
+	if len(nonEmpty) == 0 {
+		b.lock.Unlock()
+		return nil
+	}
+	b.emitLock.Lock()
+	defer b.emitLock.Unlock()
+	b.lock.Unlock()
+	for _, batch := range nonEmpty {
+		if e := b.opts.Output.Put(batch); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+/**
+ * @Description: queue the batches for OnFlush, and pass them, and the ones queued meanwhile,
+ *               unless another goroutine is already doing it. it's called with lock held and releases it.
+ */
+func (b *Batcher) handOver(batches [][]interface{}) {
+	for _, batch := range batches {
+		if len(batch) > 0 {
+			b.ready = append(b.ready, batch)
+		}
+	}
+	if b.emitting {
+		b.lock.Unlock()
+		return
+	}
+	b.emitting = true
+	done := false
+	defer func() {
+		// OnFlush panicked, let the next batch be emitted by someone else.
+		if !done {
+			b.lock.Lock()
+			b.emitting = false
+			b.lock.Unlock()
+		}
+	}()
+	for len(b.ready) > 0 {
+		batch := b.ready[0]
+		b.ready[0] = nil
+		b.ready = b.ready[1:]
+		b.lock.Unlock()
+		b.opts.OnFlush(batch)
+		b.lock.Lock()
+	}
+	b.ready = nil
+	b.emitting = false
+	done = true
+	b.lock.Unlock()
+}
+
+// emits the batch of the given generation, if it's still the current one.
+func (b *Batcher) flushGeneration(generation uint64) {
+	b.lock.Lock()
+	if b.generation != generation {
+		b.lock.Unlock()
+		return
+	}
+	b.emitLocked(b.cut())
+}
+
+// emits the current batch now, if it's not empty.
+func (b *Batcher) Flush() error {
+	b.lock.Lock()
+	return b.emitLocked(b.cut())
+}
+
+// emits the current batch, then rejects the elements added afterwards.
+func (b *Batcher) Close() error {
+	b.lock.Lock()
+	b.closed = true
+	return b.emitLocked(b.cut())
+}
+
+// the number of elements in the current batch.
+func (b *Batcher) Pending() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.batch)
+}
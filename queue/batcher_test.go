@@ -0,0 +1,160 @@
+package queue
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+func TestBatcher_MaxItemsAndFlush(t *testing.T) {
+	out := NewLinkedBlockingQueue(0)
+	b := NewBatcher(BatcherOptions{MaxItems: 3, Output: out})
+	for i := 0; i < 7; i++ {
+		b.Add(i)
+	}
+	if out.Len() != 2 || b.Pending() != 1 {
+		t.Fatalf("want 2 batches and 1 pending element, got %v", out)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for x := out.Poll(); x != nil; x = out.Poll() {
+		got = append(got, fmt.Sprint(x))
+	}
+	if fmt.Sprint(got) != "[[0 1 2] [3 4 5] [6]]" {
+		t.Fatalf("batches: %v", got)
+	}
+	if b.Add(7) != IllegalStateError {
+		t.Fatal("Add after Close should fail")
+	}
+}
+
+func TestBatcher_MaxBytes(t *testing.T) {
+	var batches []string
+	b := NewBatcher(BatcherOptions{
+		MaxBytes: 10,
+		SizeOf:   func(i interface{}) int { return len(i.(string)) },
+		OnFlush:  func(batch []interface{}) { batches = append(batches, fmt.Sprint(batch)) },
+	})
+	for _, s := range []string{"aaaa", "bbbb", "ccc", "dddddddddddd", "ee", "ffffffff"} {
+		b.Add(s)
+	}
+	b.Flush()
+	want := "[[aaaa bbbb] [ccc] [dddddddddddd] [ee ffffffff]]"
+	if fmt.Sprint(batches) != want {
+		t.Fatalf("want %s, got %v", want, batches)
+	}
+}
+
+func TestBatcher_MaxDelay(t *testing.T) {
+	var lock sync.Mutex
+	var batches [][]interface{}
+	b := NewBatcher(BatcherOptions{MaxItems: 100, MaxDelay: 20 * time.Millisecond, OnFlush: func(batch []interface{}) {
+		lock.Lock()
+		defer lock.Unlock()
+		batches = append(batches, batch)
+	}})
+	b.Add("a")
+	b.Add("b")
+	time.Sleep(60 * time.Millisecond)
+	b.Add("c")
+	b.Flush()
+	lock.Lock()
+	defer lock.Unlock()
+	if fmt.Sprint(batches) != "[[a b] [c]]" {
+		t.Fatalf("batches: %v", batches)
+	}
+}
+
+// a BlockingQueue whose Put fails.
+type failingPutQueue struct {
+	BlockingQueue
+}
+
+func (q failingPutQueue) Put(i interface{}) error {
+	return FullError
+}
+
+func TestBatcher_EmitErrorKeepsElement(t *testing.T) {
+	b := NewBatcher(BatcherOptions{
+		MaxBytes: 4,
+		SizeOf:   func(i interface{}) int { return len(i.(string)) },
+		Output:   failingPutQueue{NewLinkedBlockingQueue(0)},
+	})
+	b.Add("aa")
+	// "bbb" goes over MaxBytes, the batch [aa] fails to be emitted.
+	if err := b.Add("bbb"); err != FullError {
+		t.Fatalf("want FullError, got %v", err)
+	}
+	if b.Pending() != 1 {
+		t.Fatalf("the element added should be kept in the next batch, %d pending", b.Pending())
+	}
+}
+
+func TestBatcher_FullOutputDoesNotBlockAdd(t *testing.T) {
+	output := NewLinkedBlockingQueue(1)
+	b := NewBatcher(BatcherOptions{MaxItems: 2, Output: output})
+	b.Add("a")
+	b.Add("b")
+	b.Add("c")
+	// [c d] waits for Output, which holds [a b].
+	blocked := make(chan error, 1)
+	go func() { blocked <- b.Add("d") }()
+	time.Sleep(20 * time.Millisecond)
+
+	done := make(chan int, 1)
+	go func() {
+		b.Add("e")
+		done <- b.Pending()
+	}()
+	select {
+	case pending := <-done:
+		if pending != 1 {
+			t.Fatalf("want e pending, got %d elements", pending)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Add and Pending blocked behind a Put into the full Output")
+	}
+	if fmt.Sprint(output.Take()) != "[a b]" {
+		t.Fatal("want the first batch first")
+	}
+	if err := <-blocked; err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(output.Take()) != "[c d]" {
+		t.Fatal("want the second batch next")
+	}
+}
+
+func TestBatcher_AddFromOnFlush(t *testing.T) {
+	var b *Batcher
+	var batches []string
+	b = NewBatcher(BatcherOptions{MaxItems: 2, OnFlush: func(batch []interface{}) {
+		batches = append(batches, fmt.Sprint(batch))
+		if batch[0] == 0 {
+			// fills a batch, emitted once this one is done.
+			b.Add(10)
+			b.Add(11)
+		}
+	}})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Add(0)
+		b.Add(1)
+		b.Add(2)
+		b.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Add from OnFlush deadlocked")
+	}
+	if want := "[[0 1] [10 11] [2]]"; fmt.Sprint(batches) != want {
+		t.Fatalf("want %s, got %v", want, batches)
+	}
+}
@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+/**
+ * A Codec turns the elements of a queue into bytes and back, for the queues
+ * and formats that store elements outside of memory, see DiskQueue.
+ */
+type Codec interface {
+	Encode(i interface{}) ([]byte, error)
+	Decode(data []byte) (interface{}, error)
+}
+
+/**
+ * GobCodec encodes any element with encoding/gob, along with its type. the
+ * concrete types other than the basic ones must be registered with gob.Register
+ * by both the encoding and the decoding side.
+ */
+type GobCodec struct{}
+
+func (GobCodec) Encode(i interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	// encoding a pointer to the interface sends the concrete type along.
+	if err := gob.NewEncoder(&buf).Encode(&i); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Decode(data []byte) (interface{}, error) {
+	var i interface{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// BytesCodec stores []byte elements as they are.
+type BytesCodec struct{}
+
+func (BytesCodec) Encode(i interface{}) ([]byte, error) {
+	b, ok := i.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("BytesCodec: can't encode a %T", i)
+	}
+	return b, nil
+}
+
+func (BytesCodec) Decode(data []byte) (interface{}, error) {
+	return data, nil
+}
+
+// StringCodec stores string elements as they are.
+type StringCodec struct{}
+
+func (StringCodec) Encode(i interface{}) ([]byte, error) {
+	s, ok := i.(string)
+	if !ok {
+		return nil, fmt.Errorf("StringCodec: can't encode a %T", i)
+	}
+	return []byte(s), nil
+}
+
+func (StringCodec) Decode(data []byte) (interface{}, error) {
+	return string(data), nil
+}
@@ -0,0 +1,863 @@
+package queue
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * An unbounded concurrent deque based on linked nodes, a port of Java's
+ * ConcurrentLinkedDeque.
+ *
+ * <p>The nodes form a doubly linked list. an element is inserted with a
+ * compare-and-swap on the prev link of the first node or on the next link of
+ * the last node, so that operations at the head and operations at the tail
+ * don't contend with each other. head and tail are only hints, they may lag
+ * behind the real first and last nodes and are fixed lazily.
+ *
+ * <p>An element is logically deleted by swapping the item of its node to nil,
+ * the node is then unlinked: deleted nodes are squeezed out of the list, and
+ * a node which is off the list has its links pointed to itself, or to one of
+ * the terminator nodes, so that a traversal which runs into it knows to restart.
+ * the first and the last nodes are never unlinked, they may be deleted ones.
+ *
+ * <p>Iteration (Range, DescendingRange, ToSlice, Contains ...) is weakly
+ * consistent: it reflects the state of the deque at some point at or since its
+ * creation, and never fails because of concurrent modification.
+ *
+ * <p>Beware that, unlike in most collections, Len is NOT a constant-time
+ * operation, it traverses the whole deque.
+ */
+type ConcurrentLinkedDeque struct {
+	// *cldNode, a hint: the first node is reachable from it through the prev links.
+	head unsafe.Pointer
+	// *cldNode, a hint: the last node is reachable from it through the next links.
+	tail unsafe.Pointer
+}
+
+type cldNode struct {
+	// *cldNode
+	prev unsafe.Pointer
+	// *interface{}, nil when the element has been deleted.
+	item unsafe.Pointer
+	// *cldNode
+	next unsafe.Pointer
+}
+
+// the prev of a node unlinked at the head side, and the next of a node unlinked
+// at the tail side. they are linked to themselves so that nothing is ever linked to them.
+var cldPrevTerminator, cldNextTerminator = &cldNode{}, &cldNode{}
+
+func init() {
+	cldPrevTerminator.next = unsafe.Pointer(cldPrevTerminator)
+	cldNextTerminator.prev = unsafe.Pointer(cldNextTerminator)
+}
+
+// the number of deleted nodes at an end of the list tolerated before they are unlinked.
+const cldHops = 2
+
+func newCldNode(i interface{}) *cldNode {
+	return &cldNode{item: unsafe.Pointer(&i)}
+}
+
+func (n *cldNode) loadPrev() *cldNode {
+	return (*cldNode)(atomic.LoadPointer(&n.prev))
+}
+
+func (n *cldNode) loadNext() *cldNode {
+	return (*cldNode)(atomic.LoadPointer(&n.next))
+}
+
+func (n *cldNode) setPrev(p *cldNode) {
+	atomic.StorePointer(&n.prev, unsafe.Pointer(p))
+}
+
+func (n *cldNode) setNext(p *cldNode) {
+	atomic.StorePointer(&n.next, unsafe.Pointer(p))
+}
+
+func (n *cldNode) casPrev(old, new *cldNode) bool {
+	return atomic.CompareAndSwapPointer(&n.prev, unsafe.Pointer(old), unsafe.Pointer(new))
+}
+
+func (n *cldNode) casNext(old, new *cldNode) bool {
+	return atomic.CompareAndSwapPointer(&n.next, unsafe.Pointer(old), unsafe.Pointer(new))
+}
+
+// whether the node holds an element.
+func (n *cldNode) active() bool {
+	return atomic.LoadPointer(&n.item) != nil
+}
+
+func (n *cldNode) loadItem() interface{} {
+	if p := atomic.LoadPointer(&n.item); p != nil {
+		return *(*interface{})(p)
+	}
+	return nil
+}
+
+// takeItem deletes the element of the node, an item only ever goes from non-nil to nil.
+func (n *cldNode) takeItem() interface{} {
+	if p := atomic.SwapPointer(&n.item, nil); p != nil {
+		return *(*interface{})(p)
+	}
+	return nil
+}
+
+// removeItem deletes the element of the node if it is still i.
+func (n *cldNode) removeItem(i interface{}) bool {
+	p := atomic.LoadPointer(&n.item)
+	return p != nil && *(*interface{})(p) == i && atomic.CompareAndSwapPointer(&n.item, p, nil)
+}
+
+func (d *ConcurrentLinkedDeque) loadHead() *cldNode {
+	return (*cldNode)(atomic.LoadPointer(&d.head))
+}
+
+func (d *ConcurrentLinkedDeque) loadTail() *cldNode {
+	return (*cldNode)(atomic.LoadPointer(&d.tail))
+}
+
+func (d *ConcurrentLinkedDeque) casHead(old, new *cldNode) bool {
+	return atomic.CompareAndSwapPointer(&d.head, unsafe.Pointer(old), unsafe.Pointer(new))
+}
+
+func (d *ConcurrentLinkedDeque) casTail(old, new *cldNode) bool {
+	return atomic.CompareAndSwapPointer(&d.tail, unsafe.Pointer(old), unsafe.Pointer(new))
+}
+
+// links n as the first node.
+func (d *ConcurrentLinkedDeque) linkFirst(n *cldNode) {
+restart:
+	for {
+		h := d.loadHead()
+		for p := h; ; {
+			q := p.loadPrev()
+			if q != nil {
+				p = q
+				q = p.loadPrev()
+			}
+			if q != nil {
+				// hop two nodes at a time, checking whether head moved in between.
+				if nh := d.loadHead(); nh != h {
+					h, p = nh, nh
+				} else {
+					p = q
+				}
+			} else if p.loadNext() == p {
+				// p is the prev terminator, it has been unlinked.
+				continue restart
+			} else {
+				// p is the first node.
+				n.setNext(p)
+				if p.casPrev(nil, n) {
+					if p != h {
+						// it's fine to fail, someone else has moved it.
+						d.casHead(h, n)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// links the chain from first to last after the last node.
+func (d *ConcurrentLinkedDeque) linkLast(first, last *cldNode) {
+restart:
+	for {
+		t := d.loadTail()
+		for p := t; ; {
+			q := p.loadNext()
+			if q != nil {
+				p = q
+				q = p.loadNext()
+			}
+			if q != nil {
+				if nt := d.loadTail(); nt != t {
+					t, p = nt, nt
+				} else {
+					p = q
+				}
+			} else if p.loadPrev() == p {
+				// p is the next terminator, it has been unlinked.
+				continue restart
+			} else {
+				// p is the last node.
+				first.setPrev(p)
+				if p.casNext(nil, first) {
+					if p != t {
+						d.casTail(t, last)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// returns the first node, which is the first active node unless the deque is empty.
+func (d *ConcurrentLinkedDeque) first() *cldNode {
+restart:
+	for {
+		h := d.loadHead()
+		for p := h; ; {
+			q := p.loadPrev()
+			if q != nil {
+				p = q
+				q = p.loadPrev()
+			}
+			if q != nil {
+				if nh := d.loadHead(); nh != h {
+					h, p = nh, nh
+				} else {
+					p = q
+				}
+			} else if p == h || d.casHead(h, p) {
+				// if p is the prev terminator, the cas fails.
+				return p
+			} else {
+				continue restart
+			}
+		}
+	}
+}
+
+// returns the last node, which is the last active node unless the deque is empty.
+func (d *ConcurrentLinkedDeque) last() *cldNode {
+restart:
+	for {
+		t := d.loadTail()
+		for p := t; ; {
+			q := p.loadNext()
+			if q != nil {
+				p = q
+				q = p.loadNext()
+			}
+			if q != nil {
+				if nt := d.loadTail(); nt != t {
+					t, p = nt, nt
+				} else {
+					p = q
+				}
+			} else if p == t || d.casTail(t, p) {
+				return p
+			} else {
+				continue restart
+			}
+		}
+	}
+}
+
+// returns the successor of p, or the first node if p has been unlinked.
+func (d *ConcurrentLinkedDeque) succ(p *cldNode) *cldNode {
+	if q := p.loadNext(); q != p {
+		return q
+	}
+	return d.first()
+}
+
+// returns the predecessor of p, or the last node if p has been unlinked.
+func (d *ConcurrentLinkedDeque) pred(p *cldNode) *cldNode {
+	if q := p.loadPrev(); q != p {
+		return q
+	}
+	return d.last()
+}
+
+// unlinks x, whose element has been deleted.
+func (d *ConcurrentLinkedDeque) unlink(x *cldNode) {
+	prev, next := x.loadPrev(), x.loadNext()
+	if prev == nil {
+		d.unlinkFirst(x, next)
+		return
+	}
+	if next == nil {
+		d.unlinkLast(x, prev)
+		return
+	}
+
+	var activePred, activeSucc *cldNode
+	var isFirst, isLast bool
+	hops := 1
+	for p := prev; ; hops++ {
+		if p.active() {
+			activePred = p
+			break
+		}
+		q := p.loadPrev()
+		if q == nil {
+			if p.loadNext() == p {
+				return
+			}
+			activePred, isFirst = p, true
+			break
+		}
+		if p == q {
+			return
+		}
+		p = q
+	}
+	for p := next; ; hops++ {
+		if p.active() {
+			activeSucc = p
+			break
+		}
+		q := p.loadNext()
+		if q == nil {
+			if p.loadPrev() == p {
+				return
+			}
+			activeSucc, isLast = p, true
+			break
+		}
+		if p == q {
+			return
+		}
+		p = q
+	}
+
+	// interior deleted nodes are always squeezed out, a few at the ends are left for later.
+	if hops < cldHops && (isFirst || isLast) {
+		return
+	}
+
+	skipDeletedSuccessors(activePred)
+	skipDeletedPredecessors(activeSucc)
+
+	if !isFirst && !isLast {
+		return
+	}
+	// x may be taken off the list only if both its neighbours are still where they were.
+	predOk := activePred.active()
+	if isFirst {
+		predOk = activePred.loadPrev() == nil
+	}
+	succOk := activeSucc.active()
+	if isLast {
+		succOk = activeSucc.loadNext() == nil
+	}
+	if predOk && succOk && activePred.loadNext() == activeSucc && activeSucc.loadPrev() == activePred {
+		// neither head nor tail may point to x afterwards.
+		d.updateHead()
+		d.updateTail()
+		if isFirst {
+			x.setPrev(cldPrevTerminator)
+		} else {
+			x.setPrev(x)
+		}
+		if isLast {
+			x.setNext(cldNextTerminator)
+		} else {
+			x.setNext(x)
+		}
+	}
+}
+
+// unlinks the deleted nodes after first, the deleted first node.
+func (d *ConcurrentLinkedDeque) unlinkFirst(first, next *cldNode) {
+	var o *cldNode
+	for p := next; ; {
+		if !p.active() {
+			q := p.loadNext()
+			if q == p {
+				return
+			}
+			if q != nil {
+				o, p = p, q
+				continue
+			}
+		}
+		if o != nil && p.loadPrev() != p && first.casNext(next, p) {
+			skipDeletedPredecessors(p)
+			if first.loadPrev() == nil && (p.loadNext() == nil || p.active()) && p.loadPrev() == first {
+				d.updateHead()
+				d.updateTail()
+				o.setNext(o)
+				o.setPrev(cldPrevTerminator)
+			}
+		}
+		return
+	}
+}
+
+// unlinks the deleted nodes before last, the deleted last node.
+func (d *ConcurrentLinkedDeque) unlinkLast(last, prev *cldNode) {
+	var o *cldNode
+	for p := prev; ; {
+		if !p.active() {
+			q := p.loadPrev()
+			if q == p {
+				return
+			}
+			if q != nil {
+				o, p = p, q
+				continue
+			}
+		}
+		if o != nil && p.loadNext() != p && last.casPrev(prev, p) {
+			skipDeletedSuccessors(p)
+			if last.loadNext() == nil && (p.loadPrev() == nil || p.active()) && p.loadNext() == last {
+				d.updateHead()
+				d.updateTail()
+				o.setPrev(o)
+				o.setNext(cldNextTerminator)
+			}
+		}
+		return
+	}
+}
+
+// moves head to an active node, or to the first node.
+func (d *ConcurrentLinkedDeque) updateHead() {
+restart:
+	for {
+		h := d.loadHead()
+		p := h.loadPrev()
+		if h.active() || p == nil {
+			return
+		}
+		for {
+			q := p.loadPrev()
+			if q != nil {
+				p = q
+				q = p.loadPrev()
+			}
+			if q == nil {
+				// if p is the prev terminator, the cas fails.
+				if d.casHead(h, p) {
+					return
+				}
+				continue restart
+			}
+			if h != d.loadHead() {
+				continue restart
+			}
+			p = q
+		}
+	}
+}
+
+// moves tail to an active node, or to the last node.
+func (d *ConcurrentLinkedDeque) updateTail() {
+restart:
+	for {
+		t := d.loadTail()
+		p := t.loadNext()
+		if t.active() || p == nil {
+			return
+		}
+		for {
+			q := p.loadNext()
+			if q != nil {
+				p = q
+				q = p.loadNext()
+			}
+			if q == nil {
+				if d.casTail(t, p) {
+					return
+				}
+				continue restart
+			}
+			if t != d.loadTail() {
+				continue restart
+			}
+			p = q
+		}
+	}
+}
+
+// links x to its closest active predecessor, or to the first node.
+func skipDeletedPredecessors(x *cldNode) {
+retry:
+	for again := true; again; again = x.active() || x.loadNext() == nil {
+		prev := x.loadPrev()
+		p := prev
+		for !p.active() {
+			q := p.loadPrev()
+			if q == nil {
+				if p.loadNext() == p {
+					continue retry
+				}
+				break
+			}
+			if p == q {
+				continue retry
+			}
+			p = q
+		}
+		if prev == p || x.casPrev(prev, p) {
+			return
+		}
+	}
+}
+
+// links x to its closest active successor, or to the last node.
+func skipDeletedSuccessors(x *cldNode) {
+retry:
+	for again := true; again; again = x.active() || x.loadPrev() == nil {
+		next := x.loadNext()
+		p := next
+		for !p.active() {
+			q := p.loadNext()
+			if q == nil {
+				if p.loadPrev() == p {
+					continue retry
+				}
+				break
+			}
+			if p == q {
+				continue retry
+			}
+			p = q
+		}
+		if next == p || x.casNext(next, p) {
+			return
+		}
+	}
+}
+
+/**
+ * Inserts the specified element at the front of this deque.
+ * As the deque is unbounded, this method will never return {@code false}.
+ *
+ * @throws NilPointerError if the specified element is nil
+ */
+func (d *ConcurrentLinkedDeque) OfferFirst(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	d.linkFirst(newCldNode(i))
+	return true
+}
+
+/**
+ * Inserts the specified element at the end of this deque.
+ * As the deque is unbounded, this method will never return {@code false}.
+ *
+ * @throws NilPointerError if the specified element is nil
+ */
+func (d *ConcurrentLinkedDeque) OfferLast(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	n := newCldNode(i)
+	d.linkLast(n, n)
+	return true
+}
+
+func (d *ConcurrentLinkedDeque) PollFirst() interface{} {
+	for p := d.first(); p != nil; p = d.succ(p) {
+		if x := p.takeItem(); x != nil {
+			d.unlink(p)
+			return x
+		}
+	}
+	return nil
+}
+
+func (d *ConcurrentLinkedDeque) PollLast() interface{} {
+	for p := d.last(); p != nil; p = d.pred(p) {
+		if x := p.takeItem(); x != nil {
+			d.unlink(p)
+			return x
+		}
+	}
+	return nil
+}
+
+func (d *ConcurrentLinkedDeque) PeekFirst() interface{} {
+	for p := d.first(); p != nil; p = d.succ(p) {
+		if x := p.loadItem(); x != nil {
+			return x
+		}
+	}
+	return nil
+}
+
+func (d *ConcurrentLinkedDeque) PeekLast() interface{} {
+	for p := d.last(); p != nil; p = d.pred(p) {
+		if x := p.loadItem(); x != nil {
+			return x
+		}
+	}
+	return nil
+}
+
+func (d *ConcurrentLinkedDeque) AddFirst(i interface{}) {
+	d.OfferFirst(i)
+}
+
+func (d *ConcurrentLinkedDeque) AddLast(i interface{}) {
+	d.OfferLast(i)
+}
+
+func (d *ConcurrentLinkedDeque) RemoveFirst() interface{} {
+	if x := d.PollFirst(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (d *ConcurrentLinkedDeque) RemoveLast() interface{} {
+	if x := d.PollLast(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (d *ConcurrentLinkedDeque) GetFirst() interface{} {
+	if x := d.PeekFirst(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (d *ConcurrentLinkedDeque) GetLast() interface{} {
+	if x := d.PeekLast(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (d *ConcurrentLinkedDeque) Push(i interface{}) {
+	d.OfferFirst(i)
+}
+
+func (d *ConcurrentLinkedDeque) Pop() interface{} {
+	return d.RemoveFirst()
+}
+
+// *** Queue methods ***
+
+/**
+ * Inserts the specified element at the tail of this deque.
+ * As the deque is unbounded, this method will never panic IllegalStateError or return {@code false}.
+ *
+ * @throws NilPointerError if the specified element is nil
+ */
+func (d *ConcurrentLinkedDeque) Add(i interface{}) bool {
+	return d.OfferLast(i)
+}
+
+func (d *ConcurrentLinkedDeque) Offer(i interface{}) bool {
+	return d.OfferLast(i)
+}
+
+func (d *ConcurrentLinkedDeque) RemoveHead() interface{} {
+	return d.RemoveFirst()
+}
+
+func (d *ConcurrentLinkedDeque) Poll() interface{} {
+	return d.PollFirst()
+}
+
+func (d *ConcurrentLinkedDeque) Element() interface{} {
+	return d.GetFirst()
+}
+
+func (d *ConcurrentLinkedDeque) Peek() interface{} {
+	return d.PeekFirst()
+}
+
+// *** Collection methods ***
+
+/**
+ * Returns the number of elements in this deque.
+ *
+ * <p>Beware that, unlike in most collections, this method is
+ * <em>NOT</em> a constant-time operation. Additionally, if elements are
+ * added or removed during execution of this method, the returned result
+ * may be inaccurate.
+ */
+func (d *ConcurrentLinkedDeque) Len() int {
+	n := 0
+	d.Range(func(value interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func (d *ConcurrentLinkedDeque) IsEmpty() bool {
+	return d.PeekFirst() == nil
+}
+
+func (d *ConcurrentLinkedDeque) Contains(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	found := false
+	d.Range(func(value interface{}) bool {
+		found = value == i
+		return !found
+	})
+	return found
+}
+
+/**
+ * @Description: weakly consistent iteration from first (head) to last (tail).
+ * @receiver d
+ * @param f return false to stop the iteration
+ */
+func (d *ConcurrentLinkedDeque) Range(f func(value interface{}) bool) {
+	for p := d.first(); p != nil; p = d.succ(p) {
+		if x := p.loadItem(); x != nil {
+			if !f(x) {
+				return
+			}
+		}
+	}
+}
+
+/**
+ * @Description: weakly consistent iteration from last (tail) to first (head).
+ * @receiver d
+ * @param f return false to stop the iteration
+ */
+func (d *ConcurrentLinkedDeque) DescendingRange(f func(value interface{}) bool) {
+	for p := d.last(); p != nil; p = d.pred(p) {
+		if x := p.loadItem(); x != nil {
+			if !f(x) {
+				return
+			}
+		}
+	}
+}
+
+func (d *ConcurrentLinkedDeque) ToSlice() []interface{} {
+	ret := make([]interface{}, 0)
+	d.Range(func(value interface{}) bool {
+		ret = append(ret, value)
+		return true
+	})
+	return ret
+}
+
+func (d *ConcurrentLinkedDeque) String() string {
+	return fmt.Sprintf("%v", d.ToSlice())
+}
+
+/**
+ * Removes the first occurrence of the specified element from this deque,
+ * which may be anywhere in the deque, not only at either end.
+ *
+ * @return {@code true} if this deque changed as a result of the call
+ */
+func (d *ConcurrentLinkedDeque) Remove(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	for p := d.first(); p != nil; p = d.succ(p) {
+		if p.removeItem(i) {
+			d.unlink(p)
+			return true
+		}
+	}
+	return false
+}
+
+// lower performance
+func (d *ConcurrentLinkedDeque) ContainsAll(c Collection) bool {
+	containsAll := true
+	c.Range(func(value interface{}) bool {
+		if !d.Contains(value) {
+			containsAll = false
+			return false
+		}
+		return true
+	})
+	return containsAll
+}
+
+/**
+ * @Description: appends all non-nil elements of c to the tail of this deque, atomically.
+ *               nil element in c will be skipped and NilPointerError is returned.
+ * @receiver d
+ * @param c if c is nil, NilPointerError is returned
+ * @return bool indicates whether the deque has been changed or not when the func return
+ */
+func (d *ConcurrentLinkedDeque) AddAll(c Collection) (modified bool, err error) {
+	if c == nil {
+		return false, NilPointerError
+	}
+	var first, last *cldNode
+	for _, e := range c.ToSlice() {
+		if e == nil {
+			err = NilPointerError
+			continue
+		}
+		n := newCldNode(e)
+		if first == nil {
+			first = n
+		} else {
+			n.prev = unsafe.Pointer(last)
+			last.next = unsafe.Pointer(n)
+		}
+		last = n
+	}
+	if first == nil {
+		return false, err
+	}
+	// the chain is private until the single cas which links it.
+	d.linkLast(first, last)
+	if t := d.loadTail(); t != last && last.loadNext() == nil {
+		// try a little harder to move tail, past many elements at once.
+		d.casTail(t, last)
+	}
+	return true, err
+}
+
+func (d *ConcurrentLinkedDeque) RemoveAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	elements := c.ToSlice()
+	return d.RemoveIf(func(value interface{}) bool {
+		return sliceContains(elements, value)
+	})
+}
+
+/**
+ * Removes all of the elements of this deque that satisfy the given predicate.
+ * elements added concurrently may or may not be tested.
+ */
+func (d *ConcurrentLinkedDeque) RemoveIf(filter func(value interface{}) bool) bool {
+	if filter == nil {
+		panic(NilPointerError)
+	}
+	removed := false
+	for p := d.first(); p != nil; p = d.succ(p) {
+		if x := p.loadItem(); x != nil && filter(x) && p.removeItem(x) {
+			d.unlink(p)
+			removed = true
+		}
+	}
+	return removed
+}
+
+func (d *ConcurrentLinkedDeque) RetainAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	elements := c.ToSlice()
+	return d.RemoveIf(func(value interface{}) bool {
+		return !sliceContains(elements, value)
+	})
+}
+
+/**
+ * Removes all of the elements from this deque. elements added concurrently may or may not be removed.
+ */
+func (d *ConcurrentLinkedDeque) Clear() {
+	for d.PollFirst() != nil {
+	}
+}
+
+func NewConcurrentLinkedDeque() *ConcurrentLinkedDeque {
+	dummy := unsafe.Pointer(&cldNode{})
+	return &ConcurrentLinkedDeque{head: dummy, tail: dummy}
+}
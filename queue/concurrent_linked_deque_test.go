@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+var _ Deque = (*ConcurrentLinkedDeque)(nil)
+
+func TestConcurrentLinkedDeque_BothEnds(t *testing.T) {
+	d := NewConcurrentLinkedDeque()
+	for i := 3; i < 6; i++ {
+		d.OfferLast(i)
+	}
+	for i := 2; i >= 0; i-- {
+		d.OfferFirst(i)
+	}
+	if fmt.Sprint(d) != "[0 1 2 3 4 5]" {
+		t.Fatalf("unexpected content: %v", d)
+	}
+	// interior elements, their nodes have active neighbours on both sides.
+	if !d.Remove(2) || !d.Remove(4) || d.Remove(4) {
+		t.Fatal("Remove of interior elements failed")
+	}
+	var desc []interface{}
+	d.DescendingRange(func(value interface{}) bool {
+		desc = append(desc, value)
+		return true
+	})
+	if fmt.Sprint(desc) != "[5 3 1 0]" {
+		t.Fatalf("unexpected descending order: %v", desc)
+	}
+	if d.PeekFirst() != 0 || d.PeekLast() != 5 || d.Len() != 4 {
+		t.Fatalf("unexpected ends: %v", d)
+	}
+	// drain everything from one end, through the nodes inserted at the other one.
+	for _, want := range []int{5, 3, 1, 0} {
+		if x := d.PollLast(); x != want {
+			t.Fatalf("PollLast: want %d, got %v", want, x)
+		}
+	}
+	if d.PollFirst() != nil || d.PollLast() != nil || !d.IsEmpty() {
+		t.Fatal("deque should be empty")
+	}
+}
+
+// every goroutine inserts its own distinct elements at random ends, and randomly
+// polls either end or removes one of its earlier elements, the union of everything
+// taken out plus what is left in the end must be exactly what was inserted.
+func TestConcurrentLinkedDeque_Stress(t *testing.T) {
+	const goroutines, perGoroutine = 8, 1000
+	d := NewConcurrentLinkedDeque()
+	taken := make([][]interface{}, goroutines)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(g)))
+			for i := 0; i < perGoroutine; i++ {
+				e := g*perGoroutine + i
+				if r.Intn(2) == 0 {
+					d.OfferFirst(e)
+				} else {
+					d.OfferLast(e)
+				}
+				var x interface{}
+				switch r.Intn(4) {
+				case 0:
+					x = d.PollFirst()
+				case 1:
+					x = d.PollLast()
+				case 2:
+					if victim := g*perGoroutine + r.Intn(i+1); d.Remove(victim) {
+						x = victim
+					}
+				}
+				if x != nil {
+					taken[g] = append(taken[g], x)
+				}
+				d.Contains(e)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	seen := make(map[interface{}]bool)
+	all := append([][]interface{}{d.ToSlice()}, taken...)
+	for _, s := range all {
+		for _, x := range s {
+			if seen[x] {
+				t.Fatalf("element %v taken out twice", x)
+			}
+			seen[x] = true
+		}
+	}
+	if len(seen) != goroutines*perGoroutine {
+		t.Fatalf("want %d elements, got %d", goroutines*perGoroutine, len(seen))
+	}
+	if d.Len() != len(d.ToSlice()) {
+		t.Fatalf("Len %d doesn't match the content", d.Len())
+	}
+}
+
+// half of the goroutines work at the head, the other half at the tail.
+func benchmarkDeque(b *testing.B, newDeque func() Deque) {
+	for _, goroutines := range []int{2, 4, 16, 64} {
+		b.Run(fmt.Sprintf("goroutines-%d", goroutines), func(b *testing.B) {
+			d := newDeque()
+			var wg sync.WaitGroup
+			per := b.N/goroutines + 1
+			b.ResetTimer()
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func(head bool) {
+					defer wg.Done()
+					for i := 0; i < per; i++ {
+						if head {
+							d.OfferFirst(i + 1)
+							d.PollFirst()
+						} else {
+							d.OfferLast(i + 1)
+							d.PollLast()
+						}
+					}
+				}(g%2 == 0)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func BenchmarkConcurrentLinkedDeque(b *testing.B) {
+	benchmarkDeque(b, func() Deque { return NewConcurrentLinkedDeque() })
+}
+
+func BenchmarkLinkedBlockingDeque(b *testing.B) {
+	benchmarkDeque(b, func() Deque { return NewLinkedBlockingDeque(0) })
+}
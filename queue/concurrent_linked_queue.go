@@ -0,0 +1,339 @@
+package queue
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * An unbounded thread-safe queue based on linked nodes.
+ *
+ * <p>This implementation employs the non-blocking algorithm of Michael & Scott,
+ * "Simple, Fast, and Practical Non-Blocking and Blocking Concurrent Queue
+ * Algorithms". no operation ever takes a lock, so it is a good fit when many
+ * goroutines share a queue and nobody needs to wait for an element.
+ *
+ * <p>head always points to a dummy node, the first live element is head.next.
+ * an element is logically removed by swapping its item to nil, nodes of removed
+ * elements are unlinked lazily when the head passes them.
+ *
+ * <p>Iteration (Range, ToSlice, Contains ...) is weakly consistent: it reflects
+ * the state of the queue at some point at or since its creation, and never
+ * fails because of concurrent modification.
+ *
+ * <p>Beware that, unlike in most collections, Len is NOT a constant-time
+ * operation, it traverses the whole queue.
+ */
+type ConcurrentLinkedQueue struct {
+	// *clqNode
+	head unsafe.Pointer
+	// *clqNode, may lag behind the real last node by one.
+	tail unsafe.Pointer
+}
+
+type clqNode struct {
+	// *interface{}, nil when the node is the dummy or its element has been removed.
+	item unsafe.Pointer
+	// *clqNode
+	next unsafe.Pointer
+}
+
+func newClqNode(i interface{}) *clqNode {
+	return &clqNode{item: unsafe.Pointer(&i)}
+}
+
+func (n *clqNode) loadItem() interface{} {
+	if p := atomic.LoadPointer(&n.item); p != nil {
+		return *(*interface{})(p)
+	}
+	return nil
+}
+
+// removeItem logically removes the node if it still holds i.
+func (n *clqNode) removeItem(i interface{}) bool {
+	for {
+		p := atomic.LoadPointer(&n.item)
+		if p == nil || *(*interface{})(p) != i {
+			return false
+		}
+		if atomic.CompareAndSwapPointer(&n.item, p, nil) {
+			return true
+		}
+	}
+}
+
+func (n *clqNode) loadNext() *clqNode {
+	return (*clqNode)(atomic.LoadPointer(&n.next))
+}
+
+func (q *ConcurrentLinkedQueue) loadHead() *clqNode {
+	return (*clqNode)(atomic.LoadPointer(&q.head))
+}
+
+func (q *ConcurrentLinkedQueue) loadTail() *clqNode {
+	return (*clqNode)(atomic.LoadPointer(&q.tail))
+}
+
+/**
+ * Inserts the specified element at the tail of this queue.
+ * As the queue is unbounded, this method will never return {@code false}.
+ *
+ * @throws NilPointerError if the specified element is nil
+ */
+func (q *ConcurrentLinkedQueue) Offer(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	n := newClqNode(i)
+	for {
+		t := q.loadTail()
+		next := t.loadNext()
+		if t != q.loadTail() {
+			continue
+		}
+		if next != nil {
+			// tail is lagging behind, help to swing it.
+			atomic.CompareAndSwapPointer(&q.tail, unsafe.Pointer(t), unsafe.Pointer(next))
+			continue
+		}
+		if atomic.CompareAndSwapPointer(&t.next, nil, unsafe.Pointer(n)) {
+			// it's fine to fail, someone else has helped.
+			atomic.CompareAndSwapPointer(&q.tail, unsafe.Pointer(t), unsafe.Pointer(n))
+			return true
+		}
+	}
+}
+
+func (q *ConcurrentLinkedQueue) Poll() interface{} {
+	for {
+		h := q.loadHead()
+		t := q.loadTail()
+		first := h.loadNext()
+		if h != q.loadHead() {
+			continue
+		}
+		if first == nil {
+			return nil
+		}
+		if h == t {
+			atomic.CompareAndSwapPointer(&q.tail, unsafe.Pointer(t), unsafe.Pointer(first))
+			continue
+		}
+		if atomic.CompareAndSwapPointer(&q.head, unsafe.Pointer(h), unsafe.Pointer(first)) {
+			// first is the new dummy, only the winner of the head CAS may claim its item,
+			// however a concurrent Remove may have claimed it already.
+			if p := atomic.SwapPointer(&first.item, nil); p != nil {
+				return *(*interface{})(p)
+			}
+		}
+	}
+}
+
+func (q *ConcurrentLinkedQueue) Peek() interface{} {
+	for {
+		h := q.loadHead()
+		first := h.loadNext()
+		if first == nil {
+			return nil
+		}
+		if x := first.loadItem(); x != nil {
+			return x
+		}
+		// the element has been removed, skip its node.
+		if h == q.loadTail() {
+			atomic.CompareAndSwapPointer(&q.tail, unsafe.Pointer(h), unsafe.Pointer(first))
+		}
+		atomic.CompareAndSwapPointer(&q.head, unsafe.Pointer(h), unsafe.Pointer(first))
+	}
+}
+
+func (q *ConcurrentLinkedQueue) RemoveHead() interface{} {
+	if x := q.Poll(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (q *ConcurrentLinkedQueue) Element() interface{} {
+	if x := q.Peek(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+/**
+ * Inserts the specified element at the tail of this queue.
+ * As the queue is unbounded, this method will never panic IllegalStateError or return {@code false}.
+ *
+ * @throws NilPointerError if the specified element is nil
+ */
+func (q *ConcurrentLinkedQueue) Add(i interface{}) bool {
+	return q.Offer(i)
+}
+
+/**
+ * Returns the number of elements in this queue.
+ *
+ * <p>Beware that, unlike in most collections, this method is
+ * <em>NOT</em> a constant-time operation. Additionally, if elements are
+ * added or removed during execution of this method, the returned result
+ * may be inaccurate.
+ */
+func (q *ConcurrentLinkedQueue) Len() int {
+	n := 0
+	q.Range(func(value interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func (q *ConcurrentLinkedQueue) IsEmpty() bool {
+	return q.Peek() == nil
+}
+
+func (q *ConcurrentLinkedQueue) Contains(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	found := false
+	q.Range(func(value interface{}) bool {
+		found = value == i
+		return !found
+	})
+	return found
+}
+
+/**
+ * @Description: weakly consistent iteration from head to tail, elements removed
+ *               after the iteration has passed them are still seen.
+ * @receiver q
+ * @param f return false to stop the iteration
+ */
+func (q *ConcurrentLinkedQueue) Range(f func(value interface{}) bool) {
+	for p := q.loadHead().loadNext(); p != nil; p = p.loadNext() {
+		if x := p.loadItem(); x != nil {
+			if !f(x) {
+				return
+			}
+		}
+	}
+}
+
+func (q *ConcurrentLinkedQueue) ToSlice() []interface{} {
+	var ret []interface{}
+	q.Range(func(value interface{}) bool {
+		ret = append(ret, value)
+		return true
+	})
+	if ret == nil {
+		ret = make([]interface{}, 0)
+	}
+	return ret
+}
+
+func (q *ConcurrentLinkedQueue) String() string {
+	return fmt.Sprintf("%v", q.ToSlice())
+}
+
+/**
+ * Removes a single instance of the specified element from this queue,
+ * if it is present. the node of the removed element is unlinked lazily.
+ *
+ * @return {@code true} if this queue changed as a result of the call
+ */
+func (q *ConcurrentLinkedQueue) Remove(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	for p := q.loadHead().loadNext(); p != nil; p = p.loadNext() {
+		if p.removeItem(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// lower performance
+func (q *ConcurrentLinkedQueue) ContainsAll(c Collection) bool {
+	containsAll := true
+	c.Range(func(value interface{}) bool {
+		if !q.Contains(value) {
+			containsAll = false
+			return false
+		}
+		return true
+	})
+	return containsAll
+}
+
+/**
+ * @Description: appends all non-nil elements of c to the tail of this queue, nil element in c will be skipped
+ *               and NilPointerError is returned. the elements are not added atomically.
+ * @receiver q
+ * @param c if c is nil, NilPointerError is returned
+ * @return bool indicates whether the queue has been changed or not when the func return
+ */
+func (q *ConcurrentLinkedQueue) AddAll(c Collection) (modified bool, err error) {
+	if c == nil {
+		return false, NilPointerError
+	}
+	for _, e := range c.ToSlice() {
+		if e == nil {
+			err = NilPointerError
+			continue
+		}
+		q.Offer(e)
+		modified = true
+	}
+	return
+}
+
+func (q *ConcurrentLinkedQueue) RemoveAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	elements := c.ToSlice()
+	return q.RemoveIf(func(value interface{}) bool {
+		return sliceContains(elements, value)
+	})
+}
+
+func (q *ConcurrentLinkedQueue) RemoveIf(filter func(value interface{}) bool) bool {
+	if filter == nil {
+		panic(NilPointerError)
+	}
+	removed := false
+	for p := q.loadHead().loadNext(); p != nil; p = p.loadNext() {
+		if x := p.loadItem(); x != nil && filter(x) && p.removeItem(x) {
+			removed = true
+		}
+	}
+	return removed
+}
+
+func (q *ConcurrentLinkedQueue) RetainAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	elements := c.ToSlice()
+	return q.RemoveIf(func(value interface{}) bool {
+		return !sliceContains(elements, value)
+	})
+}
+
+/**
+ * Removes all of the elements from this queue. elements added concurrently may or may not be removed.
+ */
+func (q *ConcurrentLinkedQueue) Clear() {
+	for q.Poll() != nil {
+	}
+}
+
+func NewConcurrentLinkedQueue() *ConcurrentLinkedQueue {
+	dummy := unsafe.Pointer(&clqNode{})
+	return &ConcurrentLinkedQueue{head: dummy, tail: dummy}
+}
@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+var _ Queue = (*ConcurrentLinkedQueue)(nil)
+
+func TestConcurrentLinkedQueue_FIFO(t *testing.T) {
+	q := NewConcurrentLinkedQueue()
+	if q.Poll() != nil || q.Peek() != nil || !q.IsEmpty() {
+		t.Fatal("a new queue should be empty")
+	}
+	for i := 0; i < 5; i++ {
+		q.Offer(i)
+	}
+	if !q.Remove(2) || q.Remove(2) || q.Contains(2) {
+		t.Fatal("Remove of an interior element failed")
+	}
+	if q.Len() != 4 || fmt.Sprint(q) != "[0 1 3 4]" {
+		t.Fatalf("unexpected content: %v", q)
+	}
+	for _, want := range []int{0, 1, 3, 4} {
+		if x := q.Poll(); x != want {
+			t.Fatalf("Poll: want %d, got %v", want, x)
+		}
+	}
+	if q.Poll() != nil {
+		t.Fatal("Poll on an empty queue should return nil")
+	}
+}
+
+func TestConcurrentLinkedQueue_Concurrent(t *testing.T) {
+	const producers, consumers, perProducer = 4, 4, 2000
+	q := NewConcurrentLinkedQueue()
+	var seen sync.Map
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				q.Offer(p*perProducer + i)
+			}
+		}(p)
+	}
+	var consumed int64
+	for c := 0; c < consumers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for atomic.LoadInt64(&consumed) < producers*perProducer {
+				x := q.Poll()
+				if x == nil {
+					continue
+				}
+				if _, dup := seen.LoadOrStore(x, true); dup {
+					t.Errorf("element %v polled twice", x)
+				}
+				atomic.AddInt64(&consumed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if !q.IsEmpty() {
+		t.Fatalf("queue should be drained, got %v", q)
+	}
+}
+
+func benchmarkQueue(b *testing.B, newQueue func() Queue) {
+	for _, goroutines := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("goroutines-%d", goroutines), func(b *testing.B) {
+			q := newQueue()
+			var wg sync.WaitGroup
+			per := b.N/goroutines + 1
+			b.ResetTimer()
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < per; i++ {
+						q.Offer(i + 1)
+						q.Poll()
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func BenchmarkConcurrentLinkedQueue(b *testing.B) {
+	benchmarkQueue(b, func() Queue { return NewConcurrentLinkedQueue() })
+}
+
+func BenchmarkLinkedBlockingQueue(b *testing.B) {
+	benchmarkQueue(b, func() Queue { return NewLinkedBlockingQueue(0) })
+}
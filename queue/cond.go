@@ -0,0 +1,29 @@
+package queue
+
+import (
+	"sync"
+	"time"
+)
+
+/**
+ * @Description: a timed variant of sync.Cond.Wait. c.L must be held by the caller,
+ *               as with Wait it is released while waiting and re-acquired before returning.
+ *               callers should re-check their condition in a loop, just like Wait.
+ * @param c
+ * @param deadline
+ * @return bool false if the deadline had already passed, in that case the caller did not wait at all
+ */
+func waitUntil(c *sync.Cond, deadline time.Time) bool {
+	d := time.Until(deadline)
+	if d <= 0 {
+		return false
+	}
+	t := time.AfterFunc(d, func() {
+		c.L.Lock()
+		defer c.L.Unlock()
+		c.Broadcast()
+	})
+	c.Wait()
+	t.Stop()
+	return true
+}
@@ -0,0 +1,922 @@
+package queue
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+const (
+	// the segment files are named after the offset of their first record, zero padded, with this suffix.
+	diskQueueSegmentSuffix = ".seg"
+	// the file holding the consumer offset.
+	diskQueueOffsetFile = "consumer.offset"
+	// a record is [uint32 length][uint32 crc32 of the payload][payload], big endian.
+	diskQueueRecordHeaderSize = 8
+
+	DefaultDiskQueueSegmentSize  = 64 << 20
+	DefaultDiskQueueSyncInterval = time.Second
+)
+
+// SyncPolicy tells when a DiskQueue flushes its files to stable storage. unless it's SyncNever,
+// the directory is also flushed whenever a segment file is created or removed.
+type SyncPolicy int
+
+const (
+	// fsync after every insertion and every removal, nothing acknowledged is ever lost.
+	SyncAlways SyncPolicy = iota
+	// fsync every SyncInterval, a crash loses at most the last interval.
+	SyncInterval
+	// leave it to the operating system, only a crash of the process itself is survived.
+	SyncNever
+)
+
+// DiskQueueOptions are the options of a DiskQueue, the zero value means the defaults.
+type DiskQueueOptions struct {
+	// a segment file is closed once it's larger than SegmentSize bytes, then a new one is started
+	SegmentSize int64
+	Sync        SyncPolicy
+	// how often to fsync with SyncInterval
+	SyncInterval time.Duration
+}
+
+// a segment file, holding the records from base to base+count-1.
+type diskSegment struct {
+	base  int64
+	count int64
+	// the size of the file, in bytes
+	size int64
+}
+
+/**
+ * A DiskQueue is a persistent, optionally-bounded FIFO BlockingQueue: the
+ * elements are encoded by a Codec and appended to a write-ahead log on disk, so
+ * they survive restarts.
+ *
+ * <p>The log is split into segment files. every element is a record with a
+ * logical offset, its rank since the queue was created. the offset of the next
+ * element to take, the consumer offset, is saved in a file of its own after
+ * every removal. a segment is deleted once all its records have been taken.
+ *
+ * <p>When the queue is opened, the records are checked: the log is truncated
+ * before the first torn or corrupted record, as left by a crash in the middle of
+ * an insertion. the SyncPolicy tells what survives a crash of the machine. with
+ * SyncInterval and SyncNever, the consumer offset may be saved later than the
+ * removals, elements taken just before a crash may be taken again after it.
+ *
+ * <p>The first I/O or decoding error stops the queue: the insertions fail, the
+ * removals return nil, see Err. the elements of a log can't be removed from the
+ * middle, Remove, RemoveAll, RemoveIf and RetainAll panic UnsupportedOperationError.
+ */
+type DiskQueue struct {
+	dir      string
+	codec    Codec
+	capacity int
+	opts     DiskQueueOptions
+
+	// The number of items in the queue
+	length int64
+
+	// Main lock guarding all access
+	lock *sync.Mutex
+	// Condition for waiting takes
+	notEmpty *sync.Cond
+	// Condition for waiting puts
+	notFull *sync.Cond
+
+	// the live segments, the first one holds the consumer offset, the last one is appended to.
+	segments []*diskSegment
+	writer   *os.File
+	// the offset of the next record to append
+	writeOffset int64
+	// the first segment, and the position of the consumer offset in it
+	reader     *os.File
+	readPos    int64
+	readOffset int64
+	offsetFile *os.File
+	// whether there are writes not synced yet, with SyncInterval
+	dirty bool
+	// the checksum of the last record, a replica sends it to the primary to tell whether their logs agree
+	lastCRC uint32
+
+	// set while the queue is the copy of a primary, it's written by the replication only
+	follower bool
+	// closed and replaced on every change, when a primary streams the queue
+	changed chan struct{}
+
+	err      error
+	closed   bool
+	stopSync chan struct{}
+	syncDone chan struct{}
+}
+
+/**
+ * @Description: open the DiskQueue stored in dir, or create it.
+ * @param dir the directory of the queue, it's created if it doesn't exist
+ * @param capacity if capacity is 0, it'll be replace by math.MaxInt32,
+ *        if capacity is less than 0, IllegalArgumentError will be panic.
+ *        a queue may be reopened with a capacity smaller than its length.
+ * @param codec encodes the elements, if it's nil, NilPointerError will be panic
+ * @param opts nil means the defaults
+ * @return *DiskQueue
+ * @return error the error met while opening or recovering the files
+ */
+func OpenDiskQueue(dir string, capacity int, codec Codec, opts *DiskQueueOptions) (*DiskQueue, error) {
+	if capacity < 0 {
+		panic(IllegalArgumentError)
+	}
+	if codec == nil {
+		panic(NilPointerError)
+	}
+	if capacity == 0 {
+		capacity = math.MaxInt32
+	}
+	q := &DiskQueue{dir: dir, codec: codec, capacity: capacity, lock: new(sync.Mutex)}
+	if opts != nil {
+		q.opts = *opts
+	}
+	if q.opts.SegmentSize <= 0 {
+		q.opts.SegmentSize = DefaultDiskQueueSegmentSize
+	}
+	if q.opts.SyncInterval <= 0 {
+		q.opts.SyncInterval = DefaultDiskQueueSyncInterval
+	}
+	q.notEmpty = sync.NewCond(q.lock)
+	q.notFull = sync.NewCond(q.lock)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := q.recover(); err != nil {
+		q.closeFiles()
+		return nil, err
+	}
+	if q.opts.Sync == SyncInterval {
+		q.stopSync = make(chan struct{})
+		q.syncDone = make(chan struct{})
+		go q.syncLoop()
+	}
+	return q, nil
+}
+
+func (q *DiskQueue) segmentPath(base int64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", base, diskQueueSegmentSuffix))
+}
+
+// lists the bases of the segment files in dir, in order.
+func (q *DiskQueue) segmentBases() ([]int64, error) {
+	names, err := filepath.Glob(filepath.Join(q.dir, "*"+diskQueueSegmentSuffix))
+	if err != nil {
+		return nil, err
+	}
+	bases := make([]int64, 0, len(names))
+	for _, name := range names {
+		base, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(name), diskQueueSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		bases = append(bases, base)
+	}
+	sort.Slice(bases, func(i, j int) bool { return bases[i] < bases[j] })
+	return bases, nil
+}
+
+/**
+ * @Description: read the record at pos.
+ * @return []byte the payload
+ * @return int64 the size of the record
+ * @return error io.ErrUnexpectedEOF if the record is torn or corrupted, io.EOF if there is no record at pos
+ */
+func readDiskRecord(f *os.File, pos, fileSize int64) ([]byte, int64, error) {
+	if pos == fileSize {
+		return nil, 0, io.EOF
+	}
+	var header [diskQueueRecordHeaderSize]byte
+	if pos+diskQueueRecordHeaderSize > fileSize {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	if _, err := f.ReadAt(header[:], pos); err != nil {
+		return nil, 0, err
+	}
+	n := int64(binary.BigEndian.Uint32(header[0:4]))
+	if pos+diskQueueRecordHeaderSize+n > fileSize {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, n)
+	if _, err := f.ReadAt(payload, pos+diskQueueRecordHeaderSize); err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	return payload, diskQueueRecordHeaderSize + n, nil
+}
+
+/**
+ * @Description: count the valid records of a segment file, truncating it before the first bad one.
+ * @return bool whether the file was truncated
+ */
+func (q *DiskQueue) scanSegment(seg *diskSegment) (bool, error) {
+	f, err := os.OpenFile(q.segmentPath(seg.base), os.O_RDWR, 0644)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	for {
+		payload, n, err := readDiskRecord(f, seg.size, st.Size())
+		if err == io.EOF {
+			return false, nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return true, f.Truncate(seg.size)
+		}
+		if err != nil {
+			return false, err
+		}
+		seg.size += n
+		seg.count++
+		q.lastCRC = crc32.ChecksumIEEE(payload)
+	}
+}
+
+// rebuilds the state of the queue from the files in dir.
+func (q *DiskQueue) recover() error {
+	bases, err := q.segmentBases()
+	if err != nil {
+		return err
+	}
+	for k, base := range bases {
+		if k > 0 {
+			prev := q.segments[k-1]
+			if base != prev.base+prev.count {
+				// the log stops where it was truncated, what follows can't be trusted.
+				if err := q.removeSegments(bases[k:]); err != nil {
+					return err
+				}
+				break
+			}
+		}
+		seg := &diskSegment{base: base}
+		truncated, err := q.scanSegment(seg)
+		if err != nil {
+			return err
+		}
+		q.segments = append(q.segments, seg)
+		if truncated {
+			if err := q.removeSegments(bases[k+1:]); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	if len(q.segments) == 0 {
+		q.segments = []*diskSegment{{}}
+	}
+	last := q.segments[len(q.segments)-1]
+	q.writeOffset = last.base + last.count
+	if q.writer, err = os.OpenFile(q.segmentPath(last.base), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644); err != nil {
+		return err
+	}
+
+	if q.offsetFile, err = os.OpenFile(filepath.Join(q.dir, diskQueueOffsetFile), os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		return err
+	}
+	if err := q.syncDir(); err != nil {
+		return err
+	}
+	q.readOffset = q.segments[0].base
+	var buf [12]byte
+	if _, err := q.offsetFile.ReadAt(buf[:], 0); err == nil && crc32.ChecksumIEEE(buf[:8]) == binary.BigEndian.Uint32(buf[8:]) {
+		// a missing or torn offset file means starting over from the oldest record.
+		saved := int64(binary.BigEndian.Uint64(buf[:8]))
+		if saved > q.readOffset {
+			q.readOffset = saved
+		}
+		if q.readOffset > q.writeOffset {
+			q.readOffset = q.writeOffset
+		}
+	}
+	// the segments consumed before the last shutdown may not have been deleted yet, openReader does it.
+	if err := q.openReader(); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&q.length, q.writeOffset-q.readOffset)
+	return q.saveOffset()
+}
+
+func (q *DiskQueue) removeSegments(bases []int64) error {
+	for _, base := range bases {
+		if err := os.Remove(q.segmentPath(base)); err != nil {
+			return err
+		}
+	}
+	if len(bases) == 0 {
+		return nil
+	}
+	return q.syncDir()
+}
+
+// fsyncs the directory after segment files have been created or removed, so that a crash doesn't undo it.
+func (q *DiskQueue) syncDir() error {
+	if q.opts.Sync == SyncNever {
+		return nil
+	}
+	d, err := os.Open(q.dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// opens the reader on the segment holding the consumer offset, and finds the position of the offset in it.
+func (q *DiskQueue) openReader() error {
+	removed := false
+	for len(q.segments) > 1 && q.readOffset >= q.segments[0].base+q.segments[0].count {
+		if err := os.Remove(q.segmentPath(q.segments[0].base)); err != nil {
+			return err
+		}
+		q.segments = q.segments[1:]
+		removed = true
+	}
+	if removed {
+		if err := q.syncDir(); err != nil {
+			return err
+		}
+	}
+	seg := q.segments[0]
+	f, err := os.Open(q.segmentPath(seg.base))
+	if err != nil {
+		return err
+	}
+	if q.reader != nil {
+		q.reader.Close()
+	}
+	q.reader = f
+	q.readPos = 0
+	for offset := seg.base; offset < q.readOffset; offset++ {
+		_, n, err := readDiskRecord(f, q.readPos, seg.size)
+		if err != nil {
+			return err
+		}
+		q.readPos += n
+	}
+	return nil
+}
+
+// deletes the first segment once it has been consumed, unless it's the one appended to.
+func (q *DiskQueue) dropConsumedSegments() error {
+	if len(q.segments) > 1 && q.readOffset == q.segments[0].base+q.segments[0].count {
+		return q.openReader()
+	}
+	return nil
+}
+
+func (q *DiskQueue) saveOffset() error {
+	var buf [12]byte
+	binary.BigEndian.PutUint64(buf[:8], uint64(q.readOffset))
+	binary.BigEndian.PutUint32(buf[8:], crc32.ChecksumIEEE(buf[:8]))
+	if _, err := q.offsetFile.WriteAt(buf[:], 0); err != nil {
+		return err
+	}
+	if q.opts.Sync == SyncAlways {
+		return q.offsetFile.Sync()
+	}
+	q.dirty = true
+	return nil
+}
+
+// records the first error, with lock held, the queue is unusable afterwards.
+func (q *DiskQueue) fail(err error) error {
+	if q.err == nil {
+		q.err = err
+		q.notEmpty.Broadcast()
+		q.notFull.Broadcast()
+	}
+	return q.err
+}
+
+// wakes up the primary streaming the queue, if any, with lock held.
+func (q *DiskQueue) notifyChanged() {
+	if q.changed != nil {
+		close(q.changed)
+		q.changed = make(chan struct{})
+	}
+}
+
+// whether the queue can't be used anymore, with lock held.
+func (q *DiskQueue) stopped() error {
+	if q.err != nil {
+		return q.err
+	}
+	if q.closed {
+		return IllegalStateError
+	}
+	return nil
+}
+
+// appends an encoded element, with lock held and room in the queue.
+func (q *DiskQueue) enqueue(payload []byte) error {
+	record := make([]byte, diskQueueRecordHeaderSize+len(payload))
+	crc := crc32.ChecksumIEEE(payload)
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc)
+	copy(record[diskQueueRecordHeaderSize:], payload)
+	if _, err := q.writer.Write(record); err != nil {
+		return q.fail(err)
+	}
+	if q.opts.Sync == SyncAlways {
+		if err := q.writer.Sync(); err != nil {
+			return q.fail(err)
+		}
+	} else {
+		q.dirty = true
+	}
+	seg := q.segments[len(q.segments)-1]
+	seg.count++
+	seg.size += int64(len(record))
+	q.writeOffset++
+	q.lastCRC = crc
+	atomic.AddInt64(&q.length, 1)
+	q.notEmpty.Signal()
+	q.notifyChanged()
+	if seg.size >= q.opts.SegmentSize {
+		if err := q.roll(); err != nil {
+			return q.fail(err)
+		}
+	}
+	return nil
+}
+
+// closes the segment appended to, and starts a new one.
+func (q *DiskQueue) roll() error {
+	if q.opts.Sync != SyncNever {
+		if err := q.writer.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := q.writer.Close(); err != nil {
+		return err
+	}
+	seg := &diskSegment{base: q.writeOffset}
+	w, err := os.OpenFile(q.segmentPath(seg.base), os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	q.writer = w
+	q.segments = append(q.segments, seg)
+	return q.syncDir()
+}
+
+// reads the element at the consumer offset, with lock held and the queue not empty.
+func (q *DiskQueue) readHead() (interface{}, int64, error) {
+	payload, n, err := readDiskRecord(q.reader, q.readPos, q.segments[0].size)
+	if err != nil {
+		return nil, 0, err
+	}
+	x, err := q.codec.Decode(payload)
+	if err != nil {
+		return nil, 0, err
+	}
+	return x, n, nil
+}
+
+// takes the element at the consumer offset, with lock held and the queue not empty.
+func (q *DiskQueue) dequeue() interface{} {
+	x, n, err := q.readHead()
+	if err != nil {
+		q.fail(err)
+		return nil
+	}
+	q.readPos += n
+	q.readOffset++
+	atomic.AddInt64(&q.length, -1)
+	q.notFull.Signal()
+	q.notifyChanged()
+	if err := q.saveOffset(); err != nil {
+		q.fail(err)
+	} else if err := q.dropConsumedSegments(); err != nil {
+		q.fail(err)
+	}
+	return x
+}
+
+func (q *DiskQueue) syncLoop() {
+	defer close(q.syncDone)
+	ticker := time.NewTicker(q.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stopSync:
+			return
+		case <-ticker.C:
+			q.lock.Lock()
+			if err := q.sync(); err != nil {
+				q.fail(err)
+			}
+			q.lock.Unlock()
+		}
+	}
+}
+
+// flushes the files to stable storage if anything has been written since the last time, with lock held.
+func (q *DiskQueue) sync() error {
+	if !q.dirty || q.stopped() != nil {
+		return nil
+	}
+	if err := q.writer.Sync(); err != nil {
+		return err
+	}
+	if err := q.offsetFile.Sync(); err != nil {
+		return err
+	}
+	q.dirty = false
+	return nil
+}
+
+// inserts i, waiting until deadline for room, or forever if deadline is zero.
+func (q *DiskQueue) insert(i interface{}, wait bool, deadline time.Time) error {
+	payload, err := q.codec.Encode(i)
+	if err != nil {
+		return err
+	}
+	_, err = q.insertPayload(payload, wait, deadline)
+	return err
+}
+
+// inserts an element already encoded by the codec of the queue, returns the offset of its record.
+func (q *DiskQueue) insertPayload(payload []byte, wait bool, deadline time.Time) (int64, error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for {
+		if err := q.stopped(); err != nil {
+			return 0, err
+		}
+		if q.follower {
+			return 0, IllegalStateError
+		}
+		if q.Len() < q.capacity {
+			offset := q.writeOffset
+			return offset, q.enqueue(payload)
+		}
+		if !wait {
+			return 0, FullError
+		}
+		if deadline.IsZero() {
+			q.notFull.Wait()
+		} else if !waitUntil(q.notFull, deadline) {
+			return 0, TimeoutError
+		}
+	}
+}
+
+// takes the head, waiting until deadline for an element, or forever if deadline is zero.
+func (q *DiskQueue) remove(wait bool, deadline time.Time) interface{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for {
+		if q.stopped() != nil || q.follower {
+			return nil
+		}
+		if q.Len() > 0 {
+			return q.dequeue()
+		}
+		if !wait {
+			return nil
+		}
+		if deadline.IsZero() {
+			q.notEmpty.Wait()
+		} else if !waitUntil(q.notEmpty, deadline) {
+			return nil
+		}
+	}
+}
+
+// returns false if the queue is full, stopped, or i can't be encoded, see Err.
+func (q *DiskQueue) Offer(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	return q.insert(i, false, time.Time{}) == nil
+}
+
+func (q *DiskQueue) Add(i interface{}) bool {
+	if q.Offer(i) {
+		return true
+	}
+	panic(IllegalStateError)
+}
+
+/**
+ * Inserts the specified element at the tail of this queue, waiting if
+ * necessary for space to become available.
+ * returns the encoding error, the I/O error, or IllegalStateError if the queue is closed.
+ */
+func (q *DiskQueue) Put(i interface{}) error {
+	if i == nil {
+		return NilPointerError
+	}
+	return q.insert(i, true, time.Time{})
+}
+
+func (q *DiskQueue) OfferTimout(i interface{}, timeout time.Duration) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	return q.insert(i, true, time.Now().Add(timeout)) == nil
+}
+
+// returns nil once the queue is closed or stopped by an error.
+func (q *DiskQueue) Take() interface{} {
+	return q.remove(true, time.Time{})
+}
+
+func (q *DiskQueue) Poll() interface{} {
+	return q.remove(false, time.Time{})
+}
+
+func (q *DiskQueue) PollTimeout(timeout time.Duration) interface{} {
+	return q.remove(true, time.Now().Add(timeout))
+}
+
+func (q *DiskQueue) RemoveHead() interface{} {
+	if x := q.Poll(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (q *DiskQueue) Peek() interface{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.stopped() != nil || q.Len() == 0 {
+		return nil
+	}
+	x, _, err := q.readHead()
+	if err != nil {
+		q.fail(err)
+		return nil
+	}
+	return x
+}
+
+func (q *DiskQueue) Element() interface{} {
+	if x := q.Peek(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (q *DiskQueue) RemainingCapacity() int {
+	if n := q.capacity - q.Len(); n > 0 {
+		return n
+	}
+	return 0
+}
+
+func (q *DiskQueue) Len() int {
+	return int(atomic.LoadInt64(&q.length))
+}
+
+func (q *DiskQueue) IsEmpty() bool {
+	return q.Len() == 0
+}
+
+func (q *DiskQueue) Contains(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	found := false
+	q.Range(func(value interface{}) bool {
+		found = value == i
+		return !found
+	})
+	return found
+}
+
+/**
+ * @Description: iterate through the queue from head to tail, decoding every element from disk.
+ *               the queue is locked during the iteration, f must not call back into the queue.
+ * @receiver q
+ * @param f return false to stop the iteration
+ */
+func (q *DiskQueue) Range(f func(value interface{}) bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.stopped() != nil {
+		return
+	}
+	pos := q.readPos
+	for k, seg := range q.segments {
+		r := q.reader
+		if k > 0 {
+			var err error
+			if r, err = os.Open(q.segmentPath(seg.base)); err != nil {
+				q.fail(err)
+				return
+			}
+			pos = 0
+		}
+		more, err := q.rangeSegment(r, pos, seg.size, f)
+		if k > 0 {
+			r.Close()
+		}
+		if err != nil {
+			q.fail(err)
+			return
+		}
+		if !more {
+			return
+		}
+	}
+}
+
+// feeds f with the elements of a segment from pos, returns false if f stopped the iteration.
+func (q *DiskQueue) rangeSegment(r *os.File, pos, size int64, f func(value interface{}) bool) (bool, error) {
+	for pos < size {
+		payload, n, err := readDiskRecord(r, pos, size)
+		if err != nil {
+			return false, err
+		}
+		x, err := q.codec.Decode(payload)
+		if err != nil {
+			return false, err
+		}
+		if !f(x) {
+			return false, nil
+		}
+		pos += n
+	}
+	return true, nil
+}
+
+func (q *DiskQueue) ToSlice() []interface{} {
+	ret := make([]interface{}, 0, q.Len())
+	q.Range(func(value interface{}) bool {
+		ret = append(ret, value)
+		return true
+	})
+	return ret
+}
+
+func (q *DiskQueue) String() string {
+	return fmt.Sprintf("%v", q.ToSlice())
+}
+
+// lower performance
+func (q *DiskQueue) ContainsAll(c Collection) bool {
+	containsAll := true
+	c.Range(func(value interface{}) bool {
+		if !q.Contains(value) {
+			containsAll = false
+			return false
+		}
+		return true
+	})
+	return containsAll
+}
+
+/**
+ * @Description: offers all non-nil elements of c until the queue is full.
+ *               nil element in c will be skipped. it'll return FullError/ NilPointerError, or the error
+ *               of the queue, to indicate error.
+ * @receiver q
+ * @param c if c is nil, NilPointerError is returned
+ * @return bool indicates whether the queue has been changed or not when the func return
+ */
+func (q *DiskQueue) AddAll(c Collection) (modified bool, err error) {
+	if c == nil {
+		return false, NilPointerError
+	}
+	for _, e := range c.ToSlice() {
+		if e == nil {
+			err = NilPointerError
+			continue
+		}
+		if ierr := q.insert(e, false, time.Time{}); ierr != nil {
+			return modified, ierr
+		}
+		modified = true
+	}
+	return
+}
+
+func (q *DiskQueue) Remove(i interface{}) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (q *DiskQueue) RemoveAll(c Collection) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (q *DiskQueue) RemoveIf(filter func(value interface{}) bool) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (q *DiskQueue) RetainAll(c Collection) bool {
+	panic(UnsupportedOperationError)
+}
+
+/**
+ * Removes all of the elements from this queue, by moving the consumer offset to the tail.
+ */
+func (q *DiskQueue) Clear() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.stopped() != nil || q.follower {
+		return
+	}
+	q.readOffset = q.writeOffset
+	atomic.StoreInt64(&q.length, 0)
+	q.notifyChanged()
+	if err := q.saveOffset(); err != nil {
+		q.fail(err)
+	} else if err := q.openReader(); err != nil {
+		q.fail(err)
+	}
+	q.notFull.Broadcast()
+}
+
+// deletes all the segments once every record has been consumed, the log goes on from the same offset.
+func (q *DiskQueue) truncateIfEmpty() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.readOffset != q.writeOffset || len(q.segments) == 1 && q.segments[0].size == 0 {
+		return q.stopped()
+	}
+	return q.reset(q.writeOffset)
+}
+
+// the error which stopped the queue, if any.
+func (q *DiskQueue) Err() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.err
+}
+
+// the size of the segment files, in bytes. the consumed records take space until their segment is deleted.
+func (q *DiskQueue) Size() int64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	var size int64
+	for _, seg := range q.segments {
+		size += seg.size
+	}
+	return size
+}
+
+// the directory of the queue.
+func (q *DiskQueue) Dir() string {
+	return q.dir
+}
+
+func (q *DiskQueue) closeFiles() {
+	for _, f := range []*os.File{q.writer, q.reader, q.offsetFile} {
+		if f != nil {
+			f.Close()
+		}
+	}
+}
+
+/**
+ * @Description: flush and close the files, the waiting goroutines get IllegalStateError or nil.
+ * @return error the error of the final sync, if any
+ */
+func (q *DiskQueue) Close() error {
+	q.lock.Lock()
+	if q.closed {
+		q.lock.Unlock()
+		return nil
+	}
+	var err error
+	if q.opts.Sync != SyncNever && q.err == nil {
+		q.dirty = true
+		err = q.sync()
+	}
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	q.notifyChanged()
+	q.closeFiles()
+	q.lock.Unlock()
+	if q.stopSync != nil {
+		close(q.stopSync)
+		<-q.syncDone
+	}
+	return err
+}
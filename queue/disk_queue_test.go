@@ -0,0 +1,125 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var _ BlockingQueue = (*DiskQueue)(nil)
+
+func openTestDiskQueue(t *testing.T, dir string, capacity int, opts *DiskQueueOptions) *DiskQueue {
+	q, err := OpenDiskQueue(dir, capacity, GobCodec{}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestDiskQueue_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestDiskQueue(t, dir, 0, nil)
+	for i := 0; i < 10; i++ {
+		if err := q.Put(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		if x := q.Take(); x != i {
+			t.Fatalf("want %d, got %v", i, x)
+		}
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if q.Put(10) == nil || q.Poll() != nil {
+		t.Fatal("a closed queue should be unusable")
+	}
+
+	q = openTestDiskQueue(t, dir, 0, nil)
+	defer q.Close()
+	if q.Len() != 6 || q.Peek() != 4 || q.String() != "[4 5 6 7 8 9]" {
+		t.Fatalf("want [4 5 6 7 8 9], got %v", q)
+	}
+	q.Put("ten")
+	for i := 4; i < 10; i++ {
+		if x := q.Poll(); x != i {
+			t.Fatalf("want %d, got %v", i, x)
+		}
+	}
+	if x := q.PollTimeout(time.Second); x != "ten" || !q.IsEmpty() {
+		t.Fatalf("want ten, got %v", x)
+	}
+}
+
+func TestDiskQueue_TruncatesTornRecords(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestDiskQueue(t, dir, 0, &DiskQueueOptions{Sync: SyncNever})
+	q.Put("a")
+	q.Put("b")
+	q.Close()
+
+	// a crash in the middle of the third insertion.
+	segment := filepath.Join(dir, "00000000000000000000"+diskQueueSegmentSuffix)
+	f, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 42, 1, 2, 3, 4, 5})
+	f.Close()
+
+	q = openTestDiskQueue(t, dir, 0, &DiskQueueOptions{Sync: SyncNever})
+	defer q.Close()
+	q.Put("c")
+	if q.String() != "[a b c]" {
+		t.Fatalf("want [a b c], got %v", q)
+	}
+}
+
+func TestDiskQueue_DeletesConsumedSegments(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestDiskQueue(t, dir, 0, &DiskQueueOptions{SegmentSize: 64, Sync: SyncInterval, SyncInterval: time.Millisecond})
+	defer q.Close()
+	for i := 0; i < 20; i++ {
+		q.Put(i)
+	}
+	segments := func() int {
+		names, _ := filepath.Glob(filepath.Join(dir, "*"+diskQueueSegmentSuffix))
+		return len(names)
+	}
+	before := segments()
+	if before < 3 {
+		t.Fatalf("want several segments, got %d", before)
+	}
+	for i := 0; i < 15; i++ {
+		q.Take()
+	}
+	if after := segments(); after >= before {
+		t.Fatalf("the consumed segments should be deleted, %d before, %d after", before, after)
+	}
+	if !q.Contains(19) || q.Contains(3) || q.Len() != 5 {
+		t.Fatalf("queue: %v", q)
+	}
+	q.Clear()
+	if segments() != 1 || !q.IsEmpty() || q.Err() != nil {
+		t.Fatalf("Clear should leave a single segment, err %v", q.Err())
+	}
+}
+
+func TestDiskQueue_Capacity(t *testing.T) {
+	q := openTestDiskQueue(t, t.TempDir(), 2, nil)
+	defer q.Close()
+	q.Add("a")
+	q.Add("b")
+	if q.Offer("c") || q.OfferTimout("c", 10*time.Millisecond) {
+		t.Fatal("the queue should be full")
+	}
+	done := make(chan error)
+	go func() { done <- q.Put("c") }()
+	time.Sleep(10 * time.Millisecond)
+	q.Take()
+	if err := <-done; err != nil || q.String() != "[b c]" {
+		t.Fatalf("Put: %v, queue %v", err, q)
+	}
+}
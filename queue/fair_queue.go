@@ -0,0 +1,475 @@
+package queue
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * An optionally-bounded BlockingQueue shared fairly between classes of elements
+ * (tenants for example), so that a busy class can't starve the others.
+ *
+ * <p>Every element belongs to the class returned by the classifier, every class
+ * has its own FIFO sub-queue and a weight (1 by default). Elements are taken with
+ * deficit round robin: the non-empty classes are visited in turn, each visit
+ * gives a class a quantum of weight dequeues, so while all the classes are busy,
+ * each one gets a share of the takes proportional to its weight.
+ *
+ * <p>Elements are FIFO within a class only. Range and ToSlice walk the classes
+ * in their round robin order, one class after the other.
+ */
+type FairQueue struct {
+	// The number of items in the queue
+	count int64
+
+	// the capacity set, shared by all the classes
+	capacity int
+
+	// Main lock guarding all access
+	lock *sync.Mutex
+	// Condition for waiting takes
+	notEmpty *sync.Cond
+	// Condition for waiting puts
+	notFull *sync.Cond
+
+	classOf func(i interface{}) interface{}
+	// the weights set with SetWeight, the classes not in there weigh 1
+	weights map[interface{}]int
+	// the non-empty classes
+	classes map[interface{}]*fairClass
+	// the non-empty classes, in round robin order, Front is the class being served
+	active *list.List
+}
+
+type fairClass struct {
+	key    interface{}
+	weight int
+	// the number of elements the class may still dequeue in the current round
+	deficit int
+	// whether the class got its quantum for the current round
+	visited bool
+	items   *list.List
+	// the position of the class in active
+	elem *list.Element
+}
+
+/**
+ * @Description: create a FairQueue.
+ * @param capacity if capacity is 0, it'll be replace by math.MaxInt32,
+ *        if capacity is less than 0, IllegalArgumentError will be panic
+ * @param classOf returns the class of an element, classes must be usable as map keys.
+ *        if classOf is nil, NilPointerError will be panic
+ * @return *FairQueue
+ */
+func NewFairQueue(capacity int, classOf func(i interface{}) interface{}) *FairQueue {
+	if capacity < 0 {
+		panic(IllegalArgumentError)
+	}
+	if classOf == nil {
+		panic(NilPointerError)
+	}
+	if capacity == 0 {
+		capacity = math.MaxInt32
+	}
+	lock := new(sync.Mutex)
+	return &FairQueue{
+		capacity: capacity,
+		lock:     lock,
+		notEmpty: sync.NewCond(lock),
+		notFull:  sync.NewCond(lock),
+		classOf:  classOf,
+		weights:  make(map[interface{}]int),
+		classes:  make(map[interface{}]*fairClass),
+		active:   list.New(),
+	}
+}
+
+/**
+ * @Description: set the weight of a class, it takes effect from the next round of the class.
+ * @param class
+ * @param weight must be at least 1, or IllegalArgumentError will be panic
+ */
+func (q *FairQueue) SetWeight(class interface{}, weight int) {
+	if weight < 1 {
+		panic(IllegalArgumentError)
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.weights[class] = weight
+	if c, ok := q.classes[class]; ok {
+		c.weight = weight
+	}
+}
+
+func (q *FairQueue) Weight(class interface{}) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.weightOf(class)
+}
+
+func (q *FairQueue) weightOf(class interface{}) int {
+	if w, ok := q.weights[class]; ok {
+		return w
+	}
+	return 1
+}
+
+// returns the number of elements of a class.
+func (q *FairQueue) ClassLen(class interface{}) int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if c, ok := q.classes[class]; ok {
+		return c.items.Len()
+	}
+	return 0
+}
+
+// returns the number of elements of every non-empty class.
+func (q *FairQueue) ClassLens() map[interface{}]int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	lens := make(map[interface{}]int, len(q.classes))
+	for key, c := range q.classes {
+		lens[key] = c.items.Len()
+	}
+	return lens
+}
+
+// helper functions below, they should be called with lock held.
+
+// appends i to the sub-queue of its class, or returns false if full.
+func (q *FairQueue) enqueue(i interface{}) bool {
+	if q.Len() >= q.capacity {
+		return false
+	}
+	key := q.classOf(i)
+	c, ok := q.classes[key]
+	if !ok {
+		c = &fairClass{key: key, weight: q.weightOf(key), items: list.New()}
+		c.elem = q.active.PushBack(c)
+		q.classes[key] = c
+	}
+	c.items.PushBack(i)
+	atomic.AddInt64(&q.count, 1)
+	q.notEmpty.Signal()
+	return true
+}
+
+// takes the next element in deficit round robin order, or returns nil if empty.
+func (q *FairQueue) dequeue() interface{} {
+	for {
+		e := q.active.Front()
+		if e == nil {
+			return nil
+		}
+		c := e.Value.(*fairClass)
+		if !c.visited {
+			c.deficit += c.weight
+			c.visited = true
+		}
+		if c.deficit > 0 {
+			c.deficit--
+			return q.unlink(c, c.items.Front())
+		}
+		// the quantum is used up, next class.
+		c.visited = false
+		q.active.MoveToBack(e)
+	}
+}
+
+// removes e from the sub-queue of c, and c from the active classes if it becomes empty.
+func (q *FairQueue) unlink(c *fairClass, e *list.Element) interface{} {
+	x := c.items.Remove(e)
+	if c.items.Len() == 0 {
+		// an idle class loses its deficit, it can't save it up for later.
+		q.active.Remove(c.elem)
+		delete(q.classes, c.key)
+	}
+	atomic.AddInt64(&q.count, -1)
+	q.notFull.Signal()
+	return x
+}
+
+// returns what dequeue would return, without taking it.
+func (q *FairQueue) peek() interface{} {
+	e := q.active.Front()
+	if e == nil {
+		return nil
+	}
+	if c := e.Value.(*fairClass); c.visited && c.deficit <= 0 {
+		if e.Next() != nil {
+			e = e.Next()
+		}
+	}
+	return e.Value.(*fairClass).items.Front().Value
+}
+
+func (q *FairQueue) Offer(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.enqueue(i)
+}
+
+func (q *FairQueue) Add(i interface{}) bool {
+	if q.Offer(i) {
+		return true
+	}
+	panic(IllegalStateError)
+}
+
+func (q *FairQueue) Put(i interface{}) error {
+	if i == nil {
+		return NilPointerError
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for !q.enqueue(i) {
+		q.notFull.Wait()
+	}
+	return nil
+}
+
+func (q *FairQueue) OfferTimout(i interface{}, timeout time.Duration) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	deadline := time.Now().Add(timeout)
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for !q.enqueue(i) {
+		if !waitUntil(q.notFull, deadline) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *FairQueue) Poll() interface{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.dequeue()
+}
+
+func (q *FairQueue) Take() interface{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for {
+		if x := q.dequeue(); x != nil {
+			return x
+		}
+		q.notEmpty.Wait()
+	}
+}
+
+func (q *FairQueue) PollTimeout(timeout time.Duration) interface{} {
+	deadline := time.Now().Add(timeout)
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for {
+		if x := q.dequeue(); x != nil {
+			return x
+		}
+		if !waitUntil(q.notEmpty, deadline) {
+			return nil
+		}
+	}
+}
+
+func (q *FairQueue) RemoveHead() interface{} {
+	if x := q.Poll(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+// returns the element the next Take would return.
+func (q *FairQueue) Peek() interface{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.peek()
+}
+
+func (q *FairQueue) Element() interface{} {
+	if x := q.Peek(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (q *FairQueue) RemainingCapacity() int {
+	return q.capacity - q.Len()
+}
+
+func (q *FairQueue) Len() int {
+	return int(atomic.LoadInt64(&q.count))
+}
+
+func (q *FairQueue) IsEmpty() bool {
+	return q.Len() == 0
+}
+
+func (q *FairQueue) Contains(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	found := false
+	q.Range(func(value interface{}) bool {
+		found = value == i
+		return !found
+	})
+	return found
+}
+
+/**
+ * @Description: iterate through the classes in round robin order, and through every class in FIFO order.
+ *               the queue is locked during the iteration, f must not call back into the queue.
+ * @receiver q
+ * @param f return false to stop the iteration
+ */
+func (q *FairQueue) Range(f func(value interface{}) bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for ce := q.active.Front(); ce != nil; ce = ce.Next() {
+		for e := ce.Value.(*fairClass).items.Front(); e != nil; e = e.Next() {
+			if !f(e.Value) {
+				return
+			}
+		}
+	}
+}
+
+func (q *FairQueue) ToSlice() []interface{} {
+	ret := make([]interface{}, 0, q.Len())
+	q.Range(func(value interface{}) bool {
+		ret = append(ret, value)
+		return true
+	})
+	return ret
+}
+
+func (q *FairQueue) String() string {
+	return fmt.Sprintf("%v", q.ToSlice())
+}
+
+func (q *FairQueue) Remove(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	c, ok := q.classes[q.classOf(i)]
+	if !ok {
+		return false
+	}
+	for e := c.items.Front(); e != nil; e = e.Next() {
+		if e.Value == i {
+			q.unlink(c, e)
+			return true
+		}
+	}
+	return false
+}
+
+// lower performance
+func (q *FairQueue) ContainsAll(c Collection) bool {
+	containsAll := true
+	c.Range(func(value interface{}) bool {
+		if !q.Contains(value) {
+			containsAll = false
+			return false
+		}
+		return true
+	})
+	return containsAll
+}
+
+/**
+ * @Description: adds all non-nil elements of c until the queue is full.
+ *               nil element in c will be skipped. it'll return FullError/ NilPointerError to indicate error.
+ * @receiver q
+ * @param c if c is nil, NilPointerError is returned
+ * @return bool indicates whether the queue has been changed or not when the func return
+ */
+func (q *FairQueue) AddAll(c Collection) (modified bool, err error) {
+	if c == nil {
+		return false, NilPointerError
+	}
+	elements := c.ToSlice()
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, e := range elements {
+		if e == nil {
+			err = NilPointerError
+			continue
+		}
+		if !q.enqueue(e) {
+			return modified, FullError
+		}
+		modified = true
+	}
+	return
+}
+
+func (q *FairQueue) RemoveAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	elements := c.ToSlice()
+	return q.RemoveIf(func(value interface{}) bool {
+		return sliceContains(elements, value)
+	})
+}
+
+func (q *FairQueue) RemoveIf(filter func(value interface{}) bool) bool {
+	if filter == nil {
+		panic(NilPointerError)
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	removed := false
+	for ce := q.active.Front(); ce != nil; {
+		nextClass := ce.Next()
+		c := ce.Value.(*fairClass)
+		for e := c.items.Front(); e != nil; {
+			next := e.Next()
+			if filter(e.Value) {
+				q.unlink(c, e)
+				removed = true
+			}
+			e = next
+		}
+		ce = nextClass
+	}
+	return removed
+}
+
+func (q *FairQueue) RetainAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	elements := c.ToSlice()
+	return q.RemoveIf(func(value interface{}) bool {
+		return !sliceContains(elements, value)
+	})
+}
+
+/**
+ * Atomically removes all of the elements from this queue, the weights are kept.
+ */
+func (q *FairQueue) Clear() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.classes = make(map[interface{}]*fairClass)
+	q.active.Init()
+	atomic.StoreInt64(&q.count, 0)
+	q.notFull.Broadcast()
+}
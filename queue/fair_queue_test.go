@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+var _ BlockingQueue = (*FairQueue)(nil)
+
+type tenantJob struct {
+	tenant string
+	seq    int
+}
+
+func newTenantQueue(capacity int) *FairQueue {
+	return NewFairQueue(capacity, func(i interface{}) interface{} {
+		return i.(tenantJob).tenant
+	})
+}
+
+func TestFairQueue_NoisyTenantDoesNotStarve(t *testing.T) {
+	q := newTenantQueue(0)
+	for seq := 0; seq < 100; seq++ {
+		q.Put(tenantJob{"noisy", seq})
+	}
+	q.Put(tenantJob{"quiet", 0})
+	q.Put(tenantJob{"quiet", 1})
+
+	if got := q.ClassLens(); got["noisy"] != 100 || got["quiet"] != 2 || len(got) != 2 {
+		t.Fatalf("ClassLens: %v", got)
+	}
+	want := []tenantJob{{"noisy", 0}, {"quiet", 0}, {"noisy", 1}, {"quiet", 1}, {"noisy", 2}, {"noisy", 3}}
+	for _, w := range want {
+		if x := q.Take(); x != w {
+			t.Fatalf("want %v, got %v", w, x)
+		}
+	}
+	if q.ClassLen("quiet") != 0 || q.ClassLen("noisy") != 96 {
+		t.Fatalf("ClassLens: %v", q.ClassLens())
+	}
+}
+
+func TestFairQueue_Weights(t *testing.T) {
+	q := newTenantQueue(0)
+	q.SetWeight("a", 3)
+	for seq := 0; seq < 40; seq++ {
+		q.Put(tenantJob{"a", seq})
+		q.Put(tenantJob{"b", seq})
+	}
+	taken := map[string]int{}
+	last := map[string]int{"a": -1, "b": -1}
+	for k := 0; k < 40; k++ {
+		if p := q.Peek(); p != q.Element() {
+			t.Fatal("Peek and Element disagree")
+		}
+		peeked := q.Peek()
+		x := q.Poll().(tenantJob)
+		if x != peeked {
+			t.Fatalf("peeked %v, took %v", peeked, x)
+		}
+		if x.seq != last[x.tenant]+1 {
+			t.Fatalf("%s is not FIFO: %d after %d", x.tenant, x.seq, last[x.tenant])
+		}
+		last[x.tenant] = x.seq
+		taken[x.tenant]++
+	}
+	if taken["a"] != 30 || taken["b"] != 10 {
+		t.Fatalf("want a 3:1 share, got %v", taken)
+	}
+}
+
+func TestFairQueue_Capacity(t *testing.T) {
+	q := newTenantQueue(2)
+	q.Add(tenantJob{"a", 0})
+	q.Add(tenantJob{"b", 0})
+	if q.Offer(tenantJob{"c", 0}) || q.RemainingCapacity() != 0 {
+		t.Fatal("the capacity is shared by all the classes")
+	}
+	if q.OfferTimout(tenantJob{"c", 0}, 10*time.Millisecond) {
+		t.Fatal("OfferTimout should time out")
+	}
+	done := make(chan struct{})
+	go func() {
+		q.Put(tenantJob{"c", 0})
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if !q.Remove(tenantJob{"a", 0}) || q.Remove(tenantJob{"a", 0}) {
+		t.Fatal("Remove")
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Put did not wake up")
+	}
+	if q.Len() != 2 || !q.Contains(tenantJob{"c", 0}) {
+		t.Fatalf("queue: %v", q)
+	}
+	q.Clear()
+	if !q.IsEmpty() || len(q.ClassLens()) != 0 || q.PollTimeout(10*time.Millisecond) != nil {
+		t.Fatal("Clear")
+	}
+}
@@ -0,0 +1,310 @@
+/**
+ * Package httpqueue exposes named BlockingQueues over HTTP, the elements being
+ * JSON values.
+ */
+package httpqueue
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+	"github.com/torchcc/data-structure/queue"
+)
+
+const (
+	// how long a POST waits for room by default
+	DefaultPutTimeout = time.Second
+	// the longest wait a GET may ask for by default
+	DefaultMaxWait = 30 * time.Second
+
+	// the waits are split into slices this long, to notice the clients going away
+	pollSlice = 100 * time.Millisecond
+	// the interval of the comments keeping an idle event stream alive
+	keepAliveInterval = 15 * time.Second
+)
+
+/**
+ * A Handler serves the queues registered with it:
+ *
+ * <pre>
+ * POST /queues/{name}[?timeout=1s]  enqueue the JSON body, 201, or 429 if the queue is still full after the timeout
+ * GET  /queues/{name}[?wait=5s]     dequeue the head, 200 with the JSON element, or 204 if the queue is still empty
+ * GET  /queues/{name}/stream        dequeue the elements as server-sent events, until the client goes away
+ * GET  /stats                       the length, the remaining capacity and the counters of every queue
+ * </pre>
+ *
+ * <p>The elements are the values decoded by encoding/json, map[string]interface{},
+ * []interface{}, string, float64 or bool, so that Go code sharing the queues sees
+ * the same types. an element dequeued for a client which goes away in the same
+ * instant is lost.
+ */
+type Handler struct {
+	// how long a POST waits for room when it has no timeout parameter
+	PutTimeout time.Duration
+	// the longest wait and timeout the clients may ask for
+	MaxWait time.Duration
+
+	lock   *sync.RWMutex
+	queues map[string]*namedQueue
+}
+
+type namedQueue struct {
+	q queue.BlockingQueue
+	// counters, since the queue was registered
+	enqueued int64
+	dequeued int64
+	rejected int64
+}
+
+// the statistics of a queue, as served by /stats.
+type Stats struct {
+	Len               int   `json:"len"`
+	RemainingCapacity int   `json:"remaining_capacity"`
+	Enqueued          int64 `json:"enqueued"`
+	Dequeued          int64 `json:"dequeued"`
+	Rejected          int64 `json:"rejected"`
+}
+
+// create a Handler with the default timeouts and no queue.
+func NewHandler() *Handler {
+	return &Handler{
+		PutTimeout: DefaultPutTimeout,
+		MaxWait:    DefaultMaxWait,
+		lock:       new(sync.RWMutex),
+		queues:     make(map[string]*namedQueue),
+	}
+}
+
+/**
+ * @Description: serve q under name, replacing the queue registered under it if any.
+ * @param name must be non-empty and have no slash, or IllegalArgumentError will be panic
+ * @param q if it's nil, NilPointerError will be panic
+ */
+func (h *Handler) Register(name string, q queue.BlockingQueue) {
+	if name == "" || strings.Contains(name, "/") {
+		panic(IllegalArgumentError)
+	}
+	if q == nil {
+		panic(NilPointerError)
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.queues[name] = &namedQueue{q: q}
+}
+
+// stops serving the queue registered under name.
+func (h *Handler) Unregister(name string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	delete(h.queues, name)
+}
+
+// the names of the queues, sorted.
+func (h *Handler) Names() []string {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	names := make([]string, 0, len(h.queues))
+	for name := range h.queues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// the statistics of every queue, by name.
+func (h *Handler) Stats() map[string]Stats {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	stats := make(map[string]Stats, len(h.queues))
+	for name, nq := range h.queues {
+		stats[name] = Stats{
+			Len:               nq.q.Len(),
+			RemainingCapacity: nq.q.RemainingCapacity(),
+			Enqueued:          atomic.LoadInt64(&nq.enqueued),
+			Dequeued:          atomic.LoadInt64(&nq.dequeued),
+			Rejected:          atomic.LoadInt64(&nq.rejected),
+		}
+	}
+	return stats
+}
+
+func (h *Handler) lookup(name string) *namedQueue {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.queues[name]
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/stats" {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		writeJSON(w, http.StatusOK, h.Stats())
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/queues/")
+	if path == r.URL.Path {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	name, stream := path, false
+	if strings.HasSuffix(path, "/stream") {
+		name, stream = strings.TrimSuffix(path, "/stream"), true
+	}
+	nq := h.lookup(name)
+	if nq == nil {
+		writeError(w, http.StatusNotFound, "no such queue: "+name)
+		return
+	}
+	switch {
+	case stream && r.Method == http.MethodGet:
+		h.stream(w, r, nq)
+	case stream:
+		methodNotAllowed(w, http.MethodGet)
+	case r.Method == http.MethodPost:
+		h.enqueue(w, r, nq)
+	case r.Method == http.MethodGet:
+		h.dequeue(w, r, nq)
+	default:
+		methodNotAllowed(w, http.MethodGet+", "+http.MethodPost)
+	}
+}
+
+// reads a duration parameter, capped at MaxWait.
+func (h *Handler) duration(r *http.Request, param string, def time.Duration) (time.Duration, error) {
+	s := r.URL.Query().Get(param)
+	if s == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", param, s)
+	}
+	if d > h.MaxWait {
+		d = h.MaxWait
+	}
+	return d, nil
+}
+
+func (h *Handler) enqueue(w http.ResponseWriter, r *http.Request, nq *namedQueue) {
+	timeout, err := h.duration(r, "timeout", h.PutTimeout)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var x interface{}
+	if err := json.NewDecoder(r.Body).Decode(&x); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	if x == nil {
+		writeError(w, http.StatusBadRequest, "null can't be enqueued")
+		return
+	}
+	if !nq.q.OfferTimout(x, timeout) {
+		atomic.AddInt64(&nq.rejected, 1)
+		writeError(w, http.StatusTooManyRequests, "queue is full")
+		return
+	}
+	atomic.AddInt64(&nq.enqueued, 1)
+	writeJSON(w, http.StatusCreated, map[string]int{"len": nq.q.Len()})
+}
+
+/**
+ * @Description: take the head, waiting up to wait, in slices, so that the wait ends when the client goes away.
+ * @return interface{} nil if the queue is still empty, or the client went away
+ */
+func poll(r *http.Request, nq *namedQueue, wait time.Duration) interface{} {
+	deadline := time.Now().Add(wait)
+	for {
+		slice := time.Until(deadline)
+		if slice > pollSlice {
+			slice = pollSlice
+		}
+		var x interface{}
+		if slice <= 0 {
+			x = nq.q.Poll()
+		} else {
+			x = nq.q.PollTimeout(slice)
+		}
+		if x != nil {
+			atomic.AddInt64(&nq.dequeued, 1)
+			return x
+		}
+		if slice <= 0 || r.Context().Err() != nil {
+			return nil
+		}
+	}
+}
+
+func (h *Handler) dequeue(w http.ResponseWriter, r *http.Request, nq *namedQueue) {
+	wait, err := h.duration(r, "wait", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	x := poll(r, nq, wait)
+	if x == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, x)
+}
+
+// sends every element taken as an event, the id of the events counts them from 1.
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request, nq *namedQueue) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	id := 0
+	for r.Context().Err() == nil {
+		x := poll(r, nq, keepAliveInterval)
+		if x == nil {
+			fmt.Fprint(w, ": keep-alive\n\n")
+		} else {
+			data, err := json.Marshal(x)
+			if err != nil {
+				data, _ = json.Marshal(err.Error())
+				fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+			} else {
+				id++
+				fmt.Fprintf(w, "id: %d\ndata: %s\n\n", id, data)
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		status = http.StatusInternalServerError
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
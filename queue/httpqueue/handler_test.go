@@ -0,0 +1,119 @@
+package httpqueue
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/torchcc/data-structure/queue"
+)
+
+func do(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestHandler_EnqueueDequeue(t *testing.T) {
+	h := NewHandler()
+	h.Register("jobs", queue.NewLinkedBlockingQueue(2))
+
+	if w := do(h, "POST", "/queues/jobs", `{"id":1}`); w.Code != http.StatusCreated || w.Body.String() != "{\"len\":1}\n" {
+		t.Fatalf("post: %d %s", w.Code, w.Body)
+	}
+	do(h, "POST", "/queues/jobs", `"second"`)
+	if w := do(h, "POST", "/queues/jobs?timeout=10ms", `3`); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("post into a full queue: %d", w.Code)
+	}
+	if w := do(h, "GET", "/queues/jobs", ""); w.Code != http.StatusOK || w.Body.String() != "{\"id\":1}\n" {
+		t.Fatalf("get: %d %s", w.Code, w.Body)
+	}
+	if w := do(h, "GET", "/queues/jobs", ""); w.Body.String() != "\"second\"\n" {
+		t.Fatalf("get: %s", w.Body)
+	}
+	if w := do(h, "GET", "/queues/jobs?wait=20ms", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("get from an empty queue: %d", w.Code)
+	}
+
+	for _, c := range []struct {
+		method, target, body string
+		code                 int
+	}{
+		{"POST", "/queues/jobs", `{`, http.StatusBadRequest},
+		{"POST", "/queues/jobs", `null`, http.StatusBadRequest},
+		{"GET", "/queues/jobs?wait=soon", "", http.StatusBadRequest},
+		{"GET", "/queues/missing", "", http.StatusNotFound},
+		{"DELETE", "/queues/jobs", "", http.StatusMethodNotAllowed},
+		{"GET", "/elsewhere", "", http.StatusNotFound},
+	} {
+		if w := do(h, c.method, c.target, c.body); w.Code != c.code {
+			t.Errorf("%s %s: expected %d, got %d", c.method, c.target, c.code, w.Code)
+		}
+	}
+
+	var stats map[string]Stats
+	json.Unmarshal(do(h, "GET", "/stats", "").Body.Bytes(), &stats)
+	want := Stats{Len: 0, RemainingCapacity: 2, Enqueued: 2, Dequeued: 2, Rejected: 1}
+	if stats["jobs"] != want {
+		t.Fatalf("stats: %+v", stats)
+	}
+}
+
+func TestHandler_LongPoll(t *testing.T) {
+	h := NewHandler()
+	q := queue.NewLinkedBlockingQueue(0)
+	h.Register("q", q)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		q.Put("late")
+	}()
+	start := time.Now()
+	if w := do(h, "GET", "/queues/q?wait=5s", ""); w.Code != http.StatusOK || w.Body.String() != "\"late\"\n" {
+		t.Fatalf("long poll: %d %s", w.Code, w.Body)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatal("the long poll must return as soon as an element arrives")
+	}
+}
+
+func TestHandler_Stream(t *testing.T) {
+	h := NewHandler()
+	q := queue.NewLinkedBlockingQueue(0)
+	h.Register("q", q)
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	q.Put("a")
+	resp, err := http.Get(server.URL + "/queues/q/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type %q", ct)
+	}
+	q.Put(map[string]interface{}{"b": true})
+
+	// the events are separated by blank lines, the comments start with a colon.
+	r := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 4 {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, ":") {
+			lines = append(lines, line)
+		}
+	}
+	want := []string{"id: 1", `data: "a"`, "id: 2", `data: {"b":true}`}
+	for k, l := range want {
+		if lines[k] != l {
+			t.Fatalf("events %q", lines)
+		}
+	}
+}
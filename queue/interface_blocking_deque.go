@@ -0,0 +1,98 @@
+package queue
+
+import "time"
+
+type BlockingDeque interface {
+	BlockingQueue
+	Deque
+
+	/**
+	 * Inserts the specified element at the front of this deque,
+	 * waiting if necessary for space to become available.
+	 *
+	 * @param e the element to add
+	 * @return NilPointerError if the specified element is nil
+	 */
+	// 队首插入, 队列满则等待.
+	PutFirst(i interface{}) error
+
+	/**
+	 * Inserts the specified element at the end of this deque,
+	 * waiting if necessary for space to become available.
+	 *
+	 * <p>This method is equivalent to {@link #Put}.
+	 *
+	 * @param e the element to add
+	 * @return NilPointerError if the specified element is nil
+	 */
+	// 队尾插入, 队列满则等待.
+	PutLast(i interface{}) error
+
+	/**
+	 * Inserts the specified element at the front of this deque,
+	 * waiting up to the specified wait time if necessary for space to
+	 * become available.
+	 *
+	 * @param e the element to add
+	 * @param timeout how long to wait before giving up
+	 * @return {@code true} if successful, or {@code false} if
+	 *         the specified waiting time elapses before space is available
+	 * @throws NilPointerError if the specified element is nil
+	 */
+	// 队首插入, 插入成功返回true, 超时返回false
+	OfferFirstTimeout(i interface{}, timeout time.Duration) bool
+
+	/**
+	 * Inserts the specified element at the end of this deque,
+	 * waiting up to the specified wait time if necessary for space to
+	 * become available.
+	 *
+	 * @param e the element to add
+	 * @param timeout how long to wait before giving up
+	 * @return {@code true} if successful, or {@code false} if
+	 *         the specified waiting time elapses before space is available
+	 * @throws NilPointerError if the specified element is nil
+	 */
+	// 队尾插入, 插入成功返回true, 超时返回false
+	OfferLastTimeout(i interface{}, timeout time.Duration) bool
+
+	/**
+	 * Retrieves and removes the first element of this deque, waiting
+	 * if necessary until an element becomes available.
+	 *
+	 * @return the head of this deque
+	 */
+	// 队列非空则出列队首, 队列空则等待
+	TakeFirst() interface{}
+
+	/**
+	 * Retrieves and removes the last element of this deque, waiting
+	 * if necessary until an element becomes available.
+	 *
+	 * @return the tail of this deque
+	 */
+	// 队列非空则出列队尾, 队列空则等待
+	TakeLast() interface{}
+
+	/**
+	 * Retrieves and removes the first element of this deque, waiting
+	 * up to the specified wait time if necessary for an element to
+	 * become available.
+	 *
+	 * @param timeout how long to wait before giving up
+	 * @return the head of this deque, or {@code nil} if the specified
+	 *         waiting time elapses before an element is available
+	 */
+	PollFirstTimeout(timeout time.Duration) interface{}
+
+	/**
+	 * Retrieves and removes the last element of this deque, waiting
+	 * up to the specified wait time if necessary for an element to
+	 * become available.
+	 *
+	 * @param timeout how long to wait before giving up
+	 * @return the tail of this deque, or {@code nil} if the specified
+	 *         waiting time elapses before an element is available
+	 */
+	PollLastTimeout(timeout time.Duration) interface{}
+}
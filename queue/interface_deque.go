@@ -0,0 +1,169 @@
+package queue
+
+type Deque interface {
+	Queue
+
+	/**
+	 * Inserts the specified element at the front of this deque if it is
+	 * possible to do so immediately without violating capacity restrictions,
+	 * panic an {@code IllegalStateError} if no space is currently available.
+	 *
+	 * @param e the element to add
+	 * @throws NilPointerError if the specified element is nil
+	 */
+	// 队首插入, 队列满抛出异常.
+	AddFirst(i interface{})
+
+	/**
+	 * Inserts the specified element at the end of this deque if it is
+	 * possible to do so immediately without violating capacity restrictions,
+	 * panic an {@code IllegalStateError} if no space is currently available.
+	 *
+	 * <p>This method is equivalent to {@link #Add}.
+	 *
+	 * @param e the element to add
+	 * @throws NilPointerError if the specified element is nil
+	 */
+	// 队尾插入, 队列满抛出异常.
+	AddLast(i interface{})
+
+	/**
+	 * Inserts the specified element at the front of this deque unless it would
+	 * violate capacity restrictions.
+	 *
+	 * @param e the element to add
+	 * @return {@code true} if the element was added to this deque, else
+	 *         {@code false}
+	 * @throws NilPointerError if the specified element is nil
+	 */
+	// 队首插入, 插入成功返回true, 插入失败返回false
+	OfferFirst(i interface{}) bool
+
+	/**
+	 * Inserts the specified element at the end of this deque unless it would
+	 * violate capacity restrictions.
+	 *
+	 * <p>This method is equivalent to {@link #Offer}.
+	 *
+	 * @param e the element to add
+	 * @return {@code true} if the element was added to this deque, else
+	 *         {@code false}
+	 * @throws NilPointerError if the specified element is nil
+	 */
+	// 队尾插入, 插入成功返回true, 插入失败返回false
+	OfferLast(i interface{}) bool
+
+	/**
+	 * Retrieves and removes the first element of this deque.  This method
+	 * differs from {@link #PollFirst PollFirst} only in that it panics if
+	 * this deque is empty.
+	 *
+	 * @return the head of this deque
+	 * @throws NoSuchElementError if this deque is empty
+	 */
+	// 出列队首元素, 队列为空抛出异常.
+	RemoveFirst() interface{}
+
+	/**
+	 * Retrieves and removes the last element of this deque.  This method
+	 * differs from {@link #PollLast PollLast} only in that it panics if
+	 * this deque is empty.
+	 *
+	 * @return the tail of this deque
+	 * @throws NoSuchElementError if this deque is empty
+	 */
+	// 出列队尾元素, 队列为空抛出异常.
+	RemoveLast() interface{}
+
+	/**
+	 * Retrieves and removes the first element of this deque,
+	 * or returns {@code nil} if this deque is empty.
+	 *
+	 * @return the head of this deque, or {@code nil} if this deque is empty
+	 */
+	// 出列队首元素, 队列为空返回nil
+	PollFirst() interface{}
+
+	/**
+	 * Retrieves and removes the last element of this deque,
+	 * or returns {@code nil} if this deque is empty.
+	 *
+	 * @return the tail of this deque, or {@code nil} if this deque is empty
+	 */
+	// 出列队尾元素, 队列为空返回nil
+	PollLast() interface{}
+
+	/**
+	 * Retrieves, but does not remove, the first element of this deque.
+	 * This method differs from {@link #PeekFirst PeekFirst} only in that it
+	 * panics if this deque is empty.
+	 *
+	 * @return the head of this deque
+	 * @throws NoSuchElementError if this deque is empty
+	 */
+	// 返回队首元素, 队列为空抛出异常.
+	GetFirst() interface{}
+
+	/**
+	 * Retrieves, but does not remove, the last element of this deque.
+	 * This method differs from {@link #PeekLast PeekLast} only in that it
+	 * panics if this deque is empty.
+	 *
+	 * @return the tail of this deque
+	 * @throws NoSuchElementError if this deque is empty
+	 */
+	// 返回队尾元素, 队列为空抛出异常.
+	GetLast() interface{}
+
+	/**
+	 * Retrieves, but does not remove, the first element of this deque,
+	 * or returns {@code nil} if this deque is empty.
+	 *
+	 * @return the head of this deque, or {@code nil} if this deque is empty
+	 */
+	// 返回队首元素, 队列为空返回nil
+	PeekFirst() interface{}
+
+	/**
+	 * Retrieves, but does not remove, the last element of this deque,
+	 * or returns {@code nil} if this deque is empty.
+	 *
+	 * @return the tail of this deque, or {@code nil} if this deque is empty
+	 */
+	// 返回队尾元素, 队列为空返回nil
+	PeekLast() interface{}
+
+	/**
+	 * Pushes an element onto the stack represented by this deque (in other
+	 * words, at the head of this deque).
+	 *
+	 * <p>This method is equivalent to {@link #AddFirst}.
+	 *
+	 * @param e the element to push
+	 * @throws IllegalStateError if the element cannot be added at this
+	 *         time due to capacity restrictions
+	 * @throws NilPointerError if the specified element is nil
+	 */
+	// 入栈, 等价于AddFirst
+	Push(i interface{})
+
+	/**
+	 * Pops an element from the stack represented by this deque.  In other
+	 * words, removes and returns the first element of this deque.
+	 *
+	 * <p>This method is equivalent to {@link #RemoveFirst()}.
+	 *
+	 * @return the element at the front of this deque (which is the top
+	 *         of the stack represented by this deque)
+	 * @throws NoSuchElementError if this deque is empty
+	 */
+	// 出栈, 等价于RemoveFirst
+	Pop() interface{}
+
+	/**
+	 * @Description: iterate through the deque in reverse sequential order,
+	 *               from last (tail) to first (head).
+	 * @param f return false to stop the iteration
+	 */
+	DescendingRange(f func(value interface{}) bool)
+}
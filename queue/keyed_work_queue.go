@@ -0,0 +1,198 @@
+package queue
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * A work queue coalescing the elements by key, with the semantics of the
+ * Kubernetes client-go workqueue.
+ *
+ * <p>Every element carries a key, given by the keyOf func. A key is at most once
+ * in the queue: adding an element whose key is pending replaces the pending
+ * element in place, it keeps its position. A key taken by a consumer is being
+ * processed until the consumer calls Done(key), an element added meanwhile is
+ * held back (the last one wins) and queued only by Done, so no two consumers
+ * ever process the same key at the same time.
+ *
+ * <p>The queue is unbounded. After ShutDown, Add is ignored and the consumers
+ * drain the pending elements, then Take returns nil.
+ */
+type KeyedWorkQueue struct {
+	lock     *sync.Mutex
+	notEmpty *sync.Cond
+
+	keyOf func(i interface{}) interface{}
+	// the pending elements, in order
+	queue *list.List
+	// the list element of every pending key
+	pending map[interface{}]*list.Element
+	// the keys being processed
+	processing map[interface{}]struct{}
+	// the elements added while their key was being processed, queued by Done
+	dirty map[interface{}]interface{}
+
+	shuttingDown bool
+}
+
+/**
+ * @Description: create a KeyedWorkQueue.
+ * @param keyOf returns the key of an element, keys must be usable as map keys.
+ *        if keyOf is nil, NilPointerError will be panic
+ * @return *KeyedWorkQueue
+ */
+func NewKeyedWorkQueue(keyOf func(i interface{}) interface{}) *KeyedWorkQueue {
+	if keyOf == nil {
+		panic(NilPointerError)
+	}
+	lock := new(sync.Mutex)
+	return &KeyedWorkQueue{
+		lock:       lock,
+		notEmpty:   sync.NewCond(lock),
+		keyOf:      keyOf,
+		queue:      list.New(),
+		pending:    make(map[interface{}]*list.Element),
+		processing: make(map[interface{}]struct{}),
+		dirty:      make(map[interface{}]interface{}),
+	}
+}
+
+/**
+ * @Description: add an element, see KeyedWorkQueue for what happens to an element whose key is
+ *               pending or being processed.
+ * @param i if i is nil, NilPointerError will be panic
+ * @return bool false if the queue has been shut down, the element is then dropped
+ */
+func (q *KeyedWorkQueue) Add(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.shuttingDown {
+		return false
+	}
+	key := q.keyOf(i)
+	if _, ok := q.processing[key]; ok {
+		q.dirty[key] = i
+		return true
+	}
+	if e, ok := q.pending[key]; ok {
+		e.Value = i
+		return true
+	}
+	q.pending[key] = q.queue.PushBack(i)
+	q.notEmpty.Signal()
+	return true
+}
+
+// takes the head and marks its key as being processed, with lock held.
+func (q *KeyedWorkQueue) dequeue() interface{} {
+	e := q.queue.Front()
+	if e == nil {
+		return nil
+	}
+	x := q.queue.Remove(e)
+	key := q.keyOf(x)
+	delete(q.pending, key)
+	q.processing[key] = struct{}{}
+	return x
+}
+
+/**
+ * @Description: mark the key of an element returned by Take or Poll as processed.
+ *               if an element with the same key was added meanwhile, it's queued now.
+ * @param key
+ */
+func (q *KeyedWorkQueue) Done(key interface{}) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.processing, key)
+	if x, ok := q.dirty[key]; ok {
+		delete(q.dirty, key)
+		q.pending[key] = q.queue.PushBack(x)
+		q.notEmpty.Signal()
+	}
+}
+
+/**
+ * @Description: take the head, waiting if necessary until an element becomes available.
+ *               the caller must call Done with its key once it's processed.
+ * @return interface{} nil once the queue has been shut down and drained
+ */
+func (q *KeyedWorkQueue) Take() interface{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for q.queue.Len() == 0 && !q.shuttingDown {
+		q.notEmpty.Wait()
+	}
+	return q.dequeue()
+}
+
+// like Take, but returns nil at once if nothing is pending.
+func (q *KeyedWorkQueue) Poll() interface{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.dequeue()
+}
+
+// like Take, but returns nil if nothing is pending after timeout.
+func (q *KeyedWorkQueue) PollTimeout(timeout time.Duration) interface{} {
+	deadline := time.Now().Add(timeout)
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for q.queue.Len() == 0 && !q.shuttingDown {
+		if !waitUntil(q.notEmpty, deadline) {
+			break
+		}
+	}
+	return q.dequeue()
+}
+
+// the number of pending keys, the keys being processed are not counted.
+func (q *KeyedWorkQueue) Len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.queue.Len()
+}
+
+func (q *KeyedWorkQueue) IsEmpty() bool {
+	return q.Len() == 0
+}
+
+// whether an element with the given key is waiting to be taken.
+func (q *KeyedWorkQueue) IsPending(key interface{}) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	_, ok := q.pending[key]
+	return ok
+}
+
+// whether the given key has been taken and Done has not been called yet.
+func (q *KeyedWorkQueue) IsProcessing(key interface{}) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	_, ok := q.processing[key]
+	return ok
+}
+
+/**
+ * @Description: stop accepting elements, and wake up the waiting consumers once nothing is pending.
+ *               elements held back for keys being processed are still queued by Done.
+ */
+func (q *KeyedWorkQueue) ShutDown() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.shuttingDown = true
+	q.notEmpty.Broadcast()
+}
+
+func (q *KeyedWorkQueue) ShuttingDown() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.shuttingDown
+}
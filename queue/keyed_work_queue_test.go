@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type resourceEvent struct {
+	id      string
+	version int
+}
+
+func newResourceQueue() *KeyedWorkQueue {
+	return NewKeyedWorkQueue(func(i interface{}) interface{} {
+		return i.(resourceEvent).id
+	})
+}
+
+func TestKeyedWorkQueue_CoalescesPendingKeys(t *testing.T) {
+	q := newResourceQueue()
+	q.Add(resourceEvent{"a", 1})
+	q.Add(resourceEvent{"b", 1})
+	q.Add(resourceEvent{"a", 2})
+	if q.Len() != 2 || !q.IsPending("a") {
+		t.Fatalf("want 2 pending keys, got %d", q.Len())
+	}
+	// a keeps its position, with its last payload.
+	if x := q.Poll(); x != (resourceEvent{"a", 2}) {
+		t.Fatalf("want a@2, got %v", x)
+	}
+	if x := q.Poll(); x != (resourceEvent{"b", 1}) {
+		t.Fatalf("want b@1, got %v", x)
+	}
+}
+
+func TestKeyedWorkQueue_RequeuesAfterDone(t *testing.T) {
+	q := newResourceQueue()
+	q.Add(resourceEvent{"a", 1})
+	if q.Take() != (resourceEvent{"a", 1}) || !q.IsProcessing("a") {
+		t.Fatal("a should be processing")
+	}
+	q.Add(resourceEvent{"a", 2})
+	q.Add(resourceEvent{"a", 3})
+	if q.Len() != 0 || q.PollTimeout(10*time.Millisecond) != nil {
+		t.Fatal("a must not be handed out while it's processed")
+	}
+	q.Done("a")
+	if q.IsProcessing("a") || q.Len() != 1 {
+		t.Fatal("Done should queue the held back element")
+	}
+	if x := q.Poll(); x != (resourceEvent{"a", 3}) {
+		t.Fatalf("want a@3, got %v", x)
+	}
+	q.Done("a")
+	if q.Len() != 0 {
+		t.Fatal("nothing was added during the second processing")
+	}
+}
+
+func TestKeyedWorkQueue_NoConcurrentProcessingOfAKey(t *testing.T) {
+	q := newResourceQueue()
+	var mu sync.Mutex
+	busy := map[string]bool{}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for x := q.Take(); x != nil; x = q.Take() {
+				id := x.(resourceEvent).id
+				mu.Lock()
+				if busy[id] {
+					t.Errorf("%s is processed twice at the same time", id)
+				}
+				busy[id] = true
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+				mu.Lock()
+				busy[id] = false
+				mu.Unlock()
+				q.Done(id)
+			}
+		}()
+	}
+	for v := 0; v < 200; v++ {
+		q.Add(resourceEvent{[]string{"a", "b", "c"}[v%3], v})
+	}
+	for !q.IsEmpty() {
+		time.Sleep(time.Millisecond)
+	}
+	q.ShutDown()
+	wg.Wait()
+	if q.Add(resourceEvent{"a", 0}) {
+		t.Fatal("Add after ShutDown should be ignored")
+	}
+}
@@ -0,0 +1,577 @@
+package queue
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * An optionally-bounded blocking deque based on linked nodes.
+ *
+ * unlike LinkedBlockingQueue, which uses two locks, both ends of a deque
+ * can be touched by any operation, so a single lock guards the whole list,
+ * with two conditions for waiting takes and puts.
+ */
+type LinkedBlockingDeque struct {
+	// The number of items in the deque
+	count int64
+
+	// the capacity set
+	capacity int
+
+	// Main lock guarding all access
+	lock *sync.Mutex
+	// Condition for waiting takes
+	notEmpty *sync.Cond
+	// Condition for waiting puts
+	notFull *sync.Cond
+
+	// the doubly linked list holding the elements, Front is the head of the deque
+	list *list.List
+}
+
+// helper functions below, they should be called with lock held.
+
+// Links e as first element, or returns false if full.
+func (d *LinkedBlockingDeque) linkFirst(e interface{}) bool {
+	if d.list.Len() >= d.capacity {
+		return false
+	}
+	d.list.PushFront(e)
+	atomic.AddInt64(&d.count, 1)
+	d.notEmpty.Signal()
+	return true
+}
+
+// Links e as last element, or returns false if full.
+func (d *LinkedBlockingDeque) linkLast(e interface{}) bool {
+	if d.list.Len() >= d.capacity {
+		return false
+	}
+	d.list.PushBack(e)
+	atomic.AddInt64(&d.count, 1)
+	d.notEmpty.Signal()
+	return true
+}
+
+// Removes and returns first element, or nil if empty.
+func (d *LinkedBlockingDeque) unlinkFirst() interface{} {
+	f := d.list.Front()
+	if f == nil {
+		return nil
+	}
+	return d.unlink(f)
+}
+
+// Removes and returns last element, or nil if empty.
+func (d *LinkedBlockingDeque) unlinkLast() interface{} {
+	l := d.list.Back()
+	if l == nil {
+		return nil
+	}
+	return d.unlink(l)
+}
+
+// Unlinks e.
+func (d *LinkedBlockingDeque) unlink(e *list.Element) interface{} {
+	x := d.list.Remove(e)
+	atomic.AddInt64(&d.count, -1)
+	d.notFull.Signal()
+	return x
+}
+
+func (d *LinkedBlockingDeque) AddFirst(i interface{}) {
+	if !d.OfferFirst(i) {
+		panic(IllegalStateError)
+	}
+}
+
+func (d *LinkedBlockingDeque) AddLast(i interface{}) {
+	if !d.OfferLast(i) {
+		panic(IllegalStateError)
+	}
+}
+
+func (d *LinkedBlockingDeque) OfferFirst(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.linkFirst(i)
+}
+
+func (d *LinkedBlockingDeque) OfferLast(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.linkLast(i)
+}
+
+func (d *LinkedBlockingDeque) PutFirst(i interface{}) error {
+	if i == nil {
+		return NilPointerError
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for !d.linkFirst(i) {
+		d.notFull.Wait()
+	}
+	return nil
+}
+
+func (d *LinkedBlockingDeque) PutLast(i interface{}) error {
+	if i == nil {
+		return NilPointerError
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for !d.linkLast(i) {
+		d.notFull.Wait()
+	}
+	return nil
+}
+
+func (d *LinkedBlockingDeque) OfferFirstTimeout(i interface{}, timeout time.Duration) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	deadline := time.Now().Add(timeout)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for !d.linkFirst(i) {
+		if !waitUntil(d.notFull, deadline) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *LinkedBlockingDeque) OfferLastTimeout(i interface{}, timeout time.Duration) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	deadline := time.Now().Add(timeout)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for !d.linkLast(i) {
+		if !waitUntil(d.notFull, deadline) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *LinkedBlockingDeque) RemoveFirst() interface{} {
+	if x := d.PollFirst(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (d *LinkedBlockingDeque) RemoveLast() interface{} {
+	if x := d.PollLast(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (d *LinkedBlockingDeque) PollFirst() interface{} {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.unlinkFirst()
+}
+
+func (d *LinkedBlockingDeque) PollLast() interface{} {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.unlinkLast()
+}
+
+func (d *LinkedBlockingDeque) TakeFirst() interface{} {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for {
+		if x := d.unlinkFirst(); x != nil {
+			return x
+		}
+		d.notEmpty.Wait()
+	}
+}
+
+func (d *LinkedBlockingDeque) TakeLast() interface{} {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for {
+		if x := d.unlinkLast(); x != nil {
+			return x
+		}
+		d.notEmpty.Wait()
+	}
+}
+
+func (d *LinkedBlockingDeque) PollFirstTimeout(timeout time.Duration) interface{} {
+	deadline := time.Now().Add(timeout)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for {
+		if x := d.unlinkFirst(); x != nil {
+			return x
+		}
+		if !waitUntil(d.notEmpty, deadline) {
+			return nil
+		}
+	}
+}
+
+func (d *LinkedBlockingDeque) PollLastTimeout(timeout time.Duration) interface{} {
+	deadline := time.Now().Add(timeout)
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for {
+		if x := d.unlinkLast(); x != nil {
+			return x
+		}
+		if !waitUntil(d.notEmpty, deadline) {
+			return nil
+		}
+	}
+}
+
+func (d *LinkedBlockingDeque) GetFirst() interface{} {
+	if x := d.PeekFirst(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (d *LinkedBlockingDeque) GetLast() interface{} {
+	if x := d.PeekLast(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (d *LinkedBlockingDeque) PeekFirst() interface{} {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if f := d.list.Front(); f != nil {
+		return f.Value
+	}
+	return nil
+}
+
+func (d *LinkedBlockingDeque) PeekLast() interface{} {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if l := d.list.Back(); l != nil {
+		return l.Value
+	}
+	return nil
+}
+
+// *** BlockingQueue methods ***
+
+/**
+ * Inserts the specified element at the end of this deque unless it would
+ * violate capacity restrictions. panic an IllegalStateError if no space is currently available.
+ * <p>This method is equivalent to {@link #AddLast}.
+ */
+func (d *LinkedBlockingDeque) Add(i interface{}) bool {
+	d.AddLast(i)
+	return true
+}
+
+func (d *LinkedBlockingDeque) Offer(i interface{}) bool {
+	return d.OfferLast(i)
+}
+
+func (d *LinkedBlockingDeque) Put(i interface{}) error {
+	return d.PutLast(i)
+}
+
+func (d *LinkedBlockingDeque) OfferTimout(i interface{}, timeout time.Duration) bool {
+	return d.OfferLastTimeout(i, timeout)
+}
+
+func (d *LinkedBlockingDeque) RemoveHead() interface{} {
+	return d.RemoveFirst()
+}
+
+func (d *LinkedBlockingDeque) Poll() interface{} {
+	return d.PollFirst()
+}
+
+func (d *LinkedBlockingDeque) Take() interface{} {
+	return d.TakeFirst()
+}
+
+func (d *LinkedBlockingDeque) PollTimeout(timeout time.Duration) interface{} {
+	return d.PollFirstTimeout(timeout)
+}
+
+func (d *LinkedBlockingDeque) Element() interface{} {
+	return d.GetFirst()
+}
+
+func (d *LinkedBlockingDeque) Peek() interface{} {
+	return d.PeekFirst()
+}
+
+func (d *LinkedBlockingDeque) RemainingCapacity() int {
+	return d.capacity - d.Len()
+}
+
+// *** Stack methods ***
+
+func (d *LinkedBlockingDeque) Push(i interface{}) {
+	d.AddFirst(i)
+}
+
+func (d *LinkedBlockingDeque) Pop() interface{} {
+	return d.RemoveFirst()
+}
+
+// *** Collection methods ***
+
+func (d *LinkedBlockingDeque) Len() int {
+	return int(atomic.LoadInt64(&d.count))
+}
+
+func (d *LinkedBlockingDeque) IsEmpty() bool {
+	return d.Len() == 0
+}
+
+func (d *LinkedBlockingDeque) Contains(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for cur := d.list.Front(); cur != nil; cur = cur.Next() {
+		if cur.Value == i {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @Description: iterate through the deque from first (head) to last (tail).
+ *               the deque is locked during the iteration, f must not call back into the deque.
+ * @receiver d
+ * @param f return false to stop the iteration
+ */
+func (d *LinkedBlockingDeque) Range(f func(value interface{}) bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for cur := d.list.Front(); cur != nil; cur = cur.Next() {
+		if !f(cur.Value) {
+			return
+		}
+	}
+}
+
+/**
+ * @Description: iterate through the deque from last (tail) to first (head).
+ *               the deque is locked during the iteration, f must not call back into the deque.
+ * @receiver d
+ * @param f return false to stop the iteration
+ */
+func (d *LinkedBlockingDeque) DescendingRange(f func(value interface{}) bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for cur := d.list.Back(); cur != nil; cur = cur.Prev() {
+		if !f(cur.Value) {
+			return
+		}
+	}
+}
+
+func (d *LinkedBlockingDeque) ToSlice() []interface{} {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	ret := make([]interface{}, 0, d.list.Len())
+	for cur := d.list.Front(); cur != nil; cur = cur.Next() {
+		ret = append(ret, cur.Value)
+	}
+	return ret
+}
+
+func (d *LinkedBlockingDeque) String() string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if p := d.list.Front(); p == nil {
+		return "[]"
+	} else {
+		sb := "["
+		for {
+			e := p.Value
+			if e == d {
+				sb += "(this Collection)"
+			} else {
+				sb += fmt.Sprintf("%v", e)
+			}
+			p = p.Next()
+			if p == nil {
+				return sb + "]"
+			}
+			sb += ", "
+		}
+	}
+}
+
+/**
+ * Removes the first occurrence of the specified element from this deque.
+ * If the deque does not contain the element, it is unchanged.
+ *
+ * @param o element to be removed from this deque, if present
+ * @return {@code true} if this deque changed as a result of the call
+ */
+func (d *LinkedBlockingDeque) Remove(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for cur := d.list.Front(); cur != nil; cur = cur.Next() {
+		if cur.Value == i {
+			d.unlink(cur)
+			return true
+		}
+	}
+	return false
+}
+
+// lower performance
+func (d *LinkedBlockingDeque) ContainsAll(c Collection) bool {
+	containsAll := true
+	c.Range(func(value interface{}) bool {
+		if !d.Contains(value) {
+			containsAll = false
+			return false
+		}
+		return true
+	})
+	return containsAll
+}
+
+/**
+ * @Description: appends all non-nil elements of c to the end of this deque until it is full.
+ *               nil element in c will be skipped. it'll return FullError/ NilPointerError to indicate error.
+ * @receiver d
+ * @param c  if c is nil, NilPointerError is returned
+ * @return bool indicates whether the deque has been changed or not when the func return
+ */
+func (d *LinkedBlockingDeque) AddAll(c Collection) (modified bool, err error) {
+	if c == nil {
+		return false, NilPointerError
+	}
+	// c is ranged before locking, so that adding a deque to itself won't dead lock.
+	elements := c.ToSlice()
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, e := range elements {
+		if e == nil {
+			err = NilPointerError
+			continue
+		}
+		if !d.linkLast(e) {
+			return modified, FullError
+		}
+		modified = true
+	}
+	return
+}
+
+func (d *LinkedBlockingDeque) RemoveAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	// c is copied before locking, so that d.RemoveAll(d) won't dead lock.
+	elements := c.ToSlice()
+	return d.RemoveIf(func(value interface{}) bool {
+		return sliceContains(elements, value)
+	})
+}
+
+func (d *LinkedBlockingDeque) RemoveIf(filter func(value interface{}) bool) bool {
+	if filter == nil {
+		panic(NilPointerError)
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	removed := false
+	for cur := d.list.Front(); cur != nil; {
+		next := cur.Next()
+		if filter(cur.Value) {
+			d.unlink(cur)
+			removed = true
+		}
+		cur = next
+	}
+	return removed
+}
+
+func (d *LinkedBlockingDeque) RetainAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	elements := c.ToSlice()
+	return d.RemoveIf(func(value interface{}) bool {
+		return !sliceContains(elements, value)
+	})
+}
+
+/**
+ * Atomically removes all of the elements from this deque.
+ * The deque will be empty after this call returns.
+ */
+func (d *LinkedBlockingDeque) Clear() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.list.Init()
+	atomic.StoreInt64(&d.count, 0)
+	d.notFull.Broadcast()
+}
+
+/**
+ * @Description: create a LinkedBlockingDeque with the given capacity.
+ *               if capacity is 0, it'll be replace by math.MaxInt32,
+ *               if capacity is less than 0, IllegalArgumentError will be panic
+ * @param capacity
+ * @return *LinkedBlockingDeque
+ */
+func NewLinkedBlockingDeque(capacity int) *LinkedBlockingDeque {
+	if capacity < 0 {
+		panic(IllegalArgumentError)
+	}
+	if capacity == 0 {
+		capacity = math.MaxInt32
+	}
+	lock := new(sync.Mutex)
+	return &LinkedBlockingDeque{
+		capacity: capacity,
+		lock:     lock,
+		notEmpty: sync.NewCond(lock),
+		notFull:  sync.NewCond(lock),
+		list:     list.New(),
+	}
+}
+
+func sliceContains(s []interface{}, i interface{}) bool {
+	for _, e := range s {
+		if e == i {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package queue
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+var _ BlockingDeque = (*LinkedBlockingDeque)(nil)
+
+func TestLinkedBlockingDeque_BothEnds(t *testing.T) {
+	d := NewLinkedBlockingDeque(4)
+	d.OfferLast(2)
+	d.OfferFirst(1)
+	d.Push(0)
+	d.AddLast(3)
+	if d.OfferLast(4) {
+		t.Fatal("offer to a full deque should fail")
+	}
+	if d.PeekFirst() != 0 || d.PeekLast() != 3 {
+		t.Fatalf("unexpected ends: %v", d)
+	}
+
+	var desc []interface{}
+	d.DescendingRange(func(value interface{}) bool {
+		desc = append(desc, value)
+		return true
+	})
+	if len(desc) != 4 || desc[0] != 3 || desc[3] != 0 {
+		t.Fatalf("unexpected descending order: %v", desc)
+	}
+
+	if x := d.Pop(); x != 0 {
+		t.Fatalf("Pop: got %v", x)
+	}
+	if x := d.PollLast(); x != 3 {
+		t.Fatalf("PollLast: got %v", x)
+	}
+	if !d.Remove(1) || d.Len() != 1 || d.RemainingCapacity() != 3 {
+		t.Fatalf("unexpected state after Remove: %v", d)
+	}
+	d.Clear()
+	if d.PollFirst() != nil || d.PollLast() != nil {
+		t.Fatal("poll on an empty deque should return nil")
+	}
+}
+
+func TestLinkedBlockingDeque_Timeouts(t *testing.T) {
+	d := NewLinkedBlockingDeque(1)
+	begin := time.Now()
+	if x := d.PollLastTimeout(50 * time.Millisecond); x != nil {
+		t.Fatalf("PollLastTimeout: got %v", x)
+	}
+	if time.Since(begin) < 50*time.Millisecond {
+		t.Fatal("PollLastTimeout returned before the timeout")
+	}
+
+	d.PutFirst(1)
+	if d.OfferFirstTimeout(2, 20*time.Millisecond) {
+		t.Fatal("OfferFirstTimeout to a full deque should time out")
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		d.TakeLast()
+	}()
+	if !d.OfferFirstTimeout(2, time.Second) {
+		t.Fatal("OfferFirstTimeout should succeed once space is available")
+	}
+}
+
+func TestLinkedBlockingDeque_ProducerConsumer(t *testing.T) {
+	const n = 1000
+	d := NewLinkedBlockingDeque(8)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= n; i++ {
+			d.PutLast(i)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= n; i++ {
+			if x := d.TakeFirst(); x != i {
+				t.Errorf("TakeFirst: want %d, got %v", i, x)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+}
@@ -31,12 +31,24 @@ type LinkedBlockingQueue struct {
 
 	// head of linked list
 	head *list.List
+
+	// what insertions do when the queue is full, and the callback receiving the dropped elements
+	policy OverflowPolicy
+	onDrop func(i interface{})
 }
 
 func (q *LinkedBlockingQueue) Offer(i interface{}) bool {
 	if i == nil {
 		panic(NilPointerError)
 	}
+	if q.offer(i) {
+		return true
+	}
+	return q.overflow(i)
+}
+
+// inserts i at the tail if the queue is not full, whatever the overflow policy.
+func (q *LinkedBlockingQueue) offer(i interface{}) bool {
 	if q.capacity == q.Len() {
 		return false
 	}
@@ -114,6 +126,12 @@ func (q *LinkedBlockingQueue) Put(i interface{}) error {
 	if i == nil {
 		return NilPointerError
 	}
+	if q.policy != OverflowBlock {
+		if q.offer(i) || q.overflow(i) {
+			return nil
+		}
+		return FullError
+	}
 	c := -1
 	q.putLock.Lock()
 	for q.Len() == q.capacity {
@@ -136,6 +154,10 @@ func (q *LinkedBlockingQueue) OfferTimout(i interface{}, timeout time.Duration)
 	if i == nil {
 		panic(NilPointerError)
 	}
+	if q.policy != OverflowBlock {
+		// the other policies never wait.
+		return q.Offer(i)
+	}
 	c := -1
 	begin := time.Now()
 
@@ -434,6 +456,24 @@ func NewLinkedBlockingQueue(capacity int) *LinkedBlockingQueue {
 	}
 }
 
+/**
+ * @Description: create a LinkedBlockingQueue handling insertions into a full queue with the given policy.
+ * @param capacity as for NewLinkedBlockingQueue
+ * @param policy if it's not a known OverflowPolicy, IllegalArgumentError will be panic
+ * @param onDrop if not nil, it's called with every element dropped by OverflowDropNewest or OverflowDropOldest,
+ *        outside of the locks of the queue, by the goroutine inserting.
+ * @return *LinkedBlockingQueue
+ */
+func NewLinkedBlockingQueueWithPolicy(capacity int, policy OverflowPolicy, onDrop func(i interface{})) *LinkedBlockingQueue {
+	if policy < OverflowBlock || policy > OverflowDropOldest {
+		panic(IllegalArgumentError)
+	}
+	q := NewLinkedBlockingQueue(capacity)
+	q.policy = policy
+	q.onDrop = onDrop
+	return q
+}
+
 /**
  * @Description: create a LinkedBlockingQueue from a slice.  if the give capacity is less than the slice's len, FullError will be return
  * @param s
@@ -461,7 +501,7 @@ func FromSlice(s []interface{}, capacity int) (*LinkedBlockingQueue, error) {
 }
 
 func (q *LinkedBlockingQueue) DeepCopy() *LinkedBlockingQueue {
-	copied := NewLinkedBlockingQueue(q.capacity)
+	copied := NewLinkedBlockingQueueWithPolicy(q.capacity, q.policy, q.onDrop)
 	var n int64
 	q.Range(func(value interface{}) bool {
 		copied.head.PushBack(value)
@@ -0,0 +1,121 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"io"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+// the version of the binary form, its first byte.
+const binaryFormVersion = 1
+
+/**
+ * @Description: implement encoding.BinaryMarshaler with a compact form of a consistent snapshot of the queue:
+ *
+ *   version  byte
+ *   capacity uvarint
+ *   count    uvarint
+ *   elements a single gob stream of count interface values
+ *
+ *               the elements share the gob stream, so the description of a type is sent once, not once per
+ *               element. every element is encoded with its concrete type, the types other than the basic ones
+ *               must be registered with gob.Register, under the same name, by the encoding and the decoding
+ *               side, from an init func for example. unlike WriteSnapshot, there is no checksum.
+ * @receiver q
+ * @return error the error of gob, for an unregistered type for example
+ */
+func (q *LinkedBlockingQueue) MarshalBinary() ([]byte, error) {
+	elements := q.ToSlice()
+	var buf bytes.Buffer
+	var scratch [binary.MaxVarintLen64]byte
+	putUvarint := func(v uint64) {
+		buf.Write(scratch[:binary.PutUvarint(scratch[:], v)])
+	}
+	buf.WriteByte(binaryFormVersion)
+	putUvarint(uint64(q.capacity))
+	putUvarint(uint64(len(elements)))
+	enc := gob.NewEncoder(&buf)
+	for _, e := range elements {
+		// encoding a pointer to the interface sends the concrete type along, as GobCodec does.
+		if err := enc.Encode(&e); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+/**
+ * @Description: implement encoding.BinaryUnmarshaler, the capacity and the elements of the queue are
+ *               replaced with the ones in data, as FromSlice does. the queue may be the zero value,
+ *               it must not be in use by other goroutines. the overflow policy of the queue is kept.
+ * @receiver q
+ * @param data the form written by MarshalBinary
+ * @return error InvalidSnapshotError if data is not valid, io.ErrUnexpectedEOF if it's truncated,
+ *         or the error of gob
+ */
+func (q *LinkedBlockingQueue) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	version, err := r.ReadByte()
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if version != binaryFormVersion {
+		return InvalidSnapshotError
+	}
+	capacity, err := readBinaryUvarint(r)
+	if err != nil {
+		return err
+	}
+	count, err := readBinaryUvarint(r)
+	if err != nil {
+		return err
+	}
+	// every element takes a byte at least, a corrupted count can't allocate more than data.
+	if capacity > maxCapacity || count > capacity || count > uint64(r.Len()) {
+		return InvalidSnapshotError
+	}
+	elements := make([]interface{}, 0, count)
+	// r is an io.ByteReader, the decoder doesn't read past the last element.
+	dec := gob.NewDecoder(r)
+	for k := uint64(0); k < count; k++ {
+		var e interface{}
+		if err := dec.Decode(&e); err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		elements = append(elements, e)
+	}
+	if r.Len() != 0 {
+		return InvalidSnapshotError
+	}
+	restored, err := FromSlice(elements, int(capacity))
+	if err != nil {
+		return err
+	}
+	restored.policy, restored.onDrop = q.policy, q.onDrop
+	*q = *restored
+	return nil
+}
+
+func readBinaryUvarint(r *bytes.Reader) (uint64, error) {
+	v, err := binary.ReadUvarint(r)
+	if err == io.EOF {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return v, err
+}
+
+// implement gob.GobEncoder with the binary form, so that a queue can be a field of a struct encoded with gob.
+func (q *LinkedBlockingQueue) GobEncode() ([]byte, error) {
+	return q.MarshalBinary()
+}
+
+// implement gob.GobDecoder, see UnmarshalBinary.
+func (q *LinkedBlockingQueue) GobDecode(data []byte) error {
+	return q.UnmarshalBinary(data)
+}
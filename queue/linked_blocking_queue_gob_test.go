@@ -0,0 +1,83 @@
+package queue
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"testing"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+type gobTestJob struct {
+	ID   int
+	Name string
+}
+
+func init() {
+	gob.Register(gobTestJob{})
+}
+
+func TestLinkedBlockingQueue_Gob(t *testing.T) {
+	type state struct {
+		Name    string
+		Pending *LinkedBlockingQueue
+	}
+	q, _ := FromSlice([]interface{}{gobTestJob{1, "a"}, "b", 3}, 8)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(state{Name: "worker", Pending: q}); err != nil {
+		t.Fatal(err)
+	}
+	var decoded state
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	p := decoded.Pending
+	if decoded.Name != "worker" || p.String() != q.String() || p.RemainingCapacity() != 5 {
+		t.Fatalf("want %v with room for 5, got %v with room for %d", q, p, p.RemainingCapacity())
+	}
+	if x := p.Poll(); x != (gobTestJob{1, "a"}) {
+		t.Fatalf("want the job back with its type, got %#v", x)
+	}
+}
+
+func TestLinkedBlockingQueue_Binary(t *testing.T) {
+	q, _ := FromSlice([]interface{}{"x", 2.5}, 0)
+	data, err := q.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r LinkedBlockingQueue
+	if err := r.UnmarshalBinary(data); err != nil || r.String() != q.String() || r.RemainingCapacity() != q.RemainingCapacity() {
+		t.Fatalf("want %v, got %v: %v", q, &r, err)
+	}
+	if err := r.UnmarshalBinary(data[:len(data)-1]); err == nil {
+		t.Fatal("a truncated form should be rejected")
+	}
+	if err := r.UnmarshalBinary(data[:2]); err != io.ErrUnexpectedEOF {
+		t.Fatalf("want io.ErrUnexpectedEOF, got %v", err)
+	}
+	if err := r.UnmarshalBinary(append([]byte{9}, data[1:]...)); err != InvalidSnapshotError {
+		t.Fatalf("want InvalidSnapshotError, got %v", err)
+	}
+}
+
+func TestLinkedBlockingQueue_BinaryDescribesTypesOnce(t *testing.T) {
+	elements := make([]interface{}, 50)
+	for k := range elements {
+		elements[k] = gobTestJob{k, "job"}
+	}
+	q, _ := FromSlice(elements, 0)
+	data, err := q.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the field names are part of the description of gobTestJob.
+	if n := bytes.Count(data, []byte("Name")); n != 1 {
+		t.Fatalf("the type should be described once, found %d times", n)
+	}
+	var r LinkedBlockingQueue
+	if err := r.UnmarshalBinary(data); err != nil || r.String() != q.String() {
+		t.Fatalf("want %v, got %v: %v", q, &r, err)
+	}
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"encoding/json"
+	"reflect"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+// the JSON form of a LinkedBlockingQueue.
+type jsonQueue struct {
+	Capacity int           `json:"capacity"`
+	Elements []jsonElement `json:"elements"`
+}
+
+type jsonElement struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+/**
+ * @Description: implement json.Marshaler with DefaultTypeRegistry, see MarshalJSONWith.
+ */
+func (q *LinkedBlockingQueue) MarshalJSON() ([]byte, error) {
+	return q.MarshalJSONWith(DefaultTypeRegistry)
+}
+
+/**
+ * @Description: marshal a consistent snapshot of the queue as
+ *               {"capacity":10,"elements":[{"type":"int","value":1},{"type":"string","value":"a"}]}
+ * @receiver q
+ * @param registry gives the tag of the type of every element
+ * @return error wrapping UnregisteredTypeError if the type of an element is not registered,
+ *         or the error of json.Marshal
+ */
+func (q *LinkedBlockingQueue) MarshalJSONWith(registry *TypeRegistry) ([]byte, error) {
+	elements := q.ToSlice()
+	out := jsonQueue{Capacity: q.capacity, Elements: make([]jsonElement, len(elements))}
+	for k, e := range elements {
+		tag, err := registry.TagOf(e)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		out.Elements[k] = jsonElement{Type: tag, Value: value}
+	}
+	return json.Marshal(out)
+}
+
+/**
+ * @Description: implement json.Unmarshaler with DefaultTypeRegistry, see UnmarshalJSONWith.
+ */
+func (q *LinkedBlockingQueue) UnmarshalJSON(data []byte) error {
+	return q.UnmarshalJSONWith(data, DefaultTypeRegistry)
+}
+
+/**
+ * @Description: replace the capacity and the elements of the queue with the ones in data, as FromSlice does.
+ *               the queue may be the zero value, it must not be in use by other goroutines.
+ *               the overflow policy of the queue is kept.
+ * @receiver q
+ * @param data the form written by MarshalJSONWith
+ * @param registry gives the type of every tag
+ * @return error wrapping UnregisteredTypeError if a tag is not registered, the error of json.Unmarshal,
+ *         IllegalArgumentError if the capacity is negative, or the error of FromSlice
+ */
+func (q *LinkedBlockingQueue) UnmarshalJSONWith(data []byte, registry *TypeRegistry) error {
+	var in jsonQueue
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Capacity < 0 {
+		return IllegalArgumentError
+	}
+	elements := make([]interface{}, len(in.Elements))
+	for k, e := range in.Elements {
+		t, err := registry.TypeOf(e.Type)
+		if err != nil {
+			return err
+		}
+		v := reflect.New(t)
+		if err := json.Unmarshal(e.Value, v.Interface()); err != nil {
+			return err
+		}
+		elements[k] = v.Elem().Interface()
+	}
+	restored, err := FromSlice(elements, in.Capacity)
+	if err != nil {
+		return err
+	}
+	restored.policy, restored.onDrop = q.policy, q.onDrop
+	*q = *restored
+	return nil
+}
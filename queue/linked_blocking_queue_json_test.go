@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+type jsonTestPoint struct {
+	X, Y int
+}
+
+func init() {
+	DefaultTypeRegistry.MustRegister("point", jsonTestPoint{})
+	DefaultTypeRegistry.MustRegister("*point", &jsonTestPoint{})
+}
+
+func TestLinkedBlockingQueue_JSON(t *testing.T) {
+	q, _ := FromSlice([]interface{}{"a", 1, 2.5, int64(7), jsonTestPoint{1, 2}, &jsonTestPoint{3, 4}}, 10)
+	data, err := json.Marshal(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored struct {
+		Queue *LinkedBlockingQueue
+	}
+	if err := json.Unmarshal([]byte(`{"Queue":`+string(data)+`}`), &restored); err != nil {
+		t.Fatal(err)
+	}
+	r := restored.Queue
+	if r.RemainingCapacity() != 4 || r.Len() != 6 {
+		t.Fatalf("want 6 elements with room for 4, got %v", r)
+	}
+	want := q.ToSlice()
+	for k, x := range r.ToSlice() {
+		if p, ok := x.(*jsonTestPoint); ok {
+			if *p != *want[k].(*jsonTestPoint) {
+				t.Fatalf("element %d: want %v, got %v", k, want[k], x)
+			}
+		} else if x != want[k] {
+			t.Fatalf("element %d: want %#v, got %#v", k, want[k], x)
+		}
+	}
+	r.Put("usable")
+	if r.Len() != 7 {
+		t.Fatal("the restored queue should be usable")
+	}
+}
+
+func TestLinkedBlockingQueue_JSONUnregisteredType(t *testing.T) {
+	type secret struct{}
+	q, _ := FromSlice([]interface{}{secret{}}, 0)
+	if _, err := json.Marshal(q); !errors.Is(err, UnregisteredTypeError) {
+		t.Fatalf("want UnregisteredTypeError, got %v", err)
+	}
+	var r LinkedBlockingQueue
+	err := r.UnmarshalJSON([]byte(`{"capacity":1,"elements":[{"type":"nope","value":1}]}`))
+	if !errors.Is(err, UnregisteredTypeError) {
+		t.Fatalf("want UnregisteredTypeError, got %v", err)
+	}
+	if err := r.UnmarshalJSONWith([]byte(`{"capacity":3,"elements":[{"type":"int","value":1}]}`), DefaultTypeRegistry); err != nil || r.Peek() != 1 {
+		t.Fatalf("the zero value should be usable once unmarshalled: %v", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"testing"
 	"time"
+
+	. "github.com/torchcc/data-structure/error"
 )
 
 // we use only Offer and PollTimeout
@@ -36,3 +38,46 @@ func TestLinkedBlockingQueue_Put(t *testing.T) {
 
 	}
 }
+
+func TestLinkedBlockingQueue_OverflowPolicies(t *testing.T) {
+	var dropped []interface{}
+	onDrop := func(i interface{}) { dropped = append(dropped, i) }
+
+	reject := NewLinkedBlockingQueueWithPolicy(2, OverflowReject, onDrop)
+	reject.Put(1)
+	reject.Put(2)
+	if err := reject.Put(3); err != FullError {
+		t.Fatalf("Reject: Put should return FullError, got %v", err)
+	}
+	if reject.Offer(3) || reject.OfferTimout(3, time.Hour) {
+		t.Fatal("Reject: Offer and OfferTimout should fail at once")
+	}
+
+	newest := NewLinkedBlockingQueueWithPolicy(2, OverflowDropNewest, onDrop)
+	for i := 1; i <= 5; i++ {
+		if i%2 == 0 {
+			newest.Add(i)
+		} else if err := newest.Put(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !newest.OfferTimout(6, time.Hour) || newest.String() != "[1, 2]" {
+		t.Fatalf("DropNewest: %v", newest)
+	}
+	if fmt.Sprint(dropped) != "[3 4 5 6]" {
+		t.Fatalf("DropNewest: dropped %v", dropped)
+	}
+
+	dropped = nil
+	oldest := NewLinkedBlockingQueueWithPolicy(2, OverflowDropOldest, onDrop)
+	for i := 1; i <= 4; i++ {
+		oldest.Put(i)
+	}
+	oldest.Offer(5)
+	if oldest.String() != "[4, 5]" || fmt.Sprint(dropped) != "[1 2 3]" || oldest.Len() != 2 {
+		t.Fatalf("DropOldest: %v, dropped %v", oldest, dropped)
+	}
+	if oldest.Poll() != 4 || oldest.Poll() != 5 || oldest.Poll() != nil {
+		t.Fatal("DropOldest: the queue should hold the newest elements in order")
+	}
+}
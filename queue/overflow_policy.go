@@ -0,0 +1,75 @@
+package queue
+
+import "sync/atomic"
+
+// OverflowPolicy tells what the insertions into a full bounded queue do.
+type OverflowPolicy int
+
+const (
+	// Put and OfferTimout wait for space to become available, Offer returns false and Add panics IllegalStateError.
+	// it's the default.
+	OverflowBlock OverflowPolicy = iota
+	// nothing waits: Put returns FullError, Offer and OfferTimout return false, Add panics IllegalStateError.
+	OverflowReject
+	// the new element is dropped, the insertion succeeds without waiting.
+	OverflowDropNewest
+	// the head of the queue is evicted to make room for the new element, the insertion succeeds without waiting.
+	OverflowDropOldest
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "Block"
+	case OverflowReject:
+		return "Reject"
+	case OverflowDropNewest:
+		return "DropNewest"
+	case OverflowDropOldest:
+		return "DropOldest"
+	}
+	return "OverflowPolicy(?)"
+}
+
+/**
+ * @Description: handle the insertion of i into a full queue without waiting, according to the policy.
+ * @return bool whether the insertion succeeded, it's false for OverflowBlock and OverflowReject
+ */
+func (q *LinkedBlockingQueue) overflow(i interface{}) bool {
+	switch q.policy {
+	case OverflowDropNewest:
+		q.drop(i)
+		return true
+	case OverflowDropOldest:
+		if evicted := q.offerEvictingHead(i); evicted != nil {
+			q.drop(evicted)
+		}
+		return true
+	}
+	return false
+}
+
+// inserts i at the tail, evicting the head if the queue is still full. returns the evicted element, if any.
+func (q *LinkedBlockingQueue) offerEvictingHead(i interface{}) (evicted interface{}) {
+	q.fullyLock()
+	defer q.fullyUnlock()
+	if q.Len() == q.capacity {
+		evicted = q.dequeue()
+		q.head.PushBack(i)
+		// as Poll does after taking from a full queue, putLock is held by fullyLock.
+		q.notFull.Signal()
+		return evicted
+	}
+	// room has been made meanwhile.
+	q.head.PushBack(i)
+	if atomic.AddInt64(&q.length, 1) == 1 {
+		q.notEmpty.Signal()
+	}
+	return nil
+}
+
+func (q *LinkedBlockingQueue) drop(i interface{}) {
+	if q.onDrop != nil {
+		q.onDrop(i)
+	}
+}
@@ -0,0 +1,184 @@
+package queue
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * A RateLimiter tells how long an element should wait before it's queued
+ * again, see RateLimitingQueue.
+ */
+type RateLimiter interface {
+	// returns how long i should wait, and records one more requeue of i.
+	When(i interface{}) time.Duration
+	// resets the requeue count of i, call it once i has been processed successfully.
+	Forget(i interface{})
+	// the number of requeues of i since it was last forgotten.
+	NumRequeues(i interface{}) int
+}
+
+/**
+ * ExponentialFailureRateLimiter delays every element by baseDelay*2^failures,
+ * up to maxDelay, where failures counts the requeues of the element since it was
+ * last forgotten. the elements are map keys, so they must be comparable.
+ */
+type ExponentialFailureRateLimiter struct {
+	lock      sync.Mutex
+	failures  map[interface{}]int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+}
+
+/**
+ * @Description: create an ExponentialFailureRateLimiter.
+ * @param baseDelay the delay of the first requeue
+ * @param maxDelay the longest delay, if it's less than baseDelay, IllegalArgumentError will be panic
+ * @return *ExponentialFailureRateLimiter
+ */
+func NewExponentialFailureRateLimiter(baseDelay, maxDelay time.Duration) *ExponentialFailureRateLimiter {
+	if baseDelay < 0 || maxDelay < baseDelay {
+		panic(IllegalArgumentError)
+	}
+	return &ExponentialFailureRateLimiter{
+		failures:  make(map[interface{}]int),
+		baseDelay: baseDelay,
+		maxDelay:  maxDelay,
+	}
+}
+
+func (r *ExponentialFailureRateLimiter) When(i interface{}) time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	exp := r.failures[i]
+	r.failures[i]++
+	// computed in float64, 2^exp overflows an int64 quickly.
+	backoff := float64(r.baseDelay) * math.Pow(2, float64(exp))
+	if backoff > float64(r.maxDelay) {
+		return r.maxDelay
+	}
+	return time.Duration(backoff)
+}
+
+func (r *ExponentialFailureRateLimiter) Forget(i interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.failures, i)
+}
+
+func (r *ExponentialFailureRateLimiter) NumRequeues(i interface{}) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.failures[i]
+}
+
+/**
+ * BucketRateLimiter is a token bucket shared by all the elements: it holds up
+ * to burst tokens, refilled at rate tokens per second, and every requeue takes
+ * one. when the bucket is empty, the delay is the time until the token of the
+ * requeue is refilled, so the requeues are spread at rate per second.
+ */
+type BucketRateLimiter struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+/**
+ * @Description: create a BucketRateLimiter, its bucket is full.
+ * @param rate the tokens refilled per second, if it's not positive, IllegalArgumentError will be panic
+ * @param burst the size of the bucket, if it's less than 1, IllegalArgumentError will be panic
+ * @return *BucketRateLimiter
+ */
+func NewBucketRateLimiter(rate float64, burst int) *BucketRateLimiter {
+	if rate <= 0 || burst < 1 {
+		panic(IllegalArgumentError)
+	}
+	return &BucketRateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (r *BucketRateLimiter) When(i interface{}) time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := time.Now()
+	r.tokens = math.Min(r.burst, r.tokens+now.Sub(r.last).Seconds()*r.rate)
+	r.last = now
+	// a negative balance reserves the tokens to come.
+	r.tokens--
+	if r.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-r.tokens / r.rate * float64(time.Second))
+}
+
+func (r *BucketRateLimiter) Forget(i interface{}) {
+}
+
+func (r *BucketRateLimiter) NumRequeues(i interface{}) int {
+	return 0
+}
+
+/**
+ * MaxOfRateLimiter combines several RateLimiters: the delay is the longest of
+ * their delays, and the requeue count the highest of their counts.
+ */
+type MaxOfRateLimiter struct {
+	limiters []RateLimiter
+}
+
+func NewMaxOfRateLimiter(limiters ...RateLimiter) *MaxOfRateLimiter {
+	for _, l := range limiters {
+		if l == nil {
+			panic(NilPointerError)
+		}
+	}
+	return &MaxOfRateLimiter{limiters: limiters}
+}
+
+func (r *MaxOfRateLimiter) When(i interface{}) time.Duration {
+	var longest time.Duration
+	for _, l := range r.limiters {
+		if d := l.When(i); d > longest {
+			longest = d
+		}
+	}
+	return longest
+}
+
+func (r *MaxOfRateLimiter) Forget(i interface{}) {
+	for _, l := range r.limiters {
+		l.Forget(i)
+	}
+}
+
+func (r *MaxOfRateLimiter) NumRequeues(i interface{}) int {
+	highest := 0
+	for _, l := range r.limiters {
+		if n := l.NumRequeues(i); n > highest {
+			highest = n
+		}
+	}
+	return highest
+}
+
+/**
+ * @Description: the usual rate limiter: a per element exponential backoff from 5ms to 1000s,
+ *               and an overall limit of 10 requeues per second with bursts of 100.
+ * @return RateLimiter
+ */
+func DefaultRateLimiter() RateLimiter {
+	return NewMaxOfRateLimiter(
+		NewExponentialFailureRateLimiter(5*time.Millisecond, 1000*time.Second),
+		NewBucketRateLimiter(10, 100),
+	)
+}
@@ -0,0 +1,228 @@
+package queue
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * A RateLimitingQueue wraps a BlockingQueue, it's a BlockingQueue itself, with
+ * delayed insertions on top: AddAfter puts an element into the wrapped queue
+ * once a delay has passed, AddRateLimited once the delay given by the
+ * RateLimiter has passed, which is how failed elements are retried with backoff.
+ *
+ * <p>The delayed elements are held in a heap by a background goroutine, which
+ * puts them into the wrapped queue in the order of their deadlines. AddAfter
+ * never waits: a full wrapped queue only holds up that goroutine, hence the
+ * delayed elements. ShutDown stops the goroutine, even if it's waiting for
+ * space, and drops the delayed elements that are still waiting.
+ */
+type RateLimitingQueue struct {
+	BlockingQueue
+	limiter RateLimiter
+
+	// guards waiting, pending and seq
+	lock sync.Mutex
+	// the delayed elements not due yet, or not put because the wrapped queue is full
+	waiting delayedElementHeap
+	// the number of elements taken from waiting, which the goroutine is putting
+	pending int
+	seq     uint64
+	// wakes the background goroutine up when a delayed element is added
+	wake chan struct{}
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// how long the background goroutine waits for space at a time, before checking ShutDown again.
+const rateLimitingPutSlice = 100 * time.Millisecond
+
+type delayedElement struct {
+	value   interface{}
+	readyAt time.Time
+	// breaks the ties between equal deadlines, in the order of insertion
+	seq uint64
+}
+
+// a min heap of delayed elements, ordered by deadline.
+type delayedElementHeap []*delayedElement
+
+func (h delayedElementHeap) Len() int {
+	return len(h)
+}
+
+func (h delayedElementHeap) Less(i, j int) bool {
+	if h[i].readyAt.Equal(h[j].readyAt) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].readyAt.Before(h[j].readyAt)
+}
+
+func (h delayedElementHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *delayedElementHeap) Push(x interface{}) {
+	*h = append(*h, x.(*delayedElement))
+}
+
+func (h *delayedElementHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return x
+}
+
+/**
+ * @Description: create a RateLimitingQueue, and start its background goroutine.
+ * @param q the wrapped queue, if it's nil, NilPointerError will be panic
+ * @param limiter nil means DefaultRateLimiter()
+ * @return *RateLimitingQueue
+ */
+func NewRateLimitingQueue(q BlockingQueue, limiter RateLimiter) *RateLimitingQueue {
+	if q == nil {
+		panic(NilPointerError)
+	}
+	if limiter == nil {
+		limiter = DefaultRateLimiter()
+	}
+	r := &RateLimitingQueue{
+		BlockingQueue: q,
+		limiter:       limiter,
+		wake:          make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+	}
+	go r.loop()
+	return r
+}
+
+// the background goroutine, it puts the delayed elements whose deadline has passed.
+func (r *RateLimitingQueue) loop() {
+	for !r.ShuttingDown() {
+		now := time.Now()
+		var ready *delayedElement
+		var next <-chan time.Time
+		var timer *time.Timer
+		r.lock.Lock()
+		if r.waiting.Len() > 0 {
+			if head := r.waiting[0]; head.readyAt.After(now) {
+				timer = time.NewTimer(head.readyAt.Sub(now))
+				next = timer.C
+			} else {
+				ready = heap.Pop(&r.waiting).(*delayedElement)
+				r.pending++
+			}
+		}
+		r.lock.Unlock()
+		if ready != nil {
+			r.put(ready.value)
+			continue
+		}
+		select {
+		case <-r.stop:
+		case <-r.wake:
+		case <-next:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// puts a pending element, waiting for space by slices so that ShutDown can interrupt it.
+func (r *RateLimitingQueue) put(i interface{}) {
+	for !r.BlockingQueue.OfferTimout(i, rateLimitingPutSlice) {
+		if r.ShuttingDown() {
+			return
+		}
+	}
+	r.lock.Lock()
+	if r.pending > 0 {
+		r.pending--
+	}
+	r.lock.Unlock()
+}
+
+/**
+ * @Description: put i into the wrapped queue once delay has passed, without waiting.
+ *               it's ignored after ShutDown.
+ * @param i if i is nil, NilPointerError will be panic
+ * @param delay if it's not positive, i is put at once if there is space,
+ *        otherwise by the background goroutine as soon as there is
+ */
+func (r *RateLimitingQueue) AddAfter(i interface{}, delay time.Duration) {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	if r.ShuttingDown() {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	// the due elements still waiting for space come first.
+	now := time.Now()
+	if delay <= 0 && r.pending == 0 && (r.waiting.Len() == 0 || r.waiting[0].readyAt.After(now)) && r.BlockingQueue.Offer(i) {
+		return
+	}
+	// checked again with the lock held, ShutDown empties the heap with it held.
+	if r.ShuttingDown() {
+		return
+	}
+	r.seq++
+	heap.Push(&r.waiting, &delayedElement{value: i, readyAt: now.Add(delay), seq: r.seq})
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+/**
+ * @Description: put i into the wrapped queue once the delay given by the RateLimiter has passed.
+ *               every call counts as one more requeue of i, until Forget(i).
+ */
+func (r *RateLimitingQueue) AddRateLimited(i interface{}) {
+	r.AddAfter(i, r.limiter.When(i))
+}
+
+// resets the requeue count of i, call it once i has been processed successfully.
+func (r *RateLimitingQueue) Forget(i interface{}) {
+	r.limiter.Forget(i)
+}
+
+// the number of requeues of i since it was last forgotten.
+func (r *RateLimitingQueue) NumRequeues(i interface{}) int {
+	return r.limiter.NumRequeues(i)
+}
+
+// the number of delayed elements not in the wrapped queue yet.
+func (r *RateLimitingQueue) Waiting() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.waiting.Len() + r.pending
+}
+
+// stops the background goroutine, the delayed elements still waiting are dropped.
+func (r *RateLimitingQueue) ShutDown() {
+	r.stopOnce.Do(func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		close(r.stop)
+		r.waiting = nil
+		r.pending = 0
+	})
+}
+
+func (r *RateLimitingQueue) ShuttingDown() bool {
+	select {
+	case <-r.stop:
+		return true
+	default:
+		return false
+	}
+}
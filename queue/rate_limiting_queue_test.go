@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+var _ BlockingQueue = (*RateLimitingQueue)(nil)
+
+func TestExponentialFailureRateLimiter(t *testing.T) {
+	r := NewExponentialFailureRateLimiter(time.Millisecond, 5*time.Millisecond)
+	want := []time.Duration{1, 2, 4, 5, 5}
+	for k, w := range want {
+		if d := r.When("x"); d != w*time.Millisecond {
+			t.Fatalf("requeue %d: want %v, got %v", k, w*time.Millisecond, d)
+		}
+	}
+	if r.NumRequeues("x") != 5 || r.NumRequeues("y") != 0 {
+		t.Fatal("NumRequeues")
+	}
+	r.Forget("x")
+	if r.NumRequeues("x") != 0 || r.When("x") != time.Millisecond {
+		t.Fatal("Forget should reset the backoff")
+	}
+}
+
+func TestBucketRateLimiter(t *testing.T) {
+	r := NewBucketRateLimiter(100, 2)
+	if r.When("a") != 0 || r.When("b") != 0 {
+		t.Fatal("the burst should pass at once")
+	}
+	// the next tokens come every 10ms.
+	if d := r.When("c"); d <= 0 || d > 10*time.Millisecond {
+		t.Fatalf("want about 10ms, got %v", d)
+	}
+	if d := r.When("d"); d <= 10*time.Millisecond || d > 20*time.Millisecond {
+		t.Fatalf("want about 20ms, got %v", d)
+	}
+}
+
+func TestRateLimitingQueue_AddAfter(t *testing.T) {
+	q := NewRateLimitingQueue(NewLinkedBlockingQueue(0), nil)
+	defer q.ShutDown()
+	q.AddAfter("late", 40*time.Millisecond)
+	q.AddAfter("early", 20*time.Millisecond)
+	q.AddAfter("now", 0)
+	if q.Waiting() != 2 || q.Poll() != "now" || q.Poll() != nil {
+		t.Fatal("only the element without delay should be in the queue")
+	}
+	begin := time.Now()
+	if x := q.PollTimeout(time.Second); x != "early" {
+		t.Fatalf("want early, got %v", x)
+	}
+	if x := q.Take(); x != "late" {
+		t.Fatalf("want late, got %v", x)
+	}
+	if elapsed := time.Since(begin); elapsed < 30*time.Millisecond {
+		t.Fatalf("late came after %v only", elapsed)
+	}
+	if q.Waiting() != 0 {
+		t.Fatal("nothing should be waiting")
+	}
+}
+
+func TestRateLimitingQueue_AddRateLimited(t *testing.T) {
+	q := NewRateLimitingQueue(NewLinkedBlockingQueue(0), NewExponentialFailureRateLimiter(time.Millisecond, time.Second))
+	for k := 0; k < 3; k++ {
+		q.AddRateLimited("job")
+		if x := q.PollTimeout(time.Second); x != "job" {
+			t.Fatalf("retry %d: got %v", k, x)
+		}
+	}
+	if q.NumRequeues("job") != 3 {
+		t.Fatalf("want 3 requeues, got %d", q.NumRequeues("job"))
+	}
+	q.Forget("job")
+	if q.NumRequeues("job") != 0 {
+		t.Fatal("Forget")
+	}
+	q.ShutDown()
+	q.AddAfter("dropped", time.Millisecond)
+	if q.PollTimeout(20*time.Millisecond) != nil || !q.ShuttingDown() {
+		t.Fatal("AddAfter should be ignored after ShutDown")
+	}
+}
+
+func TestRateLimitingQueue_FullWrappedQueue(t *testing.T) {
+	inner := NewLinkedBlockingQueue(1)
+	q := NewRateLimitingQueue(inner, nil)
+	inner.Put("first")
+	done := make(chan struct{})
+	go func() {
+		// more than any channel buffer, none of them may wait for space.
+		for k := 0; k < 2000; k++ {
+			q.AddAfter(k, time.Duration(k%2)*time.Millisecond)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("AddAfter waited for the wrapped queue")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if n := q.Waiting(); n != 2000 {
+		t.Fatalf("want 2000 waiting, got %d", n)
+	}
+	// the background goroutine is waiting for space, ShutDown stops it all the same.
+	q.ShutDown()
+	if n := q.Waiting(); n != 0 {
+		t.Fatalf("want 0 waiting after ShutDown, got %d", n)
+	}
+	time.Sleep(2 * rateLimitingPutSlice)
+	if x := inner.Poll(); x != "first" {
+		t.Fatalf("want first, got %v", x)
+	}
+	if x := inner.PollTimeout(2 * rateLimitingPutSlice); x != nil {
+		t.Fatalf("nothing should be put after ShutDown, got %v", x)
+	}
+}
@@ -0,0 +1,867 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * The replication protocol. a replica connects to the primary and says hello:
+ *
+ * <pre>
+ * "DSQR" [uint8 version][uint64 write offset][uint32 checksum of the last record]
+ * </pre>
+ *
+ * then the primary streams frames, the first byte telling their type:
+ *
+ * <pre>
+ * 'R' [uint64 base]                                  drop everything, the log restarts at base
+ * 'A' [uint64 offset][uint32 length][uint32 crc32][payload]  a record appended at offset
+ * 'C' [uint64 offset]                                the consumer offset moved to offset
+ * </pre>
+ *
+ * and the replica acknowledges the records it has appended:
+ *
+ * <pre>
+ * 'K' [uint64 write offset]
+ * </pre>
+ *
+ * all the integers are big endian.
+ */
+const (
+	replicationMagic   = "DSQR"
+	replicationVersion = 1
+
+	replicationReset   = 'R'
+	replicationAppend  = 'A'
+	replicationConsume = 'C'
+	replicationAck     = 'K'
+
+	// a batch of records read from the log at once, with the lock of the queue held
+	replicationBatchRecords = 256
+	replicationBatchBytes   = 1 << 20
+
+	DefaultReplicationAckTimeout = 5 * time.Second
+	// the replicas reconnect with a backoff from 10ms up to this
+	replicaMaxBackoff = time.Second
+)
+
+// the records a replica lacks have been consumed and deleted on the primary.
+var errReplicaBehind = errors.New("replica is behind the oldest segment")
+
+// a malformed frame, the connection is dropped.
+var errReplicationProtocol = errors.New("replication protocol error")
+
+// DiskQueue hooks for the replication: snapshotLog and watch should be called with lock held, readRecords and
+// replicationStart without it, the others take it.
+
+// where a primary reads its log for a replica.
+type replicationCursor struct {
+	// the offset of the next record to send
+	offset int64
+	// the segment open in f, and the position of offset in it
+	base int64
+	pos  int64
+	f    *os.File
+}
+
+func (c *replicationCursor) close() {
+	if c.f != nil {
+		c.f.Close()
+		c.f = nil
+	}
+}
+
+// the segments of a log at some point, read by a primary without holding the lock of the queue.
+type logSnapshot struct {
+	segments    []diskSegment
+	writeOffset int64
+}
+
+// takes a snapshot of the log, with lock held.
+func (q *DiskQueue) snapshotLog() logSnapshot {
+	s := logSnapshot{segments: make([]diskSegment, len(q.segments)), writeOffset: q.writeOffset}
+	for k, seg := range q.segments {
+		s.segments[k] = *seg
+	}
+	return s
+}
+
+// returns the segment holding offset, which must be below writeOffset.
+func (s logSnapshot) segmentOf(offset int64) diskSegment {
+	for _, seg := range s.segments {
+		if offset < seg.base+seg.count {
+			return seg
+		}
+	}
+	return s.segments[len(s.segments)-1]
+}
+
+/**
+ * @Description: read the records of the snapshot from the offset of the cursor, and move the cursor
+ *               after them. it's called without lock, the records of the snapshot don't change.
+ * @return [][]byte the payloads, up to replicationBatchRecords records or replicationBatchBytes bytes
+ * @return error errReplicaBehind if the records at the cursor have been deleted
+ */
+func (q *DiskQueue) readRecords(c *replicationCursor, s logSnapshot) ([][]byte, error) {
+	if c.offset < s.segments[0].base {
+		return nil, errReplicaBehind
+	}
+	var records [][]byte
+	size := 0
+	for c.offset < s.writeOffset && len(records) < replicationBatchRecords && size < replicationBatchBytes {
+		seg := s.segmentOf(c.offset)
+		if c.f == nil || c.base != seg.base {
+			c.close()
+			f, err := os.Open(q.segmentPath(seg.base))
+			if os.IsNotExist(err) {
+				// consumed and deleted since the snapshot.
+				return nil, errReplicaBehind
+			}
+			if err != nil {
+				return nil, err
+			}
+			c.f, c.base, c.pos = f, seg.base, 0
+			for k := seg.base; k < c.offset; k++ {
+				_, n, err := readDiskRecord(f, c.pos, seg.size)
+				if err != nil {
+					return nil, err
+				}
+				c.pos += n
+			}
+		}
+		payload, n, err := readDiskRecord(c.f, c.pos, seg.size)
+		if err != nil {
+			return nil, err
+		}
+		c.pos += n
+		c.offset++
+		records = append(records, payload)
+		size += len(payload)
+	}
+	return records, nil
+}
+
+// tells where to start streaming to a replica which has the records up to writeOffset, or whether to reset it.
+func (q *DiskQueue) replicationStart(s logSnapshot, writeOffset int64, lastCRC uint32) (int64, bool) {
+	base := s.segments[0].base
+	if writeOffset < base || writeOffset > s.writeOffset {
+		return base, true
+	}
+	if writeOffset > base {
+		// the logs must agree on the last record of the replica, or it's reset.
+		c := &replicationCursor{offset: writeOffset - 1}
+		defer c.close()
+		records, err := q.readRecords(c, s)
+		if err != nil || crc32.ChecksumIEEE(records[0]) != lastCRC {
+			return base, true
+		}
+	}
+	return writeOffset, false
+}
+
+// returns a channel closed on the next change of the queue.
+func (q *DiskQueue) watch() <-chan struct{} {
+	if q.changed == nil {
+		q.changed = make(chan struct{})
+	}
+	return q.changed
+}
+
+// appends a record replicated from the primary, it must be at writeOffset.
+func (q *DiskQueue) appendAt(offset int64, payload []byte) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if err := q.stopped(); err != nil {
+		return err
+	}
+	if offset != q.writeOffset {
+		return errReplicationProtocol
+	}
+	return q.enqueue(payload)
+}
+
+// moves the consumer offset forward to offset, without decoding the records.
+func (q *DiskQueue) consumeTo(offset int64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if err := q.stopped(); err != nil {
+		return err
+	}
+	if q.readOffset >= offset {
+		return nil
+	}
+	for q.readOffset < offset && q.readOffset < q.writeOffset {
+		_, n, err := readDiskRecord(q.reader, q.readPos, q.segments[0].size)
+		if err != nil {
+			return q.fail(err)
+		}
+		q.readPos += n
+		q.readOffset++
+		atomic.AddInt64(&q.length, -1)
+		if err := q.dropConsumedSegments(); err != nil {
+			return q.fail(err)
+		}
+	}
+	q.notFull.Broadcast()
+	if err := q.saveOffset(); err != nil {
+		return q.fail(err)
+	}
+	return nil
+}
+
+// deletes the log, which restarts empty at base.
+func (q *DiskQueue) resetAt(base int64) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.reset(base)
+}
+
+// deletes the log, which restarts empty at base, with lock held.
+func (q *DiskQueue) reset(base int64) error {
+	if err := q.stopped(); err != nil {
+		return err
+	}
+	q.writer.Close()
+	bases := make([]int64, len(q.segments))
+	for k, seg := range q.segments {
+		bases[k] = seg.base
+	}
+	if err := q.removeSegments(bases); err != nil {
+		return q.fail(err)
+	}
+	q.segments = []*diskSegment{{base: base}}
+	q.writeOffset = base
+	q.readOffset = base
+	q.lastCRC = 0
+	atomic.StoreInt64(&q.length, 0)
+	var err error
+	if q.writer, err = os.OpenFile(q.segmentPath(base), os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		return q.fail(err)
+	}
+	if err := q.syncDir(); err != nil {
+		return q.fail(err)
+	}
+	if err := q.openReader(); err != nil {
+		return q.fail(err)
+	}
+	if err := q.saveOffset(); err != nil {
+		return q.fail(err)
+	}
+	q.notFull.Broadcast()
+	return nil
+}
+
+// ReplicationAck tells when an insertion into a Primary returns.
+type ReplicationAck int
+
+const (
+	// as soon as the element is in the queue of the primary, the replicas follow as fast as they can.
+	AckAsync ReplicationAck = iota
+	// once a replica has appended the element too, or AckTimeout has passed. Offer doesn't wait, OfferTimout
+	// waits within its timeout, and no insertion waits while no replica is connected.
+	AckOne
+)
+
+// PrimaryOptions are the options of a Primary, the zero value means the defaults.
+type PrimaryOptions struct {
+	Ack ReplicationAck
+	// how long an insertion waits for a replica with AckOne
+	AckTimeout time.Duration
+}
+
+/**
+ * A Primary is a DiskQueue streaming its log to replicas over TCP: the records
+ * appended, and the moves of the consumer offset. a replica, see NewReplica,
+ * catches up from its own offset, or from scratch when it's behind the oldest
+ * segment of the primary, or when its last record doesn't match the one of the
+ * primary, as after a fail-over.
+ *
+ * <p>With AckOne, the insertions return once a replica has appended the element,
+ * or once AckTimeout has passed, but Offer never waits, OfferTimout waits
+ * within its own timeout, and nothing waits while no replica is connected at
+ * all. the insertions succeed either way, the element being in
+ * the queue of the primary, so that a caller retrying on failure doesn't insert
+ * it twice. to know whether a replica has an element, insert it with PutOffset,
+ * then call WaitAck.
+ *
+ * <p>The Primary embeds the DiskQueue, the methods not overridden are those of the queue.
+ */
+type Primary struct {
+	*DiskQueue
+	opts     PrimaryOptions
+	listener net.Listener
+
+	// guards acked, conns and closed
+	lock *sync.Mutex
+	// signaled when acked moves forward
+	ackCond *sync.Cond
+	// the highest write offset acknowledged by a replica
+	acked  int64
+	conns  map[net.Conn]struct{}
+	closed bool
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+/**
+ * @Description: replicate q to the replicas connecting to l.
+ * @param q the queue, it's closed with the Primary
+ * @param l the listener of the replicas
+ * @param opts nil means the defaults
+ * @return *Primary
+ */
+func NewPrimary(q *DiskQueue, l net.Listener, opts *PrimaryOptions) *Primary {
+	if q == nil || l == nil {
+		panic(NilPointerError)
+	}
+	p := &Primary{DiskQueue: q, listener: l, lock: new(sync.Mutex), conns: make(map[net.Conn]struct{}), done: make(chan struct{})}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.AckTimeout <= 0 {
+		p.opts.AckTimeout = DefaultReplicationAckTimeout
+	}
+	p.ackCond = sync.NewCond(p.lock)
+	p.wg.Add(1)
+	go p.accept()
+	return p
+}
+
+// the address the replicas connect to.
+func (p *Primary) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+// the number of replicas connected.
+func (p *Primary) Replicas() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.conns)
+}
+
+// the highest write offset acknowledged by a replica, the records before it are on a replica.
+func (p *Primary) AckedOffset() int64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.acked
+}
+
+func (p *Primary) accept() {
+	defer p.wg.Done()
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			return
+		}
+		p.lock.Lock()
+		if p.closed {
+			p.lock.Unlock()
+			conn.Close()
+			return
+		}
+		p.conns[conn] = struct{}{}
+		p.wg.Add(1)
+		p.lock.Unlock()
+		go p.serveReplica(conn)
+	}
+}
+
+func (p *Primary) serveReplica(conn net.Conn) {
+	defer func() {
+		p.lock.Lock()
+		delete(p.conns, conn)
+		// the insertions waiting for an ack give up once the last replica is gone.
+		p.ackCond.Broadcast()
+		p.lock.Unlock()
+		conn.Close()
+		p.wg.Done()
+	}()
+	r := bufio.NewReader(conn)
+	var hello [len(replicationMagic) + 13]byte
+	if _, err := io.ReadFull(r, hello[:]); err != nil ||
+		string(hello[:4]) != replicationMagic || hello[4] != replicationVersion {
+		return
+	}
+	writeOffset := int64(binary.BigEndian.Uint64(hello[5:13]))
+	lastCRC := binary.BigEndian.Uint32(hello[13:17])
+
+	// the acknowledgements are read aside, gone tells the streaming when the replica goes away.
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		var frame [9]byte
+		for {
+			if _, err := io.ReadFull(r, frame[:]); err != nil || frame[0] != replicationAck {
+				conn.Close()
+				return
+			}
+			p.ack(int64(binary.BigEndian.Uint64(frame[1:])))
+		}
+	}()
+	p.stream(bufio.NewWriter(conn), writeOffset, lastCRC, gone)
+	conn.Close()
+	<-gone
+}
+
+/**
+ * @Description: send the log to a replica, until it goes away, the primary is closed, or reading the log fails.
+ *               the records are read without holding the lock of the queue, from a snapshot of its segments.
+ */
+func (p *Primary) stream(w *bufio.Writer, writeOffset int64, lastCRC uint32, gone <-chan struct{}) {
+	q := p.DiskQueue
+	q.lock.Lock()
+	if q.stopped() != nil {
+		q.lock.Unlock()
+		return
+	}
+	snapshot := q.snapshotLog()
+	q.lock.Unlock()
+	start, reset := q.replicationStart(snapshot, writeOffset, lastCRC)
+	c := &replicationCursor{offset: start}
+	defer c.close()
+	// the consumer offset last sent
+	consumed := int64(-1)
+	for {
+		q.lock.Lock()
+		if q.stopped() != nil {
+			q.lock.Unlock()
+			return
+		}
+		snapshot = q.snapshotLog()
+		// the replica can't consume the records it doesn't have yet, checked once they are read.
+		target := q.readOffset
+		changed := q.watch()
+		q.lock.Unlock()
+
+		records, err := q.readRecords(c, snapshot)
+		if err == errReplicaBehind {
+			// if the oldest segment is deleted meanwhile too, the connection is dropped and the replica starts over.
+			c.close()
+			c = &replicationCursor{offset: snapshot.segments[0].base}
+			reset = true
+			records, err = q.readRecords(c, snapshot)
+		}
+		if err != nil {
+			// the connection is dropped, the replica reconnects and starts over.
+			return
+		}
+		if target > c.offset {
+			target = c.offset
+		}
+
+		if reset {
+			writeReplicationFrame(w, replicationReset, c.offset-int64(len(records)), nil)
+			reset = false
+			consumed = -1
+		}
+		first := c.offset - int64(len(records))
+		for k, payload := range records {
+			writeReplicationFrame(w, replicationAppend, first+int64(k), payload)
+		}
+		if target != consumed {
+			writeReplicationFrame(w, replicationConsume, target, nil)
+		}
+		if w.Flush() != nil {
+			return
+		}
+		if len(records) == 0 && target == consumed {
+			select {
+			case <-changed:
+			case <-gone:
+				return
+			case <-p.done:
+				return
+			}
+		}
+		consumed = target
+	}
+}
+
+func writeReplicationFrame(w *bufio.Writer, typ byte, offset int64, payload []byte) {
+	var header [17]byte
+	header[0] = typ
+	binary.BigEndian.PutUint64(header[1:9], uint64(offset))
+	if typ != replicationAppend {
+		w.Write(header[:9])
+		return
+	}
+	binary.BigEndian.PutUint32(header[9:13], uint32(len(payload)))
+	binary.BigEndian.PutUint32(header[13:17], crc32.ChecksumIEEE(payload))
+	w.Write(header[:])
+	w.Write(payload)
+}
+
+func (p *Primary) ack(offset int64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if offset > p.acked {
+		p.acked = offset
+		p.ackCond.Broadcast()
+	}
+}
+
+/**
+ * @Description: wait until a replica has appended the record at offset.
+ * @param offset the offset returned by PutOffset
+ * @param timeout how long to wait
+ * @return error TimeoutError, or IllegalStateError if the primary is closed
+ */
+func (p *Primary) WaitAck(offset int64, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for p.acked <= offset {
+		if p.closed {
+			return IllegalStateError
+		}
+		if !waitUntil(p.ackCond, deadline) {
+			return TimeoutError
+		}
+	}
+	return nil
+}
+
+/**
+ * @Description: with AckOne, wait for a replica to have the record at offset, for AckTimeout at most,
+ *               and not past deadline. a replica not answering in time is not an error, and there is
+ *               nothing to wait for while no replica is connected.
+ * @param deadline zero means no deadline
+ */
+func (p *Primary) waitAck(offset int64, deadline time.Time) {
+	if p.opts.Ack != AckOne {
+		return
+	}
+	if ackDeadline := time.Now().Add(p.opts.AckTimeout); deadline.IsZero() || ackDeadline.Before(deadline) {
+		deadline = ackDeadline
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for p.acked <= offset && !p.closed && len(p.conns) > 0 {
+		if !waitUntil(p.ackCond, deadline) {
+			return
+		}
+	}
+}
+
+// inserts i into the queue, then waits for a replica as the options tell, unless the insertion may not wait.
+func (p *Primary) insert(i interface{}, wait bool, deadline time.Time) error {
+	payload, err := p.codec.Encode(i)
+	if err != nil {
+		return err
+	}
+	offset, err := p.insertPayload(payload, wait, deadline)
+	if err != nil {
+		return err
+	}
+	if wait {
+		p.waitAck(offset, deadline)
+	}
+	return nil
+}
+
+/**
+ * @Description: insert i, waiting if necessary for space to become available, but not for a replica,
+ *               whatever the options.
+ * @return int64 the offset of i, to pass to WaitAck
+ * @return error NilPointerError, or the error of the queue
+ */
+func (p *Primary) PutOffset(i interface{}) (int64, error) {
+	if i == nil {
+		return 0, NilPointerError
+	}
+	payload, err := p.codec.Encode(i)
+	if err != nil {
+		return 0, err
+	}
+	return p.insertPayload(payload, true, time.Time{})
+}
+
+// returns false if the queue is full, it doesn't wait for a replica.
+func (p *Primary) Offer(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	return p.insert(i, false, time.Time{}) == nil
+}
+
+func (p *Primary) Add(i interface{}) bool {
+	if p.Offer(i) {
+		return true
+	}
+	panic(IllegalStateError)
+}
+
+/**
+ * Inserts the specified element at the tail of this queue, waiting if
+ * necessary for space to become available, then for a replica with AckOne.
+ * returns the error of the queue only, see PutOffset.
+ */
+func (p *Primary) Put(i interface{}) error {
+	if i == nil {
+		return NilPointerError
+	}
+	return p.insert(i, true, time.Time{})
+}
+
+func (p *Primary) OfferTimout(i interface{}, timeout time.Duration) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	return p.insert(i, true, time.Now().Add(timeout)) == nil
+}
+
+/**
+ * @Description: offers all non-nil elements of c until the queue is full, then waits for a replica
+ *               to acknowledge the last one with AckOne.
+ * @receiver p
+ * @param c if c is nil, NilPointerError is returned
+ * @return bool indicates whether the queue has been changed or not when the func return
+ */
+func (p *Primary) AddAll(c Collection) (modified bool, err error) {
+	if c == nil {
+		return false, NilPointerError
+	}
+	last := int64(-1)
+	for _, e := range c.ToSlice() {
+		if e == nil {
+			err = NilPointerError
+			continue
+		}
+		payload, eerr := p.codec.Encode(e)
+		if eerr != nil {
+			err = eerr
+			break
+		}
+		offset, ierr := p.insertPayload(payload, false, time.Time{})
+		if ierr != nil {
+			err = ierr
+			break
+		}
+		last = offset
+		modified = true
+	}
+	if last >= 0 {
+		p.waitAck(last, time.Time{})
+	}
+	return
+}
+
+// stops the replication, then closes the queue.
+func (p *Primary) Close() error {
+	p.lock.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.done)
+		p.listener.Close()
+		for conn := range p.conns {
+			conn.Close()
+		}
+		p.ackCond.Broadcast()
+	}
+	p.lock.Unlock()
+	p.wg.Wait()
+	return p.DiskQueue.Close()
+}
+
+/**
+ * A Replica keeps a DiskQueue a copy of the queue of a Primary, reconnecting
+ * when the connection is lost. the queue can be read, Len, Peek, Range ..., but
+ * not modified: the insertions fail and the removals return nil until the
+ * replica is promoted.
+ */
+type Replica struct {
+	q    *DiskQueue
+	addr string
+
+	// guards conn, connected, err and stopping
+	lock      *sync.Mutex
+	conn      net.Conn
+	connected bool
+	err       error
+	stopping  bool
+	done      chan struct{}
+	stopped   chan struct{}
+}
+
+/**
+ * @Description: make q a replica of the primary listening on addr.
+ * @param q the queue, the records it has are kept if they agree with those of the primary
+ * @param addr the TCP address of the primary
+ * @return *Replica
+ */
+func NewReplica(q *DiskQueue, addr string) *Replica {
+	if q == nil {
+		panic(NilPointerError)
+	}
+	q.lock.Lock()
+	q.follower = true
+	q.lock.Unlock()
+	r := &Replica{q: q, addr: addr, lock: new(sync.Mutex), done: make(chan struct{}), stopped: make(chan struct{})}
+	go r.loop()
+	return r
+}
+
+// the replicated queue.
+func (r *Replica) Queue() *DiskQueue {
+	return r.q
+}
+
+// whether the replica is connected to the primary.
+func (r *Replica) Connected() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.connected
+}
+
+// the last error of the replication, the replica reconnects after it.
+func (r *Replica) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+func (r *Replica) setConn(conn net.Conn, err error) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err != nil {
+		r.err = err
+	}
+	r.conn = conn
+	r.connected = conn != nil
+	if r.stopping && conn != nil {
+		conn.Close()
+		return false
+	}
+	return true
+}
+
+func (r *Replica) loop() {
+	defer close(r.stopped)
+	backoff := 10 * time.Millisecond
+	for {
+		conn, err := net.DialTimeout("tcp", r.addr, replicaMaxBackoff)
+		if err == nil && r.setConn(conn, nil) {
+			err = r.follow(conn)
+			conn.Close()
+			backoff = 10 * time.Millisecond
+		}
+		r.setConn(nil, err)
+		if r.q.Err() != nil {
+			// the queue itself is broken, retrying won't help.
+			return
+		}
+		select {
+		case <-r.done:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > replicaMaxBackoff {
+			backoff = replicaMaxBackoff
+		}
+	}
+}
+
+// applies the frames of the primary, until the connection fails.
+func (r *Replica) follow(conn net.Conn) error {
+	q := r.q
+	q.lock.Lock()
+	var hello [len(replicationMagic) + 13]byte
+	copy(hello[:], replicationMagic)
+	hello[4] = replicationVersion
+	binary.BigEndian.PutUint64(hello[5:13], uint64(q.writeOffset))
+	binary.BigEndian.PutUint32(hello[13:17], q.lastCRC)
+	q.lock.Unlock()
+	if _, err := conn.Write(hello[:]); err != nil {
+		return err
+	}
+	br := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	appended := false
+	for {
+		var header [9]byte
+		if _, err := io.ReadFull(br, header[:]); err != nil {
+			return err
+		}
+		offset := int64(binary.BigEndian.Uint64(header[1:]))
+		var err error
+		switch header[0] {
+		case replicationReset:
+			err = q.resetAt(offset)
+		case replicationConsume:
+			err = q.consumeTo(offset)
+		case replicationAppend:
+			var sizes [8]byte
+			if _, err := io.ReadFull(br, sizes[:]); err != nil {
+				return err
+			}
+			payload := make([]byte, binary.BigEndian.Uint32(sizes[:4]))
+			if _, err := io.ReadFull(br, payload); err != nil {
+				return err
+			}
+			if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(sizes[4:]) {
+				return errReplicationProtocol
+			}
+			err = q.appendAt(offset, payload)
+			appended = true
+		default:
+			err = errReplicationProtocol
+		}
+		if err != nil {
+			return err
+		}
+		// acknowledges a batch once it's applied.
+		if appended && br.Buffered() == 0 {
+			q.lock.Lock()
+			writeReplicationFrame(w, replicationAck, q.writeOffset, nil)
+			q.lock.Unlock()
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			appended = false
+		}
+	}
+}
+
+// stops following the primary.
+func (r *Replica) stop() {
+	r.lock.Lock()
+	if !r.stopping {
+		r.stopping = true
+		close(r.done)
+		if r.conn != nil {
+			r.conn.Close()
+		}
+	}
+	r.lock.Unlock()
+	<-r.stopped
+}
+
+/**
+ * @Description: stop following the primary, and make the queue writable again,
+ *               to serve it with NewPrimary for example.
+ * @return *DiskQueue the queue
+ */
+func (r *Replica) Promote() *DiskQueue {
+	r.stop()
+	r.q.lock.Lock()
+	r.q.follower = false
+	r.q.lock.Unlock()
+	return r.q
+}
+
+// stops following the primary, then closes the queue.
+func (r *Replica) Close() error {
+	r.stop()
+	return r.q.Close()
+}
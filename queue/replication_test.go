@@ -0,0 +1,215 @@
+package queue
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+var _ BlockingQueue = (*Primary)(nil)
+
+// small segments, so that the primaries delete some.
+func openReplicatedQueue(t *testing.T, dir string) *DiskQueue {
+	return openTestDiskQueue(t, dir, 0, &DiskQueueOptions{SegmentSize: 64, Sync: SyncNever})
+}
+
+func startPrimary(t *testing.T, q *DiskQueue, opts *PrimaryOptions) *Primary {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewPrimary(q, l, opts)
+}
+
+// waits until the replica holds the same elements as want.
+func waitReplicated(t *testing.T, r *Replica, want BlockingQueue) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, expected := fmt.Sprint(r.Queue().ToSlice()), fmt.Sprint(want.ToSlice())
+		if got == expected {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("replica %s, expected %s, err %v", got, expected, r.Err())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReplication_Async(t *testing.T) {
+	dir := t.TempDir()
+	p := startPrimary(t, openReplicatedQueue(t, filepath.Join(dir, "primary")), nil)
+	defer p.Close()
+	r1 := NewReplica(openReplicatedQueue(t, filepath.Join(dir, "r1")), p.Addr().String())
+	defer r1.Close()
+
+	for k := 0; k < 100; k++ {
+		p.Put(k)
+	}
+	for k := 0; k < 30; k++ {
+		p.Take()
+	}
+	waitReplicated(t, r1, p)
+
+	// a replica joining late gets the log from the oldest segment of the primary.
+	r2 := NewReplica(openReplicatedQueue(t, filepath.Join(dir, "r2")), p.Addr().String())
+	defer r2.Close()
+	waitReplicated(t, r2, p)
+	if r2.Queue().Len() != 70 || p.Replicas() != 2 {
+		t.Fatalf("len %d, replicas %d", r2.Queue().Len(), p.Replicas())
+	}
+
+	// the queue of a replica is read-only.
+	if r1.Queue().Offer(1) || r1.Queue().Poll() != nil {
+		t.Fatal("a replica must not be modified")
+	}
+}
+
+func TestReplication_CatchUp(t *testing.T) {
+	dir := t.TempDir()
+	p := startPrimary(t, openReplicatedQueue(t, filepath.Join(dir, "primary")), nil)
+	defer p.Close()
+	r := NewReplica(openReplicatedQueue(t, filepath.Join(dir, "replica")), p.Addr().String())
+	for k := 0; k < 10; k++ {
+		p.Put(k)
+	}
+	waitReplicated(t, r, p)
+	r.Close()
+
+	// the primary goes on without the replica, it catches up from where it was.
+	for k := 10; k < 20; k++ {
+		p.Put(k)
+	}
+	p.Poll()
+	r = NewReplica(openReplicatedQueue(t, filepath.Join(dir, "replica")), p.Addr().String())
+	defer r.Close()
+	waitReplicated(t, r, p)
+}
+
+// connects to the primary without ever acknowledging anything.
+func dialSilentReplica(t *testing.T, p *Primary) net.Conn {
+	conn, err := net.Dial("tcp", p.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(time.Second); p.Replicas() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the primary didn't accept the connection")
+		}
+	}
+	return conn
+}
+
+func TestReplication_AckOne(t *testing.T) {
+	dir := t.TempDir()
+	p := startPrimary(t, openReplicatedQueue(t, filepath.Join(dir, "primary")), &PrimaryOptions{Ack: AckOne, AckTimeout: 5 * time.Second})
+	defer p.Close()
+	// the element is in the queue of the primary, there is no replica to wait for.
+	begin := time.Now()
+	if err := p.Put("alone"); err != nil || time.Since(begin) > time.Second {
+		t.Fatalf("expected the Put to succeed at once without replica, got %v after %v", err, time.Since(begin))
+	}
+	if !p.Offer("alone too") || p.Len() != 2 {
+		t.Fatal("Offer must succeed without replica")
+	}
+	offset, err := p.PutOffset("alone again")
+	if err != nil || offset != 2 {
+		t.Fatalf("PutOffset: %d %v", offset, err)
+	}
+	if err := p.WaitAck(offset, 10*time.Millisecond); err != TimeoutError {
+		t.Fatalf("expected TimeoutError without replica, got %v", err)
+	}
+
+	// a replica which doesn't answer: Offer doesn't wait, OfferTimout waits within its timeout only.
+	silent := dialSilentReplica(t, p)
+	begin = time.Now()
+	if !p.Offer("unacked") || time.Since(begin) > time.Second {
+		t.Fatalf("Offer waited for %v", time.Since(begin))
+	}
+	begin = time.Now()
+	if !p.OfferTimout("unacked too", 30*time.Millisecond) || time.Since(begin) > time.Second {
+		t.Fatalf("OfferTimout waited for %v", time.Since(begin))
+	}
+	silent.Close()
+
+	r := NewReplica(openReplicatedQueue(t, filepath.Join(dir, "replica")), p.Addr().String())
+	defer r.Close()
+	waitReplicated(t, r, p)
+	for k := 0; k < 20; k++ {
+		if err := p.Put(k); err != nil {
+			t.Fatal(err)
+		}
+		// acknowledged means appended by the replica.
+		if n := r.Queue().Len(); n != k+6 {
+			t.Fatalf("replica len %d after put %d", n, k)
+		}
+	}
+	if _, err := p.AddAll(p.DiskQueue); err != nil || r.Queue().Len() != 50 {
+		t.Fatalf("AddAll %v, replica len %d", err, r.Queue().Len())
+	}
+	if err := p.WaitAck(offset, time.Second); err != nil {
+		t.Fatalf("the replica has the element, got %v", err)
+	}
+}
+
+func TestReplication_AckTimeout(t *testing.T) {
+	p := startPrimary(t, openReplicatedQueue(t, t.TempDir()), &PrimaryOptions{Ack: AckOne, AckTimeout: 50 * time.Millisecond})
+	defer p.Close()
+	silent := dialSilentReplica(t, p)
+	defer silent.Close()
+	begin := time.Now()
+	if err := p.Put("x"); err != nil || time.Since(begin) < 50*time.Millisecond {
+		t.Fatalf("expected the Put to wait for the replica, then succeed, got %v after %v", err, time.Since(begin))
+	}
+}
+
+func TestReplication_Promote(t *testing.T) {
+	dir := t.TempDir()
+	p := startPrimary(t, openReplicatedQueue(t, filepath.Join(dir, "a")), &PrimaryOptions{Ack: AckOne})
+	r := NewReplica(openReplicatedQueue(t, filepath.Join(dir, "b")), p.Addr().String())
+	for k := 0; k < 10; k++ {
+		p.Put(k)
+	}
+	p.Take()
+	waitReplicated(t, r, p)
+	p.Close()
+
+	// b takes over, a comes back as its replica.
+	b := startPrimary(t, r.Promote(), nil)
+	defer b.Close()
+	if x := b.Take(); x != 1 {
+		t.Fatalf("expected 1, got %v", x)
+	}
+	b.Put("after fail-over")
+	a := NewReplica(openReplicatedQueue(t, filepath.Join(dir, "a")), b.Addr().String())
+	defer a.Close()
+	waitReplicated(t, a, b)
+	if s := fmt.Sprint(b.ToSlice()); s != "[2 3 4 5 6 7 8 9 after fail-over]" {
+		t.Fatalf("promoted queue %s", s)
+	}
+}
+
+func TestReplication_Diverged(t *testing.T) {
+	dir := t.TempDir()
+	p := startPrimary(t, openReplicatedQueue(t, filepath.Join(dir, "primary")), nil)
+	defer p.Close()
+	p.Put("x")
+	p.Put("y")
+
+	// a log of the same length with other records is reset.
+	other := openReplicatedQueue(t, filepath.Join(dir, "replica"))
+	other.Put("x")
+	other.Put("z")
+	r := NewReplica(other, p.Addr().String())
+	defer r.Close()
+	waitReplicated(t, r, p)
+
+	if err := r.Queue().Put(1); err != IllegalStateError {
+		t.Fatalf("expected IllegalStateError, got %v", err)
+	}
+}
@@ -0,0 +1,138 @@
+package resp
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+)
+
+const (
+	// the largest bulk string accepted, as in redis
+	maxBulkLen = 512 << 20
+	// the largest number of arguments of a command
+	maxArgs = 1 << 20
+	// the room reserved up front for the arguments and the bulk strings, beyond it they grow as the bytes arrive,
+	// so that a header alone can't make the server allocate maxBulkLen bytes
+	preallocArgs = 16
+	preallocBulk = 64 << 10
+)
+
+// a malformed request, the connection is closed after replying with it.
+type protocolError string
+
+func (e protocolError) Error() string {
+	return "Protocol error: " + string(e)
+}
+
+// reads a line ending with \r\n, without it.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		return nil, protocolError("too big inline request")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, protocolError("expected '\\r\\n'")
+	}
+	return line[:len(line)-2], nil
+}
+
+// reads the integer after the type byte of a header line such as *3 or $5, between 0 and max.
+func readHeader(r *bufio.Reader, prefix byte, max int) (int, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return 0, err
+	}
+	if len(line) == 0 || line[0] != prefix {
+		return 0, protocolError("expected '" + string(prefix) + "', got '" + string(line) + "'")
+	}
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n < 0 || n > max {
+		return 0, protocolError("invalid length")
+	}
+	return n, nil
+}
+
+/**
+ * @Description: read a command, an array of bulk strings, or an inline command of words separated by spaces.
+ * @return [][]byte the command and its arguments, empty for an empty line
+ * @return error a protocolError for a malformed request, or the error of the connection
+ */
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if first[0] != '*' {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.Fields(line), nil
+	}
+	n, err := readHeader(r, '*', maxArgs)
+	if err != nil {
+		return nil, err
+	}
+	args := make([][]byte, 0, minInt(n, preallocArgs))
+	for k := 0; k < n; k++ {
+		size, err := readHeader(r, '$', maxBulkLen)
+		if err != nil {
+			return nil, err
+		}
+		var b bytes.Buffer
+		b.Grow(minInt(size+2, preallocBulk))
+		if _, err := io.CopyN(&b, r, int64(size+2)); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		buf := b.Bytes()
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, protocolError("expected '\\r\\n'")
+		}
+		args = append(args, buf[:size])
+	}
+	return args, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// the replies, written to a buffered connection.
+
+func writeSimple(w *bufio.Writer, s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+func writeError(w *bufio.Writer, msg string) {
+	w.WriteString("-" + msg + "\r\n")
+}
+
+func writeInt(w *bufio.Writer, n int) {
+	w.WriteString(":" + strconv.Itoa(n) + "\r\n")
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+	w.WriteString("$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n")
+}
+
+func writeNilBulk(w *bufio.Writer) {
+	w.WriteString("$-1\r\n")
+}
+
+func writeArrayHeader(w *bufio.Writer, n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
+
+func writeNilArray(w *bufio.Writer) {
+	w.WriteString("*-1\r\n")
+}
@@ -0,0 +1,422 @@
+/**
+ * Package resp serves named queues over the redis protocol (RESP2), so that
+ * services written in other languages can use them with any redis client.
+ */
+package resp
+
+import (
+	"bufio"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+	"github.com/torchcc/data-structure/queue"
+)
+
+/**
+ * A Server maps the keys to LinkedBlockingDeques and serves the list commands
+ * of redis on them: LPUSH, RPUSH, LPOP, RPOP, BLPOP, BRPOP, LLEN, LRANGE and
+ * DEL, plus PING and QUIT. the replies are those of redis.
+ *
+ * <p>A queue is created by the first push to its key, with the capacity of the
+ * server, and deleted once it's empty, as redis does with lists. a push which
+ * doesn't fit in a bounded queue is rejected as a whole with an error reply.
+ *
+ * <p>The commands are applied one at a time, like in redis, so a push of
+ * several values and a pop over several keys are atomic.
+ */
+type Server struct {
+	capacity int
+
+	// guards all access to the queues
+	lock   *sync.Mutex
+	queues map[string]*queue.LinkedBlockingDeque
+	// closed and replaced on every push, to wake up the blocked pops
+	pushed chan struct{}
+
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	done      chan struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+/**
+ * @Description: create a Server.
+ * @param capacity the capacity of the queues, 0 means math.MaxInt32,
+ *        if capacity is less than 0, IllegalArgumentError will be panic
+ * @return *Server
+ */
+func NewServer(capacity int) *Server {
+	if capacity < 0 {
+		panic(IllegalArgumentError)
+	}
+	if capacity == 0 {
+		capacity = math.MaxInt32
+	}
+	return &Server{
+		capacity:  capacity,
+		lock:      new(sync.Mutex),
+		queues:    make(map[string]*queue.LinkedBlockingDeque),
+		pushed:    make(chan struct{}),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// listens on the TCP address addr and serves the connections, see Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+/**
+ * @Description: accept the connections of l and serve each one in its own goroutine, until Close.
+ * @return error IllegalStateError once the server is closed, or the error of Accept
+ */
+func (s *Server) Serve(l net.Listener) error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		l.Close()
+		return IllegalStateError
+	}
+	s.listeners[l] = struct{}{}
+	s.lock.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.lock.Lock()
+			delete(s.listeners, l)
+			closed := s.closed
+			s.lock.Unlock()
+			if closed {
+				return IllegalStateError
+			}
+			return err
+		}
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.lock.Unlock()
+		go s.serveConn(conn)
+	}
+}
+
+// closes the listeners and the connections, and waits for the connections to be done.
+func (s *Server) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, conn)
+		s.lock.Unlock()
+		conn.Close()
+		s.wg.Done()
+	}()
+	c := &client{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+	for {
+		args, err := readCommand(c.r)
+		if err != nil {
+			if perr, ok := err.(protocolError); ok {
+				writeError(c.w, "ERR "+perr.Error())
+				c.w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.execute(c, args)
+		// pipelined commands are answered together.
+		if c.r.Buffered() == 0 || quit {
+			if c.w.Flush() != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// a connection being served.
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+/**
+ * @Description: watch the connection while a command blocks, so that a pop doesn't take an element
+ *               for a client which went away. the reader must not be used until stop returns.
+ * @return gone closed once the client has closed the connection
+ * @return stop stops watching
+ */
+func (c *client) watch() (gone <-chan struct{}, stop func()) {
+	ch := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// returns once the client sends something, closes the connection, or stop is called.
+		if _, err := c.r.Peek(1); err != nil {
+			if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+				close(ch)
+			}
+		}
+	}()
+	return ch, func() {
+		c.conn.SetReadDeadline(time.Unix(1, 0))
+		<-done
+		c.conn.SetReadDeadline(time.Time{})
+	}
+}
+
+// runs a command and writes its reply, returns true for QUIT.
+func (s *Server) execute(c *client, args [][]byte) bool {
+	name := strings.ToUpper(string(args[0]))
+	cmd, ok := commands[name]
+	if !ok {
+		writeError(c.w, "ERR unknown command '"+string(args[0])+"'")
+		return false
+	}
+	argc := len(args) - 1
+	if argc < cmd.minArgs || cmd.maxArgs >= 0 && argc > cmd.maxArgs {
+		writeError(c.w, "ERR wrong number of arguments for '"+strings.ToLower(name)+"' command")
+		return false
+	}
+	cmd.run(s, c, args[1:])
+	return name == "QUIT"
+}
+
+type command struct {
+	// the number of arguments, maxArgs < 0 means no limit
+	minArgs, maxArgs int
+	run              func(s *Server, c *client, args [][]byte)
+}
+
+var commands = map[string]command{
+	"PING":   {0, 1, func(s *Server, c *client, args [][]byte) { s.ping(c.w, args) }},
+	"QUIT":   {0, 0, func(s *Server, c *client, args [][]byte) { writeSimple(c.w, "OK") }},
+	"LPUSH":  {2, -1, func(s *Server, c *client, args [][]byte) { s.push(c.w, args, true) }},
+	"RPUSH":  {2, -1, func(s *Server, c *client, args [][]byte) { s.push(c.w, args, false) }},
+	"LPOP":   {1, 1, func(s *Server, c *client, args [][]byte) { s.pop(c.w, args, true) }},
+	"RPOP":   {1, 1, func(s *Server, c *client, args [][]byte) { s.pop(c.w, args, false) }},
+	"BLPOP":  {2, -1, func(s *Server, c *client, args [][]byte) { s.blockingPop(c, args, true) }},
+	"BRPOP":  {2, -1, func(s *Server, c *client, args [][]byte) { s.blockingPop(c, args, false) }},
+	"LLEN":   {1, 1, func(s *Server, c *client, args [][]byte) { s.llen(c.w, args) }},
+	"LRANGE": {3, 3, func(s *Server, c *client, args [][]byte) { s.lrange(c.w, args) }},
+	"DEL":    {1, -1, func(s *Server, c *client, args [][]byte) { s.del(c.w, args) }},
+}
+
+func (s *Server) ping(w *bufio.Writer, args [][]byte) {
+	if len(args) == 1 {
+		writeBulk(w, string(args[0]))
+		return
+	}
+	writeSimple(w, "PONG")
+}
+
+// helper functions below, they should be called with lock held.
+
+// takes an element from one end of the queue of key, and deletes the queue once it's empty.
+func (s *Server) poll(key string, first bool) (string, bool) {
+	q, ok := s.queues[key]
+	if !ok {
+		return "", false
+	}
+	var x interface{}
+	if first {
+		x = q.PollFirst()
+	} else {
+		x = q.PollLast()
+	}
+	if q.IsEmpty() {
+		delete(s.queues, key)
+	}
+	if x == nil {
+		return "", false
+	}
+	return x.(string), true
+}
+
+// LPUSH/RPUSH key value [value ...]
+func (s *Server) push(w *bufio.Writer, args [][]byte, first bool) {
+	key := string(args[0])
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	q, ok := s.queues[key]
+	if !ok {
+		q = queue.NewLinkedBlockingDeque(s.capacity)
+	}
+	if q.RemainingCapacity() < len(args)-1 {
+		writeError(w, "ERR queue is full")
+		return
+	}
+	for _, v := range args[1:] {
+		if first {
+			q.OfferFirst(string(v))
+		} else {
+			q.OfferLast(string(v))
+		}
+	}
+	s.queues[key] = q
+	close(s.pushed)
+	s.pushed = make(chan struct{})
+	writeInt(w, q.Len())
+}
+
+// LPOP/RPOP key
+func (s *Server) pop(w *bufio.Writer, args [][]byte, first bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if x, ok := s.poll(string(args[0]), first); ok {
+		writeBulk(w, x)
+		return
+	}
+	writeNilBulk(w)
+}
+
+// BLPOP/BRPOP key [key ...] timeout, the timeout in seconds, 0 waits forever.
+func (s *Server) blockingPop(c *client, args [][]byte, first bool) {
+	w := c.w
+	seconds, err := strconv.ParseFloat(string(args[len(args)-1]), 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		writeError(w, "ERR timeout is not a float or out of range")
+		return
+	}
+	if seconds < 0 {
+		writeError(w, "ERR timeout is negative")
+		return
+	}
+	var expired <-chan time.Time
+	if seconds > 0 {
+		timer := time.NewTimer(time.Duration(seconds * float64(time.Second)))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	keys := args[:len(args)-1]
+	var gone <-chan struct{}
+	for {
+		s.lock.Lock()
+		for _, key := range keys {
+			if x, ok := s.poll(string(key), first); ok {
+				s.lock.Unlock()
+				writeArrayHeader(w, 2)
+				writeBulk(w, string(key))
+				writeBulk(w, x)
+				return
+			}
+		}
+		pushed := s.pushed
+		s.lock.Unlock()
+		if gone == nil {
+			// the replies to the commands pipelined before must not wait for this one.
+			if w.Flush() != nil {
+				return
+			}
+			var stop func()
+			gone, stop = c.watch()
+			defer stop()
+		}
+		select {
+		case <-gone:
+			return
+		case <-pushed:
+		case <-expired:
+			writeNilArray(w)
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// LLEN key
+func (s *Server) llen(w *bufio.Writer, args [][]byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if q, ok := s.queues[string(args[0])]; ok {
+		writeInt(w, q.Len())
+		return
+	}
+	writeInt(w, 0)
+}
+
+// LRANGE key start stop, negative indexes count from the tail.
+func (s *Server) lrange(w *bufio.Writer, args [][]byte) {
+	start, err1 := strconv.Atoi(string(args[1]))
+	stop, err2 := strconv.Atoi(string(args[2]))
+	if err1 != nil || err2 != nil {
+		writeError(w, "ERR value is not an integer or out of range")
+		return
+	}
+	s.lock.Lock()
+	var elements []interface{}
+	if q, ok := s.queues[string(args[0])]; ok {
+		elements = q.ToSlice()
+	}
+	s.lock.Unlock()
+	n := len(elements)
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= n {
+		stop = n - 1
+	}
+	if start > stop {
+		writeArrayHeader(w, 0)
+		return
+	}
+	writeArrayHeader(w, stop-start+1)
+	for _, x := range elements[start : stop+1] {
+		writeBulk(w, x.(string))
+	}
+}
+
+// DEL key [key ...]
+func (s *Server) del(w *bufio.Writer, args [][]byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	deleted := 0
+	for _, key := range args {
+		if _, ok := s.queues[string(key)]; ok {
+			delete(s.queues, string(key))
+			deleted++
+		}
+	}
+	writeInt(w, deleted)
+}
@@ -0,0 +1,217 @@
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// a minimal redis client.
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func startServer(t *testing.T, capacity int) (*Server, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(capacity)
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	return s, l.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *testClient {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testClient{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *testClient) send(args ...string) {
+	msg := "*" + strconv.Itoa(len(args)) + "\r\n"
+	for _, a := range args {
+		msg += "$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n"
+	}
+	c.conn.Write([]byte(msg))
+}
+
+// reads a reply: a string, an int, nil, an error, or a []interface{} of them.
+func (c *testClient) read() interface{} {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '+':
+		return line[1:]
+	case '-':
+		return errors.New(line[1:])
+	case ':':
+		n, _ := strconv.Atoi(line[1:])
+		return n
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n+2)
+		io.ReadFull(c.r, buf)
+		return string(buf[:n])
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		a := make([]interface{}, n)
+		for k := range a {
+			a[k] = c.read()
+		}
+		return a
+	}
+	return fmt.Errorf("bad reply %q", line)
+}
+
+func (c *testClient) do(args ...string) interface{} {
+	c.send(args...)
+	return c.read()
+}
+
+func expect(t *testing.T, got, want interface{}) {
+	t.Helper()
+	if e, ok := got.(error); ok {
+		got = "error: " + e.Error()
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %#v, got %#v", want, got)
+	}
+}
+
+func TestServer_Lists(t *testing.T) {
+	_, addr := startServer(t, 0)
+	c := dial(t, addr)
+	expect(t, c.do("PING"), "PONG")
+	expect(t, c.do("rpush", "q", "a", "b", "c"), 3)
+	expect(t, c.do("LPUSH", "q", "z"), 4)
+	expect(t, c.do("LRANGE", "q", "0", "-1"), []interface{}{"z", "a", "b", "c"})
+	expect(t, c.do("LRANGE", "q", "1", "2"), []interface{}{"a", "b"})
+	expect(t, c.do("LRANGE", "q", "5", "10"), []interface{}{})
+	expect(t, c.do("LLEN", "q"), 4)
+	expect(t, c.do("LPOP", "q"), "z")
+	expect(t, c.do("RPOP", "q"), "c")
+	expect(t, c.do("DEL", "q", "missing"), 1)
+	expect(t, c.do("LLEN", "q"), 0)
+	expect(t, c.do("LPOP", "q"), nil)
+	expect(t, c.do("LPUSH", "q"), "error: ERR wrong number of arguments for 'lpush' command")
+	expect(t, c.do("FLUSHALL"), "error: ERR unknown command 'FLUSHALL'")
+	expect(t, c.do("BLPOP", "q", "x"), "error: ERR timeout is not a float or out of range")
+
+	// inline and pipelined commands.
+	c.conn.Write([]byte("RPUSH p 1\r\nRPUSH p 2\r\nLLEN p\r\n"))
+	expect(t, c.read(), 1)
+	expect(t, c.read(), 2)
+	expect(t, c.read(), 2)
+	expect(t, c.do("QUIT"), "OK")
+	expect(t, c.read(), "error: EOF")
+}
+
+func TestServer_Capacity(t *testing.T) {
+	_, addr := startServer(t, 2)
+	c := dial(t, addr)
+	expect(t, c.do("RPUSH", "q", "a", "b", "c"), "error: ERR queue is full")
+	expect(t, c.do("RPUSH", "q", "a", "b"), 2)
+	expect(t, c.do("RPUSH", "q", "c"), "error: ERR queue is full")
+	expect(t, c.do("LRANGE", "q", "0", "-1"), []interface{}{"a", "b"})
+}
+
+func TestServer_BlockingPop(t *testing.T) {
+	_, addr := startServer(t, 0)
+	consumer, producer := dial(t, addr), dial(t, addr)
+
+	expect(t, consumer.do("BRPOP", "q1", "q2", "0.05"), nil)
+
+	consumer.send("BLPOP", "q1", "q2", "0")
+	time.Sleep(20 * time.Millisecond)
+	expect(t, producer.do("RPUSH", "q2", "x", "y"), 2)
+	expect(t, consumer.read(), []interface{}{"q2", "x"})
+	expect(t, consumer.do("BRPOP", "q1", "q2", "1"), []interface{}{"q2", "y"})
+
+	// a client going away while blocked doesn't take an element.
+	gone := dial(t, addr)
+	gone.send("BLPOP", "q3", "0")
+	time.Sleep(20 * time.Millisecond)
+	gone.conn.Close()
+	time.Sleep(20 * time.Millisecond)
+	expect(t, producer.do("RPUSH", "q3", "z"), 1)
+	expect(t, producer.do("LPOP", "q3"), "z")
+}
+
+func TestServer_Close(t *testing.T) {
+	s, addr := startServer(t, 0)
+	c := dial(t, addr)
+	c.send("BLPOP", "q", "0")
+	time.Sleep(20 * time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		s.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Close must not wait for the blocked pops")
+	}
+	expect(t, c.read(), "error: EOF")
+}
+
+func TestServer_MalformedHeaders(t *testing.T) {
+	_, addr := startServer(t, 0)
+	for _, request := range []string{
+		"*-1\r\n",
+		"*-5\r\n",
+		"*1\r\n$-1\r\n",
+		"*1\r\n$-5\r\n",
+		"*99999999999999999999\r\n",
+		"*2000000\r\n",
+		"*1\r\n$600000000\r\n",
+	} {
+		c := dial(t, addr)
+		c.conn.Write([]byte(request))
+		if reply, ok := c.read().(error); !ok || reply.Error() != "ERR Protocol error: invalid length" {
+			t.Fatalf("%q: expected a protocol error, got %v", request, reply)
+		}
+		expect(t, c.read(), "error: EOF")
+	}
+	// the largest headers allowed are not allocated before the bytes arrive.
+	for _, request := range []string{"*1048576\r\n", "*1\r\n$536870912\r\nabc"} {
+		c := dial(t, addr)
+		c.conn.Write([]byte(request))
+		c.conn.Close()
+	}
+	c := dial(t, addr)
+	c.conn.Write([]byte("*0\r\n"))
+	expect(t, c.do("PING"), "PONG")
+}
+
+func TestServer_PipelinedBlockingPop(t *testing.T) {
+	_, addr := startServer(t, 0)
+	c, producer := dial(t, addr), dial(t, addr)
+	c.conn.Write([]byte("RPUSH p 1\r\nBLPOP q 0\r\n"))
+	// the reply to RPUSH comes while BLPOP blocks.
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	expect(t, c.read(), 1)
+	c.conn.SetReadDeadline(time.Time{})
+	expect(t, producer.do("RPUSH", "q", "x"), 1)
+	expect(t, c.read(), []interface{}{"q", "x"})
+}
@@ -0,0 +1,391 @@
+package queue
+
+import (
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+// ClaimStrategy tells how many goroutines produce into, and consume from, a RingBuffer concurrently.
+type ClaimStrategy int
+
+const (
+	// single producer, single consumer
+	SPSC ClaimStrategy = iota
+	// multiple producers, single consumer
+	MPSC
+	// multiple producers, multiple consumers
+	MPMC
+)
+
+func (c ClaimStrategy) multiProducer() bool {
+	return c != SPSC
+}
+
+func (c ClaimStrategy) multiConsumer() bool {
+	return c == MPMC
+}
+
+/**
+ * A pre-allocated ring buffer in the style of the LMAX Disruptor.
+ *
+ * <p>Producers claim sequences with Next/TryNext, write the slots with Set (or
+ * mutate the pre-allocated events returned by Get), then Publish them. claiming
+ * and publishing a range of sequences at once is the way to batch.
+ *
+ * <p>Consumers never take anything out of the buffer, they wait on a
+ * SequenceBarrier for a sequence to be published, read the slot, then move
+ * their own Sequence forward. producers never wrap past the gating sequences,
+ * which are usually the sequences of the last consumers of a pipeline, see
+ * AddGatingSequences. A consumer stage depending on another one waits on a
+ * barrier created with the sequence of the stage it depends on, see NewBarrier
+ * and BatchEventProcessor.
+ *
+ * <p>For single producer claim strategies, Next, TryNext and the Publish
+ * methods must not be called by more than one goroutine at a time.
+ */
+type RingBuffer struct {
+	entries   []interface{}
+	indexMask int64
+	claim     ClaimStrategy
+	wait      WaitStrategy
+	sequencer sequencer
+}
+
+/**
+ * @Description: create a RingBuffer.
+ * @param size the number of slots, it must be a power of 2, or IllegalArgumentError will be panic
+ * @param claim the claim strategy, only its producer side matters for the RingBuffer itself
+ * @param wait the wait strategy of the consumers, nil means a BlockingWaitStrategy
+ * @param factory if not nil, it's called to pre-allocate the event of every slot
+ * @return *RingBuffer
+ */
+func NewRingBuffer(size int, claim ClaimStrategy, wait WaitStrategy, factory func() interface{}) *RingBuffer {
+	if size < 1 || size&(size-1) != 0 {
+		panic(IllegalArgumentError)
+	}
+	if wait == nil {
+		wait = NewBlockingWaitStrategy()
+	}
+	r := &RingBuffer{
+		entries:   make([]interface{}, size),
+		indexMask: int64(size - 1),
+		claim:     claim,
+		wait:      wait,
+	}
+	if claim.multiProducer() {
+		r.sequencer = newMultiProducerSequencer(int64(size), wait)
+	} else {
+		r.sequencer = newSingleProducerSequencer(int64(size), wait)
+	}
+	if factory != nil {
+		for i := range r.entries {
+			r.entries[i] = factory()
+		}
+	}
+	return r
+}
+
+func (r *RingBuffer) Size() int {
+	return len(r.entries)
+}
+
+// the highest sequence claimed by producers, for a single producer RingBuffer it's also the highest published one.
+func (r *RingBuffer) Cursor() int64 {
+	return r.sequencer.cursor().Get()
+}
+
+/**
+ * @Description: claim the next sequence, spinning while the buffer is full.
+ * @return int64 the claimed sequence, it must be published afterwards
+ */
+func (r *RingBuffer) Next() int64 {
+	return r.sequencer.next(1)
+}
+
+/**
+ * @Description: claim the next n sequences, spinning while there is no room for them.
+ * @param n must be between 1 and the size of the buffer, or IllegalArgumentError will be panic
+ * @return int64 the highest claimed sequence, the claimed ones are hi-n+1 .. hi
+ */
+func (r *RingBuffer) NextN(n int) int64 {
+	if n < 1 || n > len(r.entries) {
+		panic(IllegalArgumentError)
+	}
+	return r.sequencer.next(int64(n))
+}
+
+/**
+ * @Description: claim the next sequence if the buffer is not full.
+ * @return bool false if the buffer is full
+ */
+func (r *RingBuffer) TryNext() (int64, bool) {
+	return r.sequencer.tryNext(1)
+}
+
+/**
+ * @Description: claim the next n sequences if there is room for them.
+ * @param n must be between 1 and the size of the buffer, or IllegalArgumentError will be panic
+ * @return int64 the highest claimed sequence
+ * @return bool false if there is not enough room
+ */
+func (r *RingBuffer) TryNextN(n int) (int64, bool) {
+	if n < 1 || n > len(r.entries) {
+		panic(IllegalArgumentError)
+	}
+	return r.sequencer.tryNext(int64(n))
+}
+
+// returns the event in the slot of sequence.
+func (r *RingBuffer) Get(sequence int64) interface{} {
+	return r.entries[sequence&r.indexMask]
+}
+
+// stores the event in the slot of sequence, the sequence must have been claimed and not yet published.
+func (r *RingBuffer) Set(sequence int64, event interface{}) {
+	r.entries[sequence&r.indexMask] = event
+}
+
+func (r *RingBuffer) Publish(sequence int64) {
+	r.sequencer.publish(sequence, sequence)
+}
+
+// publishes the sequences from lo to hi, inclusive.
+func (r *RingBuffer) PublishRange(lo, hi int64) {
+	r.sequencer.publish(lo, hi)
+}
+
+// claims a sequence, stores the event into it and publishes it.
+func (r *RingBuffer) PublishEvent(event interface{}) {
+	sequence := r.Next()
+	r.Set(sequence, event)
+	r.Publish(sequence)
+}
+
+// like PublishEvent, but returns false instead of spinning if the buffer is full.
+func (r *RingBuffer) TryPublishEvent(event interface{}) bool {
+	sequence, ok := r.TryNext()
+	if !ok {
+		return false
+	}
+	r.Set(sequence, event)
+	r.Publish(sequence)
+	return true
+}
+
+/**
+ * @Description: publish a batch of events with a single claim and a single publish.
+ * @param events its length must not exceed the size of the buffer, or IllegalArgumentError will be panic
+ */
+func (r *RingBuffer) PublishEvents(events []interface{}) {
+	if len(events) == 0 {
+		return
+	}
+	hi := r.NextN(len(events))
+	lo := hi - int64(len(events)) + 1
+	for i, e := range events {
+		r.Set(lo+int64(i), e)
+	}
+	r.PublishRange(lo, hi)
+}
+
+func (r *RingBuffer) IsPublished(sequence int64) bool {
+	return r.sequencer.isAvailable(sequence)
+}
+
+func (r *RingBuffer) RemainingCapacity() int64 {
+	return r.sequencer.remainingCapacity()
+}
+
+/**
+ * @Description: add sequences producers must not wrap past, they are set to the current cursor.
+ *               the sequences of the last consumers of every pipeline should be added.
+ */
+func (r *RingBuffer) AddGatingSequences(sequences ...*Sequence) {
+	r.sequencer.addGatingSequences(sequences...)
+}
+
+func (r *RingBuffer) RemoveGatingSequence(sequence *Sequence) bool {
+	return r.sequencer.removeGatingSequence(sequence)
+}
+
+/**
+ * @Description: create a barrier consumers wait on before they read a sequence.
+ * @param dependents the sequences of the consumers that must have processed a sequence before it's
+ *        available through the barrier, with no dependents a sequence is available once it's published.
+ * @return *SequenceBarrier
+ */
+func (r *RingBuffer) NewBarrier(dependents ...*Sequence) *SequenceBarrier {
+	return &SequenceBarrier{
+		sequencer:  r.sequencer,
+		wait:       r.wait,
+		cursor:     r.sequencer.cursor(),
+		dependents: dependents,
+	}
+}
+
+/**
+ * A SequenceBarrier tracks the cursor of a RingBuffer and the sequences of the
+ * consumers a consumer depends on, it's how the stages of a pipeline are chained.
+ */
+type SequenceBarrier struct {
+	sequencer  sequencer
+	wait       WaitStrategy
+	cursor     *Sequence
+	dependents []*Sequence
+	alerted    int32
+}
+
+/**
+ * @Description: wait for sequence to become available for consuming.
+ * @return int64 the highest available sequence, it may be greater than sequence, this is what allows batching.
+ * @return error AlertError if the barrier has been alerted
+ */
+func (b *SequenceBarrier) WaitFor(sequence int64) (int64, error) {
+	return b.waitFor(sequence, time.Time{})
+}
+
+/**
+ * @Description: like WaitFor, but gives up with TimeoutError after timeout.
+ */
+func (b *SequenceBarrier) WaitForTimeout(sequence int64, timeout time.Duration) (int64, error) {
+	return b.waitFor(sequence, time.Now().Add(timeout))
+}
+
+func (b *SequenceBarrier) waitFor(sequence int64, deadline time.Time) (int64, error) {
+	for {
+		if err := b.CheckAlert(); err != nil {
+			return InitialSequence, err
+		}
+		available, err := b.wait.WaitFor(sequence, b.cursor, b.dependents, b, deadline)
+		if err != nil {
+			return available, err
+		}
+		// with multiple producers, claimed sequences may not have been published yet.
+		if highest := b.sequencer.highestPublished(sequence, available); highest >= sequence {
+			return highest, nil
+		}
+		if err := checkWait(b, deadline); err != nil {
+			return InitialSequence, err
+		}
+		runtime.Gosched()
+	}
+}
+
+// the highest sequence that may be available through the barrier, without waiting.
+func (b *SequenceBarrier) Cursor() int64 {
+	return minimumSequence(b.dependents, b.cursor.Get())
+}
+
+// wakes up the consumers waiting on the barrier, they'll get AlertError until the alert is cleared.
+func (b *SequenceBarrier) Alert() {
+	atomic.StoreInt32(&b.alerted, 1)
+	b.wait.SignalAllWhenBlocking()
+}
+
+func (b *SequenceBarrier) ClearAlert() {
+	atomic.StoreInt32(&b.alerted, 0)
+}
+
+func (b *SequenceBarrier) IsAlerted() bool {
+	return atomic.LoadInt32(&b.alerted) == 1
+}
+
+// returns AlertError if the barrier has been alerted.
+func (b *SequenceBarrier) CheckAlert() error {
+	if b.IsAlerted() {
+		return AlertError
+	}
+	return nil
+}
+
+// EventHandler is called by a BatchEventProcessor for every published event,
+// endOfBatch is true for the last event of the batch available at that time.
+type EventHandler func(event interface{}, sequence int64, endOfBatch bool)
+
+/**
+ * A BatchEventProcessor is a consumer stage of a RingBuffer, it runs a loop
+ * feeding every event available through its barrier to the handler, in batches,
+ * and moves its own Sequence forward after every batch.
+ *
+ * <p>To chain stages, create the barrier of a stage with the Sequence of the
+ * stage(s) it depends on, and add the Sequence of the last stage(s) to the
+ * gating sequences of the RingBuffer.
+ */
+type BatchEventProcessor struct {
+	ringBuffer *RingBuffer
+	barrier    *SequenceBarrier
+	handler    EventHandler
+	sequence   *Sequence
+	// processorIdle, processorRunning or processorHalted
+	state int32
+}
+
+const (
+	processorIdle int32 = iota
+	processorRunning
+	// Halt was called, while running or before Run
+	processorHalted
+)
+
+func NewBatchEventProcessor(ringBuffer *RingBuffer, barrier *SequenceBarrier, handler EventHandler) *BatchEventProcessor {
+	if ringBuffer == nil || barrier == nil || handler == nil {
+		panic(NilPointerError)
+	}
+	return &BatchEventProcessor{
+		ringBuffer: ringBuffer,
+		barrier:    barrier,
+		handler:    handler,
+		sequence:   NewSequence(InitialSequence),
+	}
+}
+
+// the sequence of the last event handled.
+func (p *BatchEventProcessor) Sequence() *Sequence {
+	return p.sequence
+}
+
+/**
+ * @Description: run the processing loop in the calling goroutine, until Halt is called.
+ *               if Halt was called before, it returns at once. the processor may be run again afterwards.
+ *               it panics IllegalStateError if the processor is already running.
+ */
+func (p *BatchEventProcessor) Run() {
+	if !atomic.CompareAndSwapInt32(&p.state, processorIdle, processorRunning) {
+		if atomic.CompareAndSwapInt32(&p.state, processorHalted, processorIdle) {
+			return
+		}
+		panic(IllegalStateError)
+	}
+	p.barrier.ClearAlert()
+	// Halt sets the state before alerting, a Halt whose alert was just cleared is seen here.
+	if atomic.CompareAndSwapInt32(&p.state, processorHalted, processorIdle) {
+		return
+	}
+	next := p.sequence.Get() + 1
+	for {
+		available, err := p.barrier.WaitFor(next)
+		if err != nil {
+			if atomic.CompareAndSwapInt32(&p.state, processorHalted, processorIdle) {
+				return
+			}
+			continue
+		}
+		for ; next <= available; next++ {
+			p.handler(p.ringBuffer.Get(next), next, next == available)
+		}
+		p.sequence.Set(available)
+	}
+}
+
+// stops the processing loop once the current batch is done, or the next Run before it starts.
+func (p *BatchEventProcessor) Halt() {
+	atomic.StoreInt32(&p.state, processorHalted)
+	p.barrier.Alert()
+}
+
+func (p *BatchEventProcessor) IsRunning() bool {
+	return atomic.LoadInt32(&p.state) == processorRunning
+}
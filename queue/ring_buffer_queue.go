@@ -0,0 +1,368 @@
+package queue
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * A bounded BlockingQueue backed by a RingBuffer, for low latency hand-offs.
+ *
+ * <p>Producers claim slots from the ring buffer according to the claim strategy,
+ * consumers take the published slots in order. with the SPSC and MPSC strategies
+ * the consuming methods (Take, Poll, PollTimeout ...) must not be called by more
+ * than one goroutine at a time, with SPSC the same holds for the producing ones
+ * (Offer, Put, OfferTimout ...). MPMC lets consumers compete for slots.
+ *
+ * <p>Waiting consumers wait as the WaitStrategy says, waiting producers always
+ * spin and yield, as in the Disruptor.
+ *
+ * <p>The elements of a ring buffer can't be removed from the middle, Remove,
+ * RemoveAll, RemoveIf and RetainAll panic UnsupportedOperationError.
+ */
+type RingBufferQueue struct {
+	ringBuffer *RingBuffer
+	barrier    *SequenceBarrier
+	// the highest sequence taken by a consumer.
+	claimed *Sequence
+	// the highest sequence below which all the slots have been read, it gates the producers.
+	consumed *Sequence
+	// MPMC only: done[sequence & indexMask] == sequence >> indexShift once the slot of sequence has been read.
+	done       []int32
+	indexShift uint
+}
+
+// the pre-allocated event of every slot of a RingBufferQueue.
+type ringBufferQueueSlot struct {
+	// *interface{}, it's read by Range while producers may write it, hence the atomic access.
+	value unsafe.Pointer
+}
+
+func (s *ringBufferQueueSlot) load() interface{} {
+	if p := atomic.LoadPointer(&s.value); p != nil {
+		return *(*interface{})(p)
+	}
+	return nil
+}
+
+func (s *ringBufferQueueSlot) store(i interface{}) {
+	atomic.StorePointer(&s.value, unsafe.Pointer(&i))
+}
+
+func (s *ringBufferQueueSlot) clear() interface{} {
+	return *(*interface{})(atomic.SwapPointer(&s.value, nil))
+}
+
+/**
+ * @Description: create a RingBufferQueue.
+ * @param capacity must be a power of 2, or IllegalArgumentError will be panic
+ * @param claim the claim strategy
+ * @param wait the wait strategy of the consumers, nil means a BlockingWaitStrategy
+ * @return *RingBufferQueue
+ */
+func NewRingBufferQueue(capacity int, claim ClaimStrategy, wait WaitStrategy) *RingBufferQueue {
+	rb := NewRingBuffer(capacity, claim, wait, func() interface{} {
+		return &ringBufferQueueSlot{}
+	})
+	q := &RingBufferQueue{
+		ringBuffer: rb,
+		barrier:    rb.NewBarrier(),
+		claimed:    NewSequence(InitialSequence),
+		consumed:   NewSequence(InitialSequence),
+	}
+	rb.AddGatingSequences(q.consumed)
+	if claim.multiConsumer() {
+		q.done = make([]int32, capacity)
+		for i := range q.done {
+			q.done[i] = -1
+		}
+		q.indexShift = log2(int64(capacity))
+	}
+	return q
+}
+
+func (q *RingBufferQueue) slot(sequence int64) *ringBufferQueueSlot {
+	return q.ringBuffer.Get(sequence).(*ringBufferQueueSlot)
+}
+
+func (q *RingBufferQueue) publish(sequence int64, i interface{}) {
+	q.slot(sequence).store(i)
+	q.ringBuffer.Publish(sequence)
+}
+
+/**
+ * @Description: try to take the next sequence, if it has been published.
+ * @return bool false if nothing is available
+ */
+func (q *RingBufferQueue) tryConsume() (interface{}, bool) {
+	for {
+		current := q.claimed.Get()
+		next := current + 1
+		if next > q.ringBuffer.Cursor() || !q.ringBuffer.IsPublished(next) {
+			return nil, false
+		}
+		if !q.ringBuffer.claim.multiConsumer() {
+			x := q.slot(next).clear()
+			q.claimed.Set(next)
+			q.consumed.Set(next)
+			return x, true
+		}
+		if q.claimed.CompareAndSet(current, next) {
+			x := q.slot(next).clear()
+			q.markDone(next)
+			return x, true
+		}
+	}
+}
+
+// records the slot of sequence as read, then moves consumed forward as far as possible.
+func (q *RingBufferQueue) markDone(sequence int64) {
+	atomic.StoreInt32(&q.done[sequence&q.ringBuffer.indexMask], int32(sequence>>q.indexShift))
+	for {
+		current := q.consumed.Get()
+		next := current + 1
+		if atomic.LoadInt32(&q.done[next&q.ringBuffer.indexMask]) != int32(next>>q.indexShift) {
+			// whoever reads next will carry on from there.
+			return
+		}
+		q.consumed.CompareAndSet(current, next)
+	}
+}
+
+// takes the next element, waiting until deadline, or forever if deadline is zero.
+func (q *RingBufferQueue) consume(deadline time.Time) interface{} {
+	for {
+		if x, ok := q.tryConsume(); ok {
+			return x
+		}
+		// not SequenceBarrier.WaitFor: another consumer may take next meanwhile, then its slot is
+		// reused and next never shows up as published again, so the claim must be checked every round.
+		next := q.claimed.Get() + 1
+		if next <= q.ringBuffer.Cursor() {
+			// claimed by a producer but not published yet, or just taken by another consumer.
+			if !deadline.IsZero() && !time.Now().Before(deadline) {
+				return nil
+			}
+			runtime.Gosched()
+			continue
+		}
+		cursor := q.ringBuffer.sequencer.cursor()
+		if _, err := q.ringBuffer.wait.WaitFor(next, cursor, nil, q.barrier, deadline); err == TimeoutError {
+			// one last chance, the element may have come in just before the deadline.
+			x, _ := q.tryConsume()
+			return x
+		}
+	}
+}
+
+func (q *RingBufferQueue) Offer(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	sequence, ok := q.ringBuffer.TryNext()
+	if !ok {
+		return false
+	}
+	q.publish(sequence, i)
+	return true
+}
+
+func (q *RingBufferQueue) Put(i interface{}) error {
+	if i == nil {
+		return NilPointerError
+	}
+	q.publish(q.ringBuffer.Next(), i)
+	return nil
+}
+
+func (q *RingBufferQueue) OfferTimout(i interface{}, timeout time.Duration) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		if sequence, ok := q.ringBuffer.TryNext(); ok {
+			q.publish(sequence, i)
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		runtime.Gosched()
+	}
+}
+
+func (q *RingBufferQueue) Add(i interface{}) bool {
+	if q.Offer(i) {
+		return true
+	}
+	panic(IllegalStateError)
+}
+
+func (q *RingBufferQueue) Take() interface{} {
+	return q.consume(time.Time{})
+}
+
+func (q *RingBufferQueue) Poll() interface{} {
+	x, _ := q.tryConsume()
+	return x
+}
+
+func (q *RingBufferQueue) PollTimeout(timeout time.Duration) interface{} {
+	return q.consume(time.Now().Add(timeout))
+}
+
+func (q *RingBufferQueue) RemoveHead() interface{} {
+	if x := q.Poll(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+/**
+ * Retrieves, but does not remove, the head of this queue. with MPMC the head may
+ * have been taken by another consumer by the time this method returns.
+ */
+func (q *RingBufferQueue) Peek() interface{} {
+	var x interface{}
+	q.Range(func(value interface{}) bool {
+		x = value
+		return false
+	})
+	return x
+}
+
+func (q *RingBufferQueue) Element() interface{} {
+	if x := q.Peek(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (q *RingBufferQueue) RemainingCapacity() int {
+	return q.ringBuffer.Size() - q.Len()
+}
+
+func (q *RingBufferQueue) Len() int {
+	// with multiple producers, this counts the claimed sequences that are about to be published too.
+	n := q.ringBuffer.Cursor() - q.claimed.Get()
+	if n < 0 {
+		return 0
+	}
+	return int(n)
+}
+
+func (q *RingBufferQueue) IsEmpty() bool {
+	return q.Len() == 0
+}
+
+func (q *RingBufferQueue) Contains(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	found := false
+	q.Range(func(value interface{}) bool {
+		found = value == i
+		return !found
+	})
+	return found
+}
+
+/**
+ * @Description: weakly consistent iteration from head to tail, it never blocks producers nor consumers.
+ * @receiver q
+ * @param f return false to stop the iteration
+ */
+func (q *RingBufferQueue) Range(f func(value interface{}) bool) {
+	from := q.claimed.Get() + 1
+	to := q.ringBuffer.sequencer.highestPublished(from, q.ringBuffer.Cursor())
+	for sequence := from; sequence <= to; sequence++ {
+		x := q.slot(sequence).load()
+		// once a consumer has moved past sequence, the slot may hold another element, or nothing.
+		if q.claimed.Get() >= sequence {
+			continue
+		}
+		if x != nil && !f(x) {
+			return
+		}
+	}
+}
+
+func (q *RingBufferQueue) ToSlice() []interface{} {
+	ret := make([]interface{}, 0, q.Len())
+	q.Range(func(value interface{}) bool {
+		ret = append(ret, value)
+		return true
+	})
+	return ret
+}
+
+func (q *RingBufferQueue) String() string {
+	return fmt.Sprintf("%v", q.ToSlice())
+}
+
+// lower performance
+func (q *RingBufferQueue) ContainsAll(c Collection) bool {
+	containsAll := true
+	c.Range(func(value interface{}) bool {
+		if !q.Contains(value) {
+			containsAll = false
+			return false
+		}
+		return true
+	})
+	return containsAll
+}
+
+/**
+ * @Description: offers all non-nil elements of c until the queue is full.
+ *               nil element in c will be skipped. it'll return FullError/ NilPointerError to indicate error.
+ * @receiver q
+ * @param c if c is nil, NilPointerError is returned
+ * @return bool indicates whether the queue has been changed or not when the func return
+ */
+func (q *RingBufferQueue) AddAll(c Collection) (modified bool, err error) {
+	if c == nil {
+		return false, NilPointerError
+	}
+	for _, e := range c.ToSlice() {
+		if e == nil {
+			err = NilPointerError
+			continue
+		}
+		if !q.Offer(e) {
+			return modified, FullError
+		}
+		modified = true
+	}
+	return
+}
+
+func (q *RingBufferQueue) Remove(i interface{}) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (q *RingBufferQueue) RemoveAll(c Collection) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (q *RingBufferQueue) RemoveIf(filter func(value interface{}) bool) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (q *RingBufferQueue) RetainAll(c Collection) bool {
+	panic(UnsupportedOperationError)
+}
+
+/**
+ * Removes all of the elements from this queue, by polling them.
+ */
+func (q *RingBufferQueue) Clear() {
+	for q.Poll() != nil {
+	}
+}
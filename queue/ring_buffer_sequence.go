@@ -0,0 +1,47 @@
+package queue
+
+import "sync/atomic"
+
+// the sequence a RingBuffer cursor, or a consumer, starts from: nothing published, nothing consumed.
+const InitialSequence int64 = -1
+
+/**
+ * A Sequence is a monotonically increasing counter shared between the producers
+ * and the consumers of a RingBuffer, it is padded on both sides so that two hot
+ * sequences never share a cache line.
+ */
+type Sequence struct {
+	_     [7]int64
+	value int64
+	_     [7]int64
+}
+
+func NewSequence(initial int64) *Sequence {
+	return &Sequence{value: initial}
+}
+
+func (s *Sequence) Get() int64 {
+	return atomic.LoadInt64(&s.value)
+}
+
+func (s *Sequence) Set(value int64) {
+	atomic.StoreInt64(&s.value, value)
+}
+
+func (s *Sequence) CompareAndSet(expected, value int64) bool {
+	return atomic.CompareAndSwapInt64(&s.value, expected, value)
+}
+
+func (s *Sequence) AddAndGet(delta int64) int64 {
+	return atomic.AddInt64(&s.value, delta)
+}
+
+// returns the minimum of the sequences and min.
+func minimumSequence(sequences []*Sequence, min int64) int64 {
+	for _, s := range sequences {
+		if v := s.Get(); v < min {
+			min = v
+		}
+	}
+	return min
+}
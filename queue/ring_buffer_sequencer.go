@@ -0,0 +1,270 @@
+package queue
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+/**
+ * A sequencer hands out the sequences (slots) of a RingBuffer to producers,
+ * making sure that they never wrap past the slowest gating consumer, and tracks
+ * which sequences have been published.
+ */
+type sequencer interface {
+	// claims the next n sequences, spinning while there is no room, returns the highest one.
+	next(n int64) int64
+	// claims the next n sequences if there is room for them, returns the highest one.
+	tryNext(n int64) (int64, bool)
+	// makes the sequences from lo to hi, inclusive, visible to consumers.
+	publish(lo, hi int64)
+	isAvailable(sequence int64) bool
+	// returns the highest sequence, from lo up to available, that can be consumed safely.
+	highestPublished(lo, available int64) int64
+	// the cursor consumers wait on.
+	cursor() *Sequence
+	remainingCapacity() int64
+	addGatingSequences(sequences ...*Sequence)
+	removeGatingSequence(sequence *Sequence) bool
+}
+
+// gatingSequences is the copy-on-write set of consumer sequences producers must not wrap past.
+type gatingSequences struct {
+	// guards writers only, readers load the slice atomically.
+	mutex     sync.Mutex
+	sequences atomic.Value
+}
+
+func (g *gatingSequences) get() []*Sequence {
+	s, _ := g.sequences.Load().([]*Sequence)
+	return s
+}
+
+func (g *gatingSequences) add(cursor int64, sequences ...*Sequence) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	old := g.get()
+	updated := make([]*Sequence, len(old), len(old)+len(sequences))
+	copy(updated, old)
+	for _, s := range sequences {
+		// a consumer joining late starts from the current cursor, otherwise it would hold producers back forever.
+		s.Set(cursor)
+		updated = append(updated, s)
+	}
+	g.sequences.Store(updated)
+}
+
+func (g *gatingSequences) remove(sequence *Sequence) bool {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	old := g.get()
+	updated := make([]*Sequence, 0, len(old))
+	for _, s := range old {
+		if s != sequence {
+			updated = append(updated, s)
+		}
+	}
+	g.sequences.Store(updated)
+	return len(updated) != len(old)
+}
+
+/**
+ * Sequencer for a single producer, it's not safe to claim sequences from more
+ * than one goroutine at a time, but claiming is a plain increment.
+ */
+type singleProducerSequencer struct {
+	bufferSize int64
+	wait       WaitStrategy
+	gating     gatingSequences
+	// the highest published sequence
+	published *Sequence
+	// the highest claimed sequence, only the producer writes it
+	nextValue int64
+	// the minimum gating sequence seen by the last check, only the producer touches it
+	cachedValue int64
+}
+
+func newSingleProducerSequencer(bufferSize int64, wait WaitStrategy) *singleProducerSequencer {
+	return &singleProducerSequencer{
+		bufferSize:  bufferSize,
+		wait:        wait,
+		published:   NewSequence(InitialSequence),
+		nextValue:   InitialSequence,
+		cachedValue: InitialSequence,
+	}
+}
+
+func (s *singleProducerSequencer) hasCapacity(n int64, spin bool) bool {
+	nextValue := atomic.LoadInt64(&s.nextValue)
+	wrapPoint := nextValue + n - s.bufferSize
+	if wrapPoint <= s.cachedValue && s.cachedValue <= nextValue {
+		return true
+	}
+	for {
+		min := minimumSequence(s.gating.get(), nextValue)
+		s.cachedValue = min
+		if wrapPoint <= min {
+			return true
+		}
+		if !spin {
+			return false
+		}
+		runtime.Gosched()
+	}
+}
+
+func (s *singleProducerSequencer) next(n int64) int64 {
+	s.hasCapacity(n, true)
+	return atomic.AddInt64(&s.nextValue, n)
+}
+
+func (s *singleProducerSequencer) tryNext(n int64) (int64, bool) {
+	if !s.hasCapacity(n, false) {
+		return 0, false
+	}
+	return atomic.AddInt64(&s.nextValue, n), true
+}
+
+func (s *singleProducerSequencer) publish(lo, hi int64) {
+	s.published.Set(hi)
+	s.wait.SignalAllWhenBlocking()
+}
+
+func (s *singleProducerSequencer) isAvailable(sequence int64) bool {
+	published := s.published.Get()
+	return sequence <= published && sequence > published-s.bufferSize
+}
+
+func (s *singleProducerSequencer) highestPublished(lo, available int64) int64 {
+	return available
+}
+
+func (s *singleProducerSequencer) cursor() *Sequence {
+	return s.published
+}
+
+func (s *singleProducerSequencer) remainingCapacity() int64 {
+	produced := atomic.LoadInt64(&s.nextValue)
+	return s.bufferSize - (produced - minimumSequence(s.gating.get(), produced))
+}
+
+func (s *singleProducerSequencer) addGatingSequences(sequences ...*Sequence) {
+	s.gating.add(s.published.Get(), sequences...)
+}
+
+func (s *singleProducerSequencer) removeGatingSequence(sequence *Sequence) bool {
+	return s.gating.remove(sequence)
+}
+
+/**
+ * Sequencer for multiple concurrent producers, sequences are claimed with a
+ * compare-and-swap on the cursor, and as producers may publish out of order,
+ * every slot records the round of the last sequence published into it.
+ */
+type multiProducerSequencer struct {
+	bufferSize int64
+	wait       WaitStrategy
+	gating     gatingSequences
+	// the highest claimed sequence
+	claimed *Sequence
+	// the minimum gating sequence seen by the last check
+	gatingCache *Sequence
+	// availableBuffer[sequence & indexMask] == sequence >> indexShift once sequence is published
+	availableBuffer []int32
+	indexMask       int64
+	indexShift      uint
+}
+
+func newMultiProducerSequencer(bufferSize int64, wait WaitStrategy) *multiProducerSequencer {
+	s := &multiProducerSequencer{
+		bufferSize:      bufferSize,
+		wait:            wait,
+		claimed:         NewSequence(InitialSequence),
+		gatingCache:     NewSequence(InitialSequence),
+		availableBuffer: make([]int32, bufferSize),
+		indexMask:       bufferSize - 1,
+		indexShift:      log2(bufferSize),
+	}
+	for i := range s.availableBuffer {
+		s.availableBuffer[i] = -1
+	}
+	return s
+}
+
+func (s *multiProducerSequencer) claim(n int64, spin bool) (int64, bool) {
+	for {
+		current := s.claimed.Get()
+		next := current + n
+		wrapPoint := next - s.bufferSize
+		cachedGating := s.gatingCache.Get()
+		if wrapPoint > cachedGating || cachedGating > current {
+			gating := minimumSequence(s.gating.get(), current)
+			if wrapPoint > gating {
+				if !spin {
+					return 0, false
+				}
+				runtime.Gosched()
+				continue
+			}
+			s.gatingCache.Set(gating)
+		} else if s.claimed.CompareAndSet(current, next) {
+			return next, true
+		}
+	}
+}
+
+func (s *multiProducerSequencer) next(n int64) int64 {
+	next, _ := s.claim(n, true)
+	return next
+}
+
+func (s *multiProducerSequencer) tryNext(n int64) (int64, bool) {
+	return s.claim(n, false)
+}
+
+func (s *multiProducerSequencer) publish(lo, hi int64) {
+	for sequence := lo; sequence <= hi; sequence++ {
+		atomic.StoreInt32(&s.availableBuffer[sequence&s.indexMask], int32(sequence>>s.indexShift))
+	}
+	s.wait.SignalAllWhenBlocking()
+}
+
+func (s *multiProducerSequencer) isAvailable(sequence int64) bool {
+	return atomic.LoadInt32(&s.availableBuffer[sequence&s.indexMask]) == int32(sequence>>s.indexShift)
+}
+
+func (s *multiProducerSequencer) highestPublished(lo, available int64) int64 {
+	for sequence := lo; sequence <= available; sequence++ {
+		if !s.isAvailable(sequence) {
+			return sequence - 1
+		}
+	}
+	return available
+}
+
+func (s *multiProducerSequencer) cursor() *Sequence {
+	return s.claimed
+}
+
+func (s *multiProducerSequencer) remainingCapacity() int64 {
+	produced := s.claimed.Get()
+	return s.bufferSize - (produced - minimumSequence(s.gating.get(), produced))
+}
+
+func (s *multiProducerSequencer) addGatingSequences(sequences ...*Sequence) {
+	s.gating.add(s.claimed.Get(), sequences...)
+}
+
+func (s *multiProducerSequencer) removeGatingSequence(sequence *Sequence) bool {
+	return s.gating.remove(sequence)
+}
+
+// n must be a power of 2.
+func log2(n int64) uint {
+	var r uint
+	for n > 1 {
+		n >>= 1
+		r++
+	}
+	return r
+}
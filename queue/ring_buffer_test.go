@@ -0,0 +1,219 @@
+package queue
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+var _ BlockingQueue = (*RingBufferQueue)(nil)
+
+var waitStrategies = map[string]func() WaitStrategy{
+	"busy-spin": func() WaitStrategy { return BusySpinWaitStrategy{} },
+	"yielding":  func() WaitStrategy { return YieldingWaitStrategy{} },
+	"blocking":  func() WaitStrategy { return NewBlockingWaitStrategy() },
+}
+
+// busy spinning consumers need a core each, plus one for the producers, or they starve everybody.
+func skipBusySpin(t *testing.T, name string, consumers int) {
+	if name == "busy-spin" && runtime.NumCPU() <= consumers {
+		t.Skipf("busy-spin needs more than %d CPUs", consumers)
+	}
+}
+
+// two dependent stages: the second one must only see events the first one has handled.
+func TestRingBuffer_Pipeline(t *testing.T) {
+	const n = 1000
+	for name, wait := range waitStrategies {
+		t.Run(name, func(t *testing.T) {
+			skipBusySpin(t, name, 2)
+			rb := NewRingBuffer(16, MPSC, wait(), nil)
+			var handled [n]bool
+			first := NewBatchEventProcessor(rb, rb.NewBarrier(), func(event interface{}, sequence int64, endOfBatch bool) {
+				handled[event.(int)] = true
+			})
+			var got []int
+			done := make(chan struct{})
+			second := NewBatchEventProcessor(rb, rb.NewBarrier(first.Sequence()), func(event interface{}, sequence int64, endOfBatch bool) {
+				if !handled[event.(int)] {
+					t.Errorf("event %v reached the second stage first", event)
+				}
+				got = append(got, event.(int))
+				if len(got) == n {
+					close(done)
+				}
+			})
+			rb.AddGatingSequences(second.Sequence())
+			go first.Run()
+			go second.Run()
+
+			for i := 0; i < n; i += 10 {
+				batch := make([]interface{}, 10)
+				for j := range batch {
+					batch[j] = i + j
+				}
+				rb.PublishEvents(batch)
+			}
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("pipeline stalled")
+			}
+			first.Halt()
+			second.Halt()
+			for i, x := range got {
+				if x != i {
+					t.Fatalf("event %d: got %d", i, x)
+				}
+			}
+		})
+	}
+}
+
+func TestRingBuffer_TryNext(t *testing.T) {
+	rb := NewRingBuffer(4, SPSC, nil, nil)
+	consumer := NewSequence(InitialSequence)
+	rb.AddGatingSequences(consumer)
+	for i := 0; i < 4; i++ {
+		if !rb.TryPublishEvent(i) {
+			t.Fatalf("TryPublishEvent %d failed", i)
+		}
+	}
+	if rb.TryPublishEvent(4) || rb.RemainingCapacity() != 0 {
+		t.Fatal("the buffer should be full")
+	}
+	available, err := rb.NewBarrier().WaitFor(0)
+	if err != nil || available != 3 {
+		t.Fatalf("WaitFor: got %d, %v", available, err)
+	}
+	consumer.Set(1)
+	if _, ok := rb.TryNextN(2); !ok {
+		t.Fatal("two slots should have been freed")
+	}
+}
+
+func TestSequenceBarrier_AlertAndTimeout(t *testing.T) {
+	rb := NewRingBuffer(4, SPSC, nil, nil)
+	barrier := rb.NewBarrier()
+	if _, err := barrier.WaitForTimeout(0, 20*time.Millisecond); err != TimeoutError {
+		t.Fatalf("want TimeoutError, got %v", err)
+	}
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		barrier.Alert()
+	}()
+	if _, err := barrier.WaitFor(0); err != AlertError {
+		t.Fatalf("want AlertError, got %v", err)
+	}
+}
+
+func TestBatchEventProcessor_HaltBeforeRun(t *testing.T) {
+	rb := NewRingBuffer(4, SPSC, NewBlockingWaitStrategy(), nil)
+	p := NewBatchEventProcessor(rb, rb.NewBarrier(), func(event interface{}, sequence int64, endOfBatch bool) {})
+	p.Halt()
+	done := make(chan struct{})
+	go func() {
+		p.Run()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("a Halt before Run must stop it")
+	}
+
+	// the processor can run again, until the next Halt.
+	done = make(chan struct{})
+	go func() {
+		p.Run()
+		close(done)
+	}()
+	for !p.IsRunning() {
+		runtime.Gosched()
+	}
+	p.Halt()
+	<-done
+}
+
+func TestRingBufferQueue_SPSC(t *testing.T) {
+	q := NewRingBufferQueue(8, SPSC, nil)
+	go func() {
+		for i := 0; i < 1000; i++ {
+			q.Put(i)
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		if x := q.Take(); x != i {
+			t.Fatalf("Take: want %d, got %v", i, x)
+		}
+	}
+	if x := q.PollTimeout(10 * time.Millisecond); x != nil {
+		t.Fatalf("PollTimeout: got %v", x)
+	}
+}
+
+func TestRingBufferQueue_MPMC(t *testing.T) {
+	const producers, consumers, perProducer = 4, 4, 1000
+	for name, wait := range waitStrategies {
+		t.Run(name, func(t *testing.T) {
+			skipBusySpin(t, name, consumers)
+			q := NewRingBufferQueue(16, MPMC, wait())
+			var wg sync.WaitGroup
+			for p := 0; p < producers; p++ {
+				wg.Add(1)
+				go func(p int) {
+					defer wg.Done()
+					for i := 0; i < perProducer; i++ {
+						q.Put(p*perProducer + i)
+					}
+				}(p)
+			}
+			results := make(chan interface{}, producers*perProducer)
+			for c := 0; c < consumers; c++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < perProducer; i++ {
+						results <- q.Take()
+					}
+				}()
+			}
+			wg.Wait()
+			close(results)
+			seen := make(map[interface{}]bool)
+			for x := range results {
+				if seen[x] {
+					t.Fatalf("element %v taken twice", x)
+				}
+				seen[x] = true
+			}
+			if len(seen) != producers*perProducer || !q.IsEmpty() {
+				t.Fatalf("want %d distinct elements, got %d", producers*perProducer, len(seen))
+			}
+		})
+	}
+}
+
+func TestRingBufferQueue_Collection(t *testing.T) {
+	q := NewRingBufferQueue(4, MPSC, nil)
+	for i := 1; i <= 4; i++ {
+		q.Add(i)
+	}
+	if q.Offer(5) || q.OfferTimout(5, 10*time.Millisecond) {
+		t.Fatal("offer to a full queue should fail")
+	}
+	if q.Peek() != 1 || !q.Contains(4) || q.Len() != 4 || q.String() != "[1 2 3 4]" {
+		t.Fatalf("unexpected content: %v", q)
+	}
+	q.Poll()
+	if !q.Offer(5) || q.String() != "[2 3 4 5]" {
+		t.Fatalf("unexpected content after wrapping: %v", q)
+	}
+	q.Clear()
+	if !q.IsEmpty() || q.RemainingCapacity() != 4 {
+		t.Fatal("queue should be empty")
+	}
+}
@@ -0,0 +1,153 @@
+package queue
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * WaitStrategy decides how a consumer waits for a sequence to become available
+ * in a RingBuffer, trading latency against CPU usage.
+ */
+type WaitStrategy interface {
+	/**
+	 * @Description: wait until the cursor and all the dependent sequences have reached sequence.
+	 * @param sequence the sequence to wait for
+	 * @param cursor the cursor of the RingBuffer, producers move it forward
+	 * @param dependents the sequences of the consumers the waiting consumer depends on, may be empty
+	 * @param barrier the barrier the waiting is done for, it must be checked for alerts while waiting
+	 * @param deadline give up with TimeoutError once it has passed, the zero Time means no deadline
+	 * @return int64 the highest available sequence, it may be greater than sequence.
+	 *         for a multi producer RingBuffer a sequence being available here only means it has been claimed.
+	 * @return error AlertError if the barrier has been alerted, TimeoutError if the deadline has passed
+	 */
+	WaitFor(sequence int64, cursor *Sequence, dependents []*Sequence, barrier *SequenceBarrier, deadline time.Time) (int64, error)
+
+	/**
+	 * @Description: wake up the consumers blocked in WaitFor, called by producers after publishing.
+	 */
+	SignalAllWhenBlocking()
+}
+
+// checks the barrier and the deadline, it's called in every round of the spinning strategies.
+func checkWait(barrier *SequenceBarrier, deadline time.Time) error {
+	if err := barrier.CheckAlert(); err != nil {
+		return err
+	}
+	if !deadline.IsZero() && !time.Now().Before(deadline) {
+		return TimeoutError
+	}
+	return nil
+}
+
+/**
+ * Busy Spin strategy that uses a busy spin loop while waiting.
+ *
+ * <p>This strategy gives the lowest latency, at the price of burning a whole CPU
+ * for every waiting consumer. it should only be used if the number of consumers
+ * is lower than the number of physical cores.
+ */
+type BusySpinWaitStrategy struct{}
+
+func (BusySpinWaitStrategy) WaitFor(sequence int64, cursor *Sequence, dependents []*Sequence, barrier *SequenceBarrier, deadline time.Time) (int64, error) {
+	for {
+		available := minimumSequence(dependents, cursor.Get())
+		if available >= sequence {
+			return available, nil
+		}
+		if err := checkWait(barrier, deadline); err != nil {
+			return available, err
+		}
+	}
+}
+
+func (BusySpinWaitStrategy) SignalAllWhenBlocking() {}
+
+// the number of rounds YieldingWaitStrategy spins before it starts to yield.
+const yieldingSpinTries = 100
+
+/**
+ * Yielding strategy that spins for a while, then yields the processor to other
+ * goroutines with runtime.Gosched.
+ *
+ * <p>This strategy is a good compromise between latency and CPU usage, the CPU
+ * is still busy while waiting, but other goroutines get their share of it.
+ */
+type YieldingWaitStrategy struct{}
+
+func (YieldingWaitStrategy) WaitFor(sequence int64, cursor *Sequence, dependents []*Sequence, barrier *SequenceBarrier, deadline time.Time) (int64, error) {
+	for counter := yieldingSpinTries; ; {
+		available := minimumSequence(dependents, cursor.Get())
+		if available >= sequence {
+			return available, nil
+		}
+		if err := checkWait(barrier, deadline); err != nil {
+			return available, err
+		}
+		if counter > 0 {
+			counter--
+		} else {
+			runtime.Gosched()
+		}
+	}
+}
+
+func (YieldingWaitStrategy) SignalAllWhenBlocking() {}
+
+/**
+ * Blocking strategy that parks the waiting consumers on a condition variable
+ * until a producer signals a publish.
+ *
+ * <p>This strategy is the slowest of all, but it costs no CPU while there is
+ * nothing to consume, it's the default one.
+ */
+type BlockingWaitStrategy struct {
+	mutex sync.Mutex
+	// Condition for waiting consumers
+	cond *sync.Cond
+}
+
+func NewBlockingWaitStrategy() *BlockingWaitStrategy {
+	s := &BlockingWaitStrategy{}
+	s.cond = sync.NewCond(&s.mutex)
+	return s
+}
+
+func (s *BlockingWaitStrategy) WaitFor(sequence int64, cursor *Sequence, dependents []*Sequence, barrier *SequenceBarrier, deadline time.Time) (int64, error) {
+	if cursor.Get() < sequence {
+		s.mutex.Lock()
+		for cursor.Get() < sequence {
+			if err := barrier.CheckAlert(); err != nil {
+				s.mutex.Unlock()
+				return cursor.Get(), err
+			}
+			if deadline.IsZero() {
+				s.cond.Wait()
+			} else if !waitUntil(s.cond, deadline) {
+				s.mutex.Unlock()
+				return cursor.Get(), TimeoutError
+			}
+		}
+		s.mutex.Unlock()
+	}
+	// the cursor is there, the dependent consumers are usually about to follow, so spin for them.
+	for {
+		available := minimumSequence(dependents, cursor.Get())
+		if available >= sequence {
+			return available, nil
+		}
+		if err := checkWait(barrier, deadline); err != nil {
+			return available, err
+		}
+		runtime.Gosched()
+	}
+}
+
+func (s *BlockingWaitStrategy) SignalAllWhenBlocking() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.cond.Broadcast()
+}
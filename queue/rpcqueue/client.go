@@ -0,0 +1,358 @@
+package rpcqueue
+
+import (
+	"fmt"
+	"net/rpc"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+	"github.com/torchcc/data-structure/queue"
+)
+
+// the errors of the error package, recognized in the replies of the server.
+var sentinels = []error{
+	FullError, NilPointerError, NoSuchElementError, IllegalStateError, IllegalArgumentError,
+	UnsupportedOperationError, TimeoutError, DuplicateElementError,
+}
+
+/**
+ * A Client is a BlockingQueue standing for a queue exported by a Server, every
+ * method is a remote call. Put, OfferTimout, Take and PollTimeout wait on the server
+ * side, which gives up once the connection is lost.
+ *
+ * <p>Put returns the errors, the other methods can't: they return false, nil or
+ * 0 instead, and Err tells the first error met, a lost connection or an unknown
+ * queue for example. a panic of the remote queue, such as
+ * UnsupportedOperationError, panics the caller as it would locally.
+ *
+ * <p>The bulk methods, Range, ContainsAll, RemoveIf ..., work on a snapshot
+ * fetched with ToSlice, RemoveIf isn't atomic.
+ */
+type Client struct {
+	rpc  *rpc.Client
+	name string
+
+	lock *sync.Mutex
+	err  error
+}
+
+/**
+ * @Description: connect to a Server.
+ * @param network "tcp" usually
+ * @param address the address of the server
+ * @param name the name of the queue on the server
+ * @return *Client
+ * @return error the error met while connecting
+ */
+func Dial(network, address, name string) (*Client, error) {
+	c, err := rpc.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c, name), nil
+}
+
+// uses the queue exported under name through an rpc client, which may be shared with other Clients.
+func NewClient(c *rpc.Client, name string) *Client {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	return &Client{rpc: c, name: name, lock: new(sync.Mutex)}
+}
+
+// the first error the methods couldn't return, if any.
+func (c *Client) Err() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.err
+}
+
+func (c *Client) fail(err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// closes the rpc client, the calls in progress return rpc.ErrShutdown.
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+// maps the error of a remote call back to the errors of the error package.
+func remoteError(err error) error {
+	se, ok := err.(rpc.ServerError)
+	if !ok {
+		return err
+	}
+	msg := string(se)
+	for _, sentinel := range sentinels {
+		if msg == sentinel.Error() {
+			return sentinel
+		}
+		if strings.HasPrefix(msg, sentinel.Error()) {
+			return fmt.Errorf("%w%s", sentinel, msg[len(sentinel.Error()):])
+		}
+	}
+	return err
+}
+
+// whether err is what the remote queue panicked with.
+func isRemotePanic(err error) bool {
+	for _, sentinel := range sentinels {
+		if err == sentinel {
+			return true
+		}
+	}
+	return false
+}
+
+// calls a remote method, returns the error as it is.
+func (c *Client) call(method string, req *Request) (*Response, error) {
+	req.Name = c.name
+	resp := new(Response)
+	if err := c.rpc.Call(serviceName+"."+method, req, resp); err != nil {
+		return nil, remoteError(err)
+	}
+	return resp, nil
+}
+
+// calls a remote method for a caller which can't return the error: it panics or records it.
+func (c *Client) mustCall(method string, req *Request) (*Response, bool) {
+	resp, err := c.call(method, req)
+	if err == nil {
+		return resp, true
+	}
+	if isRemotePanic(err) {
+		panic(err)
+	}
+	c.fail(err)
+	return nil, false
+}
+
+func (c *Client) Offer(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	resp, ok := c.mustCall("Offer", &Request{Element: i})
+	return ok && resp.Ok
+}
+
+func (c *Client) Add(i interface{}) bool {
+	if c.Offer(i) {
+		return true
+	}
+	panic(IllegalStateError)
+}
+
+/**
+ * @Description: insert i, waiting on the server side for room.
+ * @return error NilPointerError, the error of the remote queue,
+ *         or the error of the connection, rpc.ErrShutdown once it's lost
+ */
+func (c *Client) Put(i interface{}) error {
+	if i == nil {
+		return NilPointerError
+	}
+	_, err := c.call("Put", &Request{Element: i})
+	return err
+}
+
+func (c *Client) OfferTimout(i interface{}, timeout time.Duration) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	resp, ok := c.mustCall("OfferTimeout", &Request{Element: i, Timeout: timeout})
+	return ok && resp.Ok
+}
+
+func (c *Client) element(method string, req *Request) interface{} {
+	if resp, ok := c.mustCall(method, req); ok {
+		return resp.Element
+	}
+	return nil
+}
+
+func (c *Client) Poll() interface{} {
+	return c.element("Poll", &Request{})
+}
+
+// waits on the server side for an element, returns nil if the connection is lost, see Err.
+func (c *Client) Take() interface{} {
+	return c.element("Take", &Request{})
+}
+
+func (c *Client) PollTimeout(timeout time.Duration) interface{} {
+	return c.element("PollTimeout", &Request{Timeout: timeout})
+}
+
+func (c *Client) RemoveHead() interface{} {
+	if x := c.Poll(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (c *Client) Peek() interface{} {
+	return c.element("Peek", &Request{})
+}
+
+func (c *Client) Element() interface{} {
+	if x := c.Peek(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (c *Client) RemainingCapacity() int {
+	if resp, ok := c.mustCall("RemainingCapacity", &Request{}); ok {
+		return resp.N
+	}
+	return 0
+}
+
+func (c *Client) Len() int {
+	if resp, ok := c.mustCall("Len", &Request{}); ok {
+		return resp.N
+	}
+	return 0
+}
+
+func (c *Client) IsEmpty() bool {
+	return c.Len() == 0
+}
+
+func (c *Client) Contains(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	resp, ok := c.mustCall("Contains", &Request{Element: i})
+	return ok && resp.Ok
+}
+
+/**
+ * @Description: iterate through a snapshot of the queue, from head to tail.
+ * @receiver c
+ * @param f return false to stop the iteration
+ */
+func (c *Client) Range(f func(value interface{}) bool) {
+	for _, x := range c.ToSlice() {
+		if !f(x) {
+			return
+		}
+	}
+}
+
+func (c *Client) ToSlice() []interface{} {
+	if resp, ok := c.mustCall("ToSlice", &Request{}); ok && resp.Elements != nil {
+		return resp.Elements
+	}
+	return []interface{}{}
+}
+
+func (c *Client) String() string {
+	return fmt.Sprintf("%v", c.ToSlice())
+}
+
+// checks c against a single snapshot of the queue.
+func (c *Client) ContainsAll(other queue.Collection) bool {
+	elements := c.ToSlice()
+	containsAll := true
+	other.Range(func(value interface{}) bool {
+		found := false
+		for _, e := range elements {
+			if e == value {
+				found = true
+				break
+			}
+		}
+		containsAll = found
+		return found
+	})
+	return containsAll
+}
+
+/**
+ * @Description: offers all non-nil elements of c until the queue is full, in a single call.
+ *               nil element in c will be skipped. it'll return FullError/ NilPointerError, or the error
+ *               of the call, to indicate error.
+ * @receiver c
+ * @param other if it's nil, NilPointerError is returned
+ * @return bool indicates whether the queue has been changed or not when the func return
+ */
+func (c *Client) AddAll(other queue.Collection) (modified bool, err error) {
+	if other == nil {
+		return false, NilPointerError
+	}
+	var elements []interface{}
+	for _, e := range other.ToSlice() {
+		if e == nil {
+			err = NilPointerError
+			continue
+		}
+		elements = append(elements, e)
+	}
+	if len(elements) == 0 {
+		return false, err
+	}
+	resp, cerr := c.call("AddAll", &Request{Elements: elements})
+	if cerr != nil {
+		return false, cerr
+	}
+	if !resp.Ok {
+		err = FullError
+	}
+	return resp.N > 0, err
+}
+
+func (c *Client) Remove(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	resp, ok := c.mustCall("Remove", &Request{Element: i})
+	return ok && resp.Ok
+}
+
+func (c *Client) removeElements(method string, elements []interface{}) bool {
+	resp, ok := c.mustCall(method, &Request{Elements: elements})
+	return ok && resp.Ok
+}
+
+func (c *Client) RemoveAll(other queue.Collection) bool {
+	if other == nil {
+		panic(NilPointerError)
+	}
+	return c.removeElements("RemoveAll", other.ToSlice())
+}
+
+// removes the elements of a snapshot matching filter, the elements inserted meanwhile are not filtered.
+func (c *Client) RemoveIf(filter func(value interface{}) bool) bool {
+	if filter == nil {
+		panic(NilPointerError)
+	}
+	var matched []interface{}
+	for _, x := range c.ToSlice() {
+		if filter(x) {
+			matched = append(matched, x)
+		}
+	}
+	if len(matched) == 0 {
+		return false
+	}
+	return c.removeElements("RemoveAll", matched)
+}
+
+// panics IllegalArgumentError if an element can't be compared with ==, a slice or a map for example.
+func (c *Client) RetainAll(other queue.Collection) bool {
+	if other == nil {
+		panic(NilPointerError)
+	}
+	return c.removeElements("RetainAll", other.ToSlice())
+}
+
+func (c *Client) Clear() {
+	c.mustCall("Clear", &Request{})
+}
@@ -0,0 +1,224 @@
+package rpcqueue
+
+import (
+	"encoding/gob"
+	"errors"
+	"net"
+	"net/rpc"
+	"testing"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+	"github.com/torchcc/data-structure/queue"
+)
+
+var _ queue.BlockingQueue = (*Client)(nil)
+
+type rpcTestJob struct {
+	ID   int
+	Name string
+}
+
+func init() {
+	gob.Register(rpcTestJob{})
+}
+
+func fromSlice(elements ...interface{}) *queue.LinkedBlockingQueue {
+	q, _ := queue.FromSlice(elements, 0)
+	return q
+}
+
+func startServer(t *testing.T) (*Server, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer()
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	return s, l.Addr().String()
+}
+
+func dial(t *testing.T, addr, name string) *Client {
+	c, err := Dial("tcp", addr, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestClient_BlockingQueue(t *testing.T) {
+	s, addr := startServer(t)
+	q := queue.NewLinkedBlockingQueue(3)
+	s.Register("jobs", q)
+	c := dial(t, addr, "jobs")
+
+	if err := c.Put(rpcTestJob{1, "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Offer("b") || !c.OfferTimout(3, time.Millisecond) {
+		t.Fatal("offer")
+	}
+	if c.Offer("d") || c.OfferTimout("d", 10*time.Millisecond) || c.RemainingCapacity() != 0 {
+		t.Fatal("expected full")
+	}
+	if c.Len() != 3 || !c.Contains("b") || c.Contains("d") || c.String() != "[{1 a} b 3]" {
+		t.Fatalf("len %d, %s", c.Len(), c)
+	}
+	if x := c.Peek(); x != (rpcTestJob{1, "a"}) {
+		t.Fatalf("peek %v", x)
+	}
+	if x := c.Take(); x != (rpcTestJob{1, "a"}) {
+		t.Fatalf("take %v", x)
+	}
+	if !c.Remove(3) || c.Poll() != "b" || c.PollTimeout(10*time.Millisecond) != nil || !c.IsEmpty() {
+		t.Fatalf("expected empty, %s", c)
+	}
+
+	other := queue.NewLinkedBlockingQueue(0)
+	for _, x := range []interface{}{1, 2, 3, 4} {
+		other.Put(x)
+	}
+	if modified, err := c.AddAll(other); !modified || err != FullError || c.Len() != 3 {
+		t.Fatalf("AddAll %v %v, %s", modified, err, c)
+	}
+	if !c.ContainsAll(fromSlice(1, 3)) {
+		t.Fatal("ContainsAll")
+	}
+	if !c.RemoveIf(func(v interface{}) bool { return v == 2 }) || c.String() != "[1 3]" {
+		t.Fatalf("RemoveIf, %s", c)
+	}
+	if !c.RetainAll(fromSlice(3)) || c.String() != "[3]" {
+		t.Fatalf("RetainAll, %s", c)
+	}
+	c.Clear()
+	if !q.IsEmpty() || c.Err() != nil {
+		t.Fatalf("Clear, %v", c.Err())
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	s, addr := startServer(t)
+	dq, err := queue.OpenDiskQueue(t.TempDir(), 0, queue.GobCodec{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dq.Close()
+	s.Register("disk", dq)
+
+	// a panic of the remote queue panics the caller.
+	func() {
+		defer func() {
+			if r := recover(); r != UnsupportedOperationError {
+				t.Fatalf("expected UnsupportedOperationError, got %v", r)
+			}
+		}()
+		dial(t, addr, "disk").Remove(1)
+	}()
+
+	missing := dial(t, addr, "missing")
+	if missing.Offer(1) || !errors.Is(missing.Err(), NoSuchElementError) {
+		t.Fatalf("unknown queue: %v", missing.Err())
+	}
+	if err := missing.Put(1); !errors.Is(err, NoSuchElementError) {
+		t.Fatalf("unknown queue: %v", err)
+	}
+}
+
+func TestClient_ConnectionLoss(t *testing.T) {
+	s, addr := startServer(t)
+	q := queue.NewLinkedBlockingQueue(0)
+	s.Register("q", q)
+	c := dial(t, addr, "q")
+
+	done := make(chan interface{})
+	go func() {
+		done <- c.Take()
+	}()
+	time.Sleep(20 * time.Millisecond)
+	s.Close()
+	select {
+	case x := <-done:
+		if x != nil || c.Err() == nil {
+			t.Fatalf("expected nil and an error, got %v, %v", x, c.Err())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Take must return once the connection is lost")
+	}
+	if err := c.Put(1); err != rpc.ErrShutdown {
+		t.Fatalf("expected rpc.ErrShutdown, got %v", err)
+	}
+	// Close waited for the Take of the lost connection, which doesn't take anything afterwards.
+	q.Put("x")
+	time.Sleep(2 * waitSlice)
+	if q.Len() != 1 {
+		t.Fatal("the Take of a lost connection took an element")
+	}
+}
+
+func TestClient_GoneWhileBlocked(t *testing.T) {
+	s, addr := startServer(t)
+	q := queue.NewLinkedBlockingQueue(1)
+	s.Register("q", q)
+	taker, putter := dial(t, addr, "q"), dial(t, addr, "q")
+	go taker.Take()
+	time.Sleep(20 * time.Millisecond)
+	taker.Close()
+	time.Sleep(2 * waitSlice)
+	q.Put("x")
+	time.Sleep(2 * waitSlice)
+	if q.Len() != 1 {
+		t.Fatal("the Take of a client gone took an element")
+	}
+
+	// the queue is full, the Put of a client gone gives up.
+	go putter.Put("y")
+	time.Sleep(20 * time.Millisecond)
+	putter.Close()
+	time.Sleep(2 * waitSlice)
+	if q.Poll() != "x" || q.PollTimeout(2*waitSlice) != nil {
+		t.Fatal("the Put of a client gone inserted an element")
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	s, addr := startServer(t)
+	u := queue.NewUniqueQueue(0)
+	s.Register("u", u)
+	c := dial(t, addr, "u")
+	// the timeout is relative, the server times it with its own clock.
+	begin := time.Now()
+	if x := c.PollTimeout(30 * time.Millisecond); x != nil || time.Since(begin) > waitSlice {
+		t.Fatalf("expected nothing after the timeout, got %v after %v", x, time.Since(begin))
+	}
+	// a Put refused at once, not for lack of room, returns the error of the queue.
+	c.Put("a")
+	if err := c.Put("a"); err != DuplicateElementError {
+		t.Fatalf("expected DuplicateElementError, got %v", err)
+	}
+	if c.OfferTimout("a", time.Hour) {
+		t.Fatal("offered a duplicate")
+	}
+}
+
+func TestClient_RetainAllUncomparable(t *testing.T) {
+	s, addr := startServer(t)
+	s.Register("q", queue.NewLinkedBlockingQueue(0))
+	c := dial(t, addr, "q")
+	c.Put("a")
+	c.Put("b")
+	if !c.RetainAll(fromSlice("b")) || c.String() != "[b]" {
+		t.Fatalf("RetainAll, %s", c)
+	}
+	c.Put([]byte("c"))
+	defer func() {
+		if r := recover(); r != IllegalArgumentError {
+			t.Fatalf("expected IllegalArgumentError, got %v", r)
+		}
+		if c.Len() != 2 {
+			t.Fatalf("nothing should be removed, got %s", c)
+		}
+	}()
+	c.RetainAll(fromSlice("b"))
+}
@@ -0,0 +1,467 @@
+/**
+ * Package rpcqueue exports BlockingQueues over net/rpc, and provides a client
+ * implementing BlockingQueue on top of them, so that code written against
+ * BlockingQueue can use a queue living in another process.
+ *
+ * <p>The elements travel with encoding/gob: register their concrete types with
+ * gob.Register on both sides, the basic types are registered already.
+ */
+package rpcqueue
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"reflect"
+	"sync"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+	"github.com/torchcc/data-structure/queue"
+)
+
+// the name of the service in the rpc server.
+const serviceName = "Queue"
+
+// how long a blocking call waits in the queue at a time, before checking its connection again.
+const waitSlice = 100 * time.Millisecond
+
+// Request is the argument of every remote call.
+type Request struct {
+	// the name of the queue
+	Name     string
+	Element  interface{}
+	Elements []interface{}
+	// how long OfferTimeout and PollTimeout wait, timed by the clock of the server
+	Timeout time.Duration
+}
+
+// Response is the reply of every remote call.
+type Response struct {
+	Element  interface{}
+	Elements []interface{}
+	Ok       bool
+	N        int
+}
+
+/**
+ * A Server exports named BlockingQueues, LinkedBlockingQueues usually. the bulk
+ * removals, RemoveAll, RetainAll and Clear, are done element by element with
+ * Remove and Poll, so that they work with the queues which don't implement
+ * them. a panic of a queue, UnsupportedOperationError for example, is returned
+ * to the client as an error instead of crashing the server.
+ *
+ * <p>The blocking calls wait in the queue by slices of 100ms, and give up once
+ * their connection is lost, so that a Take whose client went away doesn't take
+ * an element nobody will get.
+ */
+type Server struct {
+	lock   *sync.Mutex
+	queues map[string]queue.BlockingQueue
+
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// create a Server with no queue.
+func NewServer() *Server {
+	return &Server{
+		lock:      new(sync.Mutex),
+		queues:    make(map[string]queue.BlockingQueue),
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+/**
+ * @Description: export q under name, replacing the queue exported under it if any.
+ * @param name
+ * @param q if it's nil, NilPointerError will be panic
+ */
+func (s *Server) Register(name string, q queue.BlockingQueue) {
+	if q == nil {
+		panic(NilPointerError)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.queues[name] = q
+}
+
+// stops exporting the queue registered under name.
+func (s *Server) Unregister(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.queues, name)
+}
+
+/**
+ * @Description: accept the connections of l and serve each one in its own goroutine, until Close.
+ * @return error IllegalStateError once the server is closed, or the error of Accept
+ */
+func (s *Server) Serve(l net.Listener) error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		l.Close()
+		return IllegalStateError
+	}
+	s.listeners[l] = struct{}{}
+	s.lock.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.lock.Lock()
+			delete(s.listeners, l)
+			closed := s.closed
+			s.lock.Unlock()
+			if closed {
+				return IllegalStateError
+			}
+			return err
+		}
+		s.lock.Lock()
+		if s.closed {
+			s.lock.Unlock()
+			conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.lock.Unlock()
+		go func() {
+			defer func() {
+				s.lock.Lock()
+				delete(s.conns, conn)
+				s.lock.Unlock()
+				s.wg.Done()
+			}()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// serves conn with an rpc server of its own, whose service knows when conn is lost.
+func (s *Server) serveConn(conn net.Conn) {
+	gone := make(chan struct{})
+	srv := rpc.NewServer()
+	if err := srv.RegisterName(serviceName, &service{s: s, gone: gone}); err != nil {
+		conn.Close()
+		return
+	}
+	srv.ServeConn(&watchedConn{Conn: conn, gone: gone})
+}
+
+/**
+ * A watchedConn closes gone once reading fails. the rpc server keeps reading the
+ * next request while calls are running, so it happens as soon as the client
+ * goes away, not once the calls are done.
+ */
+type watchedConn struct {
+	net.Conn
+	gone chan struct{}
+	once sync.Once
+}
+
+func (c *watchedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if err != nil {
+		c.once.Do(func() { close(c.gone) })
+	}
+	return n, err
+}
+
+/**
+ * Closes the listeners and the connections, and waits for the calls in
+ * progress, the blocking ones give up within 100ms.
+ */
+func (s *Server) Close() error {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return nil
+	}
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	s.lock.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) lookup(name string) (queue.BlockingQueue, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	q, ok := s.queues[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: no such queue %q", NoSuchElementError, name)
+	}
+	return q, nil
+}
+
+// the receiver of the remote calls, its methods are the rpc methods only.
+type service struct {
+	s *Server
+	// closed once the connection of the calls is lost
+	gone <-chan struct{}
+}
+
+// runs f on the queue of req, turning its panics into errors.
+func (svc *service) do(req *Request, f func(q queue.BlockingQueue) error) (err error) {
+	q, err := svc.s.lookup(req.Name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+	return f(q)
+}
+
+/**
+ * @Description: call f by slices until it succeeds, the connection is lost, or deadline passes.
+ *               f is called once at least, with a zero slice if deadline has passed already.
+ * @param deadline zero means no deadline
+ * @return error TimeoutError, or IllegalStateError once the connection is lost
+ */
+func (svc *service) wait(deadline time.Time, f func(slice time.Duration) bool) error {
+	for {
+		slice := waitSlice
+		if !deadline.IsZero() {
+			if left := time.Until(deadline); left < slice {
+				slice = left
+			}
+			if slice < 0 {
+				slice = 0
+			}
+		}
+		if f(slice) {
+			return nil
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return TimeoutError
+		}
+		select {
+		case <-svc.gone:
+			return IllegalStateError
+		default:
+		}
+	}
+}
+
+/**
+ * @Description: offer i for a slice of time.
+ * @return ok whether i was inserted
+ * @return done whether to stop waiting: i was inserted, or refused at once, by a closed queue,
+ *         or a UniqueQueue holding i already for example
+ */
+func offerSlice(q queue.BlockingQueue, i interface{}, slice time.Duration) (ok, done bool) {
+	start := time.Now()
+	ok = q.OfferTimout(i, slice)
+	return ok, ok || time.Since(start) < slice/2
+}
+
+/**
+ * @Description: poll for a slice of time.
+ * @return x the element taken, nil if none
+ * @return done whether to stop waiting: an element was taken, or nil was returned at once, by a
+ *         closed queue for example, whose Take would do the same
+ */
+func pollSlice(q queue.BlockingQueue, slice time.Duration) (x interface{}, done bool) {
+	start := time.Now()
+	x = q.PollTimeout(slice)
+	return x, x != nil || time.Since(start) < slice/2
+}
+
+func (svc *service) Offer(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		resp.Ok = q.Offer(req.Element)
+		return nil
+	})
+}
+
+func (svc *service) Put(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		if req.Element == nil {
+			return NilPointerError
+		}
+		refused := false
+		err := svc.wait(time.Time{}, func(slice time.Duration) bool {
+			ok, done := offerSlice(q, req.Element, slice)
+			refused = !ok && done
+			return done
+		})
+		if refused {
+			// Put tells why.
+			return q.Put(req.Element)
+		}
+		return err
+	})
+}
+
+func (svc *service) OfferTimeout(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		err := svc.wait(time.Now().Add(req.Timeout), func(slice time.Duration) bool {
+			var done bool
+			resp.Ok, done = offerSlice(q, req.Element, slice)
+			return done
+		})
+		if err == TimeoutError {
+			return nil
+		}
+		return err
+	})
+}
+
+func (svc *service) Poll(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		resp.Element = q.Poll()
+		return nil
+	})
+}
+
+func (svc *service) Take(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		return svc.wait(time.Time{}, func(slice time.Duration) bool {
+			var done bool
+			resp.Element, done = pollSlice(q, slice)
+			return done
+		})
+	})
+}
+
+func (svc *service) PollTimeout(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		err := svc.wait(time.Now().Add(req.Timeout), func(slice time.Duration) bool {
+			var done bool
+			resp.Element, done = pollSlice(q, slice)
+			return done
+		})
+		if err == TimeoutError {
+			return nil
+		}
+		return err
+	})
+}
+
+func (svc *service) Peek(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		resp.Element = q.Peek()
+		return nil
+	})
+}
+
+func (svc *service) Len(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		resp.N = q.Len()
+		return nil
+	})
+}
+
+func (svc *service) RemainingCapacity(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		resp.N = q.RemainingCapacity()
+		return nil
+	})
+}
+
+func (svc *service) Contains(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		resp.Ok = q.Contains(req.Element)
+		return nil
+	})
+}
+
+func (svc *service) ToSlice(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		resp.Elements = q.ToSlice()
+		return nil
+	})
+}
+
+// offers the elements until the queue is full, N tells how many were inserted, Ok whether all of them were.
+func (svc *service) AddAll(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		for _, e := range req.Elements {
+			if !q.Offer(e) {
+				return nil
+			}
+			resp.N++
+		}
+		resp.Ok = true
+		return nil
+	})
+}
+
+func (svc *service) Remove(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		resp.Ok = q.Remove(req.Element)
+		return nil
+	})
+}
+
+// removes every occurrence of the elements.
+func (svc *service) RemoveAll(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		for _, e := range req.Elements {
+			for q.Remove(e) {
+				resp.Ok = true
+			}
+		}
+		return nil
+	})
+}
+
+// removes the elements which are not in Elements, compared with == as the local queues do.
+// it returns IllegalArgumentError if an element can't be compared, a slice or a map for example.
+func (svc *service) RetainAll(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		elements := q.ToSlice()
+		if !allComparable(req.Elements) || !allComparable(elements) {
+			return IllegalArgumentError
+		}
+		for _, e := range elements {
+			if !containsElement(req.Elements, e) && q.Remove(e) {
+				resp.Ok = true
+			}
+		}
+		return nil
+	})
+}
+
+func allComparable(elements []interface{}) bool {
+	for _, e := range elements {
+		if e != nil && !reflect.TypeOf(e).Comparable() {
+			return false
+		}
+	}
+	return true
+}
+
+func containsElement(elements []interface{}, e interface{}) bool {
+	for _, x := range elements {
+		if x == e {
+			return true
+		}
+	}
+	return false
+}
+
+func (svc *service) Clear(req *Request, resp *Response) error {
+	return svc.do(req, func(q queue.BlockingQueue) error {
+		for q.Poll() != nil {
+		}
+		return nil
+	})
+}
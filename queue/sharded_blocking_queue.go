@@ -0,0 +1,413 @@
+package queue
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * ShardPlacement picks the shard, among n, an element is put into.
+ */
+type ShardPlacement func(i interface{}, n int) int
+
+/**
+ * @Description: spread the elements evenly over the shards, one after the other.
+ *               with this placement, an insertion into a full shard moves on to the next shards.
+ * @return ShardPlacement
+ */
+func RoundRobinPlacement() ShardPlacement {
+	var counter uint64
+	return func(i interface{}, n int) int {
+		return int(atomic.AddUint64(&counter, 1) % uint64(n))
+	}
+}
+
+/**
+ * @Description: put all the elements with the same key into the same shard,
+ *               so that they keep their relative order.
+ * @param key returns the key of an element, it should be well distributed, a hash for example
+ * @return ShardPlacement
+ */
+func KeyPlacement(key func(i interface{}) uint64) ShardPlacement {
+	if key == nil {
+		panic(NilPointerError)
+	}
+	return func(i interface{}, n int) int {
+		return int(key(i) % uint64(n))
+	}
+}
+
+/**
+ * A BlockingQueue striping its elements over several LinkedBlockingQueues, so
+ * that producers and consumers contend on several locks instead of one.
+ *
+ * <p>Producers put an element into the shard picked by the ShardPlacement.
+ * every consumer call starts at a different shard, in turn, and steals from the
+ * other shards when that one is empty, so no element is left behind while any
+ * consumer is waiting.
+ *
+ * <p><b>Ordering is relaxed</b>: elements are FIFO within a shard only. two
+ * elements put into different shards may be taken in any order, even by a single
+ * consumer, even if they were put by a single producer. with KeyPlacement, the
+ * elements with the same key are kept in order. Peek, Range and ToSlice walk the
+ * shards one after the other, they don't reflect the order of taking.
+ */
+type ShardedBlockingQueue struct {
+	shards     []*LinkedBlockingQueue
+	placement  ShardPlacement
+	roundRobin bool
+
+	// the shard the next consumer call starts at.
+	nextTake uint64
+
+	// Lock and condition consumers wait on when all the shards are empty,
+	// producers only touch them when there are waiting consumers.
+	lock     *sync.Mutex
+	notEmpty *sync.Cond
+	waiters  int32
+}
+
+/**
+ * @Description: create a ShardedBlockingQueue.
+ * @param shards the number of shards, if it's less than 1, IllegalArgumentError will be panic
+ * @param capacity the capacity of every shard, 0 means math.MaxInt32, as for LinkedBlockingQueue
+ * @param placement picks the shard of an element, nil means RoundRobinPlacement
+ * @return *ShardedBlockingQueue
+ */
+func NewShardedBlockingQueue(shards, capacity int, placement ShardPlacement) *ShardedBlockingQueue {
+	if shards < 1 {
+		panic(IllegalArgumentError)
+	}
+	q := &ShardedBlockingQueue{
+		shards:    make([]*LinkedBlockingQueue, shards),
+		placement: placement,
+		lock:      new(sync.Mutex),
+	}
+	for i := range q.shards {
+		q.shards[i] = NewLinkedBlockingQueue(capacity)
+	}
+	if placement == nil {
+		q.placement = RoundRobinPlacement()
+		q.roundRobin = true
+	}
+	q.notEmpty = sync.NewCond(q.lock)
+	return q
+}
+
+func (q *ShardedBlockingQueue) shardOf(i interface{}) int {
+	return q.placement(i, len(q.shards))
+}
+
+// wakes up a waiting consumer, if any. Called after every insertion.
+func (q *ShardedBlockingQueue) signalNotEmpty() {
+	// the waiter registers itself before its last look at the shards, and this is checked after the
+	// insertion, so either the waiter sees the element or the element's producer sees the waiter.
+	if atomic.LoadInt32(&q.waiters) > 0 {
+		q.lock.Lock()
+		defer q.lock.Unlock()
+		q.notEmpty.Signal()
+	}
+}
+
+// offers i to its shard, and with round robin placement to the following shards if it is full.
+func (q *ShardedBlockingQueue) offer(i interface{}) bool {
+	start := q.shardOf(i)
+	tries := 1
+	if q.roundRobin {
+		tries = len(q.shards)
+	}
+	for k := 0; k < tries; k++ {
+		if q.shards[(start+k)%len(q.shards)].Offer(i) {
+			q.signalNotEmpty()
+			return true
+		}
+	}
+	return false
+}
+
+// polls the shards, starting at a different one on every call.
+func (q *ShardedBlockingQueue) poll() interface{} {
+	n := uint64(len(q.shards))
+	start := atomic.AddUint64(&q.nextTake, 1)
+	for k := uint64(0); k < n; k++ {
+		if x := q.shards[(start+k)%n].Poll(); x != nil {
+			return x
+		}
+	}
+	return nil
+}
+
+func (q *ShardedBlockingQueue) Offer(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	return q.offer(i)
+}
+
+func (q *ShardedBlockingQueue) Add(i interface{}) bool {
+	if q.Offer(i) {
+		return true
+	}
+	panic(IllegalStateError)
+}
+
+/**
+ * Inserts the specified element, waiting if necessary for space to become
+ * available in its shard. with round robin placement, it only waits if all the shards are full.
+ */
+func (q *ShardedBlockingQueue) Put(i interface{}) error {
+	if i == nil {
+		return NilPointerError
+	}
+	if q.offer(i) {
+		return nil
+	}
+	if err := q.shards[q.shardOf(i)].Put(i); err != nil {
+		return err
+	}
+	q.signalNotEmpty()
+	return nil
+}
+
+func (q *ShardedBlockingQueue) OfferTimout(i interface{}, timeout time.Duration) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	if q.offer(i) {
+		return true
+	}
+	if !q.shards[q.shardOf(i)].OfferTimout(i, timeout) {
+		return false
+	}
+	q.signalNotEmpty()
+	return true
+}
+
+func (q *ShardedBlockingQueue) Poll() interface{} {
+	return q.poll()
+}
+
+func (q *ShardedBlockingQueue) Take() interface{} {
+	if x := q.poll(); x != nil {
+		return x
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	atomic.AddInt32(&q.waiters, 1)
+	defer atomic.AddInt32(&q.waiters, -1)
+	for {
+		if x := q.poll(); x != nil {
+			return x
+		}
+		q.notEmpty.Wait()
+	}
+}
+
+func (q *ShardedBlockingQueue) PollTimeout(timeout time.Duration) interface{} {
+	if x := q.poll(); x != nil {
+		return x
+	}
+	deadline := time.Now().Add(timeout)
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	atomic.AddInt32(&q.waiters, 1)
+	defer atomic.AddInt32(&q.waiters, -1)
+	for {
+		if x := q.poll(); x != nil {
+			return x
+		}
+		if !waitUntil(q.notEmpty, deadline) {
+			return nil
+		}
+	}
+}
+
+func (q *ShardedBlockingQueue) RemoveHead() interface{} {
+	if x := q.Poll(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+// returns the head of the first non empty shard.
+func (q *ShardedBlockingQueue) Peek() interface{} {
+	for _, s := range q.shards {
+		if x := s.Peek(); x != nil {
+			return x
+		}
+	}
+	return nil
+}
+
+func (q *ShardedBlockingQueue) Element() interface{} {
+	if x := q.Peek(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (q *ShardedBlockingQueue) RemainingCapacity() int {
+	n := 0
+	for _, s := range q.shards {
+		n += s.RemainingCapacity()
+	}
+	return n
+}
+
+func (q *ShardedBlockingQueue) Len() int {
+	n := 0
+	for _, s := range q.shards {
+		n += s.Len()
+	}
+	return n
+}
+
+// returns the number of elements in every shard.
+func (q *ShardedBlockingQueue) ShardLens() []int {
+	lens := make([]int, len(q.shards))
+	for k, s := range q.shards {
+		lens[k] = s.Len()
+	}
+	return lens
+}
+
+func (q *ShardedBlockingQueue) IsEmpty() bool {
+	return q.Len() == 0
+}
+
+func (q *ShardedBlockingQueue) Contains(i interface{}) bool {
+	for _, s := range q.shards {
+		if s.Contains(i) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * @Description: iterate through the shards one after the other, each shard is locked while it's iterated.
+ * @receiver q
+ * @param f return false to stop the iteration
+ */
+func (q *ShardedBlockingQueue) Range(f func(value interface{}) bool) {
+	for _, s := range q.shards {
+		stopped := false
+		s.Range(func(value interface{}) bool {
+			stopped = !f(value)
+			return !stopped
+		})
+		if stopped {
+			return
+		}
+	}
+}
+
+func (q *ShardedBlockingQueue) ToSlice() []interface{} {
+	ret := make([]interface{}, 0, q.Len())
+	for _, s := range q.shards {
+		ret = append(ret, s.ToSlice()...)
+	}
+	return ret
+}
+
+func (q *ShardedBlockingQueue) String() string {
+	return fmt.Sprintf("%v", q.ToSlice())
+}
+
+func (q *ShardedBlockingQueue) Remove(i interface{}) bool {
+	for _, s := range q.shards {
+		if s.Remove(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// lower performance
+func (q *ShardedBlockingQueue) ContainsAll(c Collection) bool {
+	containsAll := true
+	c.Range(func(value interface{}) bool {
+		if !q.Contains(value) {
+			containsAll = false
+			return false
+		}
+		return true
+	})
+	return containsAll
+}
+
+/**
+ * @Description: offers all non-nil elements of c until the queue is full.
+ *               nil element in c will be skipped. it'll return FullError/ NilPointerError to indicate error.
+ * @receiver q
+ * @param c if c is nil, NilPointerError is returned
+ * @return bool indicates whether the queue has been changed or not when the func return
+ */
+func (q *ShardedBlockingQueue) AddAll(c Collection) (modified bool, err error) {
+	if c == nil {
+		return false, NilPointerError
+	}
+	for _, e := range c.ToSlice() {
+		if e == nil {
+			err = NilPointerError
+			continue
+		}
+		if !q.offer(e) {
+			return modified, FullError
+		}
+		modified = true
+	}
+	return
+}
+
+func (q *ShardedBlockingQueue) RemoveAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	elements := c.ToSlice()
+	return q.RemoveIf(func(value interface{}) bool {
+		return sliceContains(elements, value)
+	})
+}
+
+/**
+ * Removes all of the elements that satisfy the given predicate, shard by shard, not atomically.
+ */
+func (q *ShardedBlockingQueue) RemoveIf(filter func(value interface{}) bool) bool {
+	if filter == nil {
+		panic(NilPointerError)
+	}
+	removed := false
+	for _, s := range q.shards {
+		for _, e := range s.ToSlice() {
+			if filter(e) && s.Remove(e) {
+				removed = true
+			}
+		}
+	}
+	return removed
+}
+
+func (q *ShardedBlockingQueue) RetainAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	elements := c.ToSlice()
+	return q.RemoveIf(func(value interface{}) bool {
+		return !sliceContains(elements, value)
+	})
+}
+
+/**
+ * Removes all of the elements, shard by shard, not atomically.
+ */
+func (q *ShardedBlockingQueue) Clear() {
+	for _, s := range q.shards {
+		for s.Poll() != nil {
+		}
+	}
+}
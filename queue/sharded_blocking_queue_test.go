@@ -0,0 +1,108 @@
+package queue
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+var _ BlockingQueue = (*ShardedBlockingQueue)(nil)
+
+func TestShardedBlockingQueue_RoundRobin(t *testing.T) {
+	q := NewShardedBlockingQueue(4, 2, nil)
+	for i := 0; i < 8; i++ {
+		if !q.Offer(i) {
+			t.Fatalf("Offer %d failed, shards: %v", i, q.ShardLens())
+		}
+	}
+	if q.Offer(8) || q.RemainingCapacity() != 0 {
+		t.Fatal("all the shards should be full")
+	}
+	for k, n := range q.ShardLens() {
+		if n != 2 {
+			t.Fatalf("shard %d holds %d elements", k, n)
+		}
+	}
+	seen := make(map[interface{}]bool)
+	for x := q.Poll(); x != nil; x = q.Poll() {
+		seen[x] = true
+	}
+	if len(seen) != 8 || !q.IsEmpty() {
+		t.Fatalf("want 8 distinct elements, got %v", seen)
+	}
+}
+
+func TestShardedBlockingQueue_KeyPlacementKeepsOrder(t *testing.T) {
+	type event struct{ key, seq int }
+	q := NewShardedBlockingQueue(3, 0, KeyPlacement(func(i interface{}) uint64 {
+		return uint64(i.(event).key)
+	}))
+	for seq := 0; seq < 100; seq++ {
+		q.Put(event{key: seq % 5, seq: seq})
+	}
+	last := map[int]int{}
+	for x := q.Poll(); x != nil; x = q.Poll() {
+		e := x.(event)
+		if prev, ok := last[e.key]; ok && prev > e.seq {
+			t.Fatalf("key %d: %d taken after %d", e.key, e.seq, prev)
+		}
+		last[e.key] = e.seq
+	}
+}
+
+func TestShardedBlockingQueue_TakeStealsAndWakesUp(t *testing.T) {
+	// everything goes to shard 0, consumers starting elsewhere must steal it.
+	q := NewShardedBlockingQueue(4, 0, func(i interface{}, n int) int { return 0 })
+	got := make(chan interface{})
+	go func() { got <- q.Take() }()
+	time.Sleep(20 * time.Millisecond)
+	q.Put("x")
+	select {
+	case x := <-got:
+		if x != "x" {
+			t.Fatalf("Take: got %v", x)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Take was not woken up")
+	}
+	if x := q.PollTimeout(20 * time.Millisecond); x != nil {
+		t.Fatalf("PollTimeout: got %v", x)
+	}
+}
+
+func TestShardedBlockingQueue_Concurrent(t *testing.T) {
+	const producers, consumers, perProducer = 8, 4, 1000
+	q := NewShardedBlockingQueue(4, 16, nil)
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				q.Put(p*perProducer + i)
+			}
+		}(p)
+	}
+	results := make(chan interface{}, producers*perProducer)
+	for c := 0; c < consumers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < producers*perProducer/consumers; i++ {
+				results <- q.Take()
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+	seen := make(map[interface{}]bool)
+	for x := range results {
+		if seen[x] {
+			t.Fatalf("element %v taken twice", x)
+		}
+		seen[x] = true
+	}
+	if len(seen) != producers*perProducer {
+		t.Fatalf("want %d elements, got %d", producers*perProducer, len(seen))
+	}
+}
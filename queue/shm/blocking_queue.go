@@ -0,0 +1,261 @@
+//go:build linux
+// +build linux
+
+package shm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+	"github.com/torchcc/data-structure/queue"
+)
+
+/**
+ * A BlockingQueue is a queue.BlockingQueue over a shared-memory Queue, its
+ * elements are turned into messages by a queue.Codec, as in a DiskQueue.
+ *
+ * <p>An element whose message is larger than the slot size is refused like an
+ * element of a full queue, but Put returns IllegalArgumentError. a message
+ * which can't be decoded is dropped, and the error is kept, see Err. a dead
+ * peer noticed while waiting is not reported, the waiting goes on.
+ *
+ * <p>The messages can only be taken at the head: Peek, Element, the iteration
+ * and the removal of given elements panic UnsupportedOperationError.
+ */
+type BlockingQueue struct {
+	q     *Queue
+	codec queue.Codec
+
+	lock sync.Mutex
+	err  error
+}
+
+/**
+ * @Description: create a BlockingQueue over q, which is still closed by its owner.
+ * @param q, codec if either is nil, NilPointerError will be panic
+ * @return *BlockingQueue
+ */
+func NewBlockingQueue(q *Queue, codec queue.Codec) *BlockingQueue {
+	if q == nil || codec == nil {
+		panic(NilPointerError)
+	}
+	return &BlockingQueue{q: q, codec: codec}
+}
+
+func (b *BlockingQueue) fail(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.err = err
+}
+
+// the last codec error, if any.
+func (b *BlockingQueue) Err() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.err
+}
+
+func (b *BlockingQueue) encode(i interface{}) ([]byte, error) {
+	m, err := b.codec.Encode(i)
+	if err != nil {
+		b.fail(err)
+		return nil, err
+	}
+	if m == nil {
+		m = []byte{}
+	}
+	if len(m) > b.q.SlotSize() {
+		return nil, IllegalArgumentError
+	}
+	return m, nil
+}
+
+// decodes a message taken, nil stands for no message.
+func (b *BlockingQueue) decode(m []byte) interface{} {
+	if m == nil {
+		return nil
+	}
+	x, err := b.codec.Decode(m)
+	if err != nil {
+		b.fail(err)
+		return nil
+	}
+	return x
+}
+
+// returns false if the queue is full, or i can't be encoded into a slot, see Err.
+func (b *BlockingQueue) Offer(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	m, err := b.encode(i)
+	return err == nil && b.q.Offer(m)
+}
+
+func (b *BlockingQueue) Add(i interface{}) bool {
+	if b.Offer(i) {
+		return true
+	}
+	panic(IllegalStateError)
+}
+
+/**
+ * Inserts the specified element at the tail of this queue, waiting if
+ * necessary for space to become available.
+ * returns NilPointerError, the encoding error, or IllegalArgumentError if the message is larger than a slot.
+ */
+func (b *BlockingQueue) Put(i interface{}) error {
+	if i == nil {
+		return NilPointerError
+	}
+	m, err := b.encode(i)
+	if err != nil {
+		return err
+	}
+	for {
+		if err := b.q.Put(m); err != PeerDeadError {
+			return err
+		}
+	}
+}
+
+func (b *BlockingQueue) OfferTimout(i interface{}, timeout time.Duration) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	m, err := b.encode(i)
+	if err != nil {
+		return false
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		if b.q.OfferTimeout(m, time.Until(deadline)) {
+			return true
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+	}
+}
+
+// returns nil if the message taken can't be decoded, see Err.
+func (b *BlockingQueue) Take() interface{} {
+	for {
+		if m, err := b.q.Take(); err != PeerDeadError {
+			return b.decode(m)
+		}
+	}
+}
+
+func (b *BlockingQueue) Poll() interface{} {
+	return b.decode(b.q.Poll())
+}
+
+func (b *BlockingQueue) PollTimeout(timeout time.Duration) interface{} {
+	deadline := time.Now().Add(timeout)
+	for {
+		if m := b.q.PollTimeout(time.Until(deadline)); m != nil || !time.Now().Before(deadline) {
+			return b.decode(m)
+		}
+	}
+}
+
+func (b *BlockingQueue) RemoveHead() interface{} {
+	if x := b.Poll(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (b *BlockingQueue) Peek() interface{} {
+	panic(UnsupportedOperationError)
+}
+
+func (b *BlockingQueue) Element() interface{} {
+	panic(UnsupportedOperationError)
+}
+
+func (b *BlockingQueue) RemainingCapacity() int {
+	return b.q.RemainingCapacity()
+}
+
+func (b *BlockingQueue) Len() int {
+	return b.q.Len()
+}
+
+func (b *BlockingQueue) IsEmpty() bool {
+	return b.q.IsEmpty()
+}
+
+func (b *BlockingQueue) Contains(i interface{}) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (b *BlockingQueue) Range(f func(value interface{}) bool) {
+	panic(UnsupportedOperationError)
+}
+
+func (b *BlockingQueue) ToSlice() []interface{} {
+	panic(UnsupportedOperationError)
+}
+
+func (b *BlockingQueue) String() string {
+	return fmt.Sprintf("shm.BlockingQueue(%d/%d)", b.Len(), b.q.Cap())
+}
+
+func (b *BlockingQueue) ContainsAll(c queue.Collection) bool {
+	panic(UnsupportedOperationError)
+}
+
+/**
+ * @Description: offers all non-nil elements of c until the queue is full.
+ *               nil element in c will be skipped. it'll return FullError/ NilPointerError, or the error
+ *               of the codec, to indicate error.
+ * @receiver b
+ * @param c if c is nil, NilPointerError is returned
+ * @return bool indicates whether the queue has been changed or not when the func return
+ */
+func (b *BlockingQueue) AddAll(c queue.Collection) (modified bool, err error) {
+	if c == nil {
+		return false, NilPointerError
+	}
+	for _, e := range c.ToSlice() {
+		if e == nil {
+			err = NilPointerError
+			continue
+		}
+		m, eerr := b.encode(e)
+		if eerr != nil {
+			return modified, eerr
+		}
+		if !b.q.Offer(m) {
+			return modified, FullError
+		}
+		modified = true
+	}
+	return
+}
+
+func (b *BlockingQueue) Remove(i interface{}) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (b *BlockingQueue) RemoveAll(c queue.Collection) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (b *BlockingQueue) RemoveIf(filter func(value interface{}) bool) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (b *BlockingQueue) RetainAll(c queue.Collection) bool {
+	panic(UnsupportedOperationError)
+}
+
+// takes the messages until the queue is empty. messages offered concurrently may or may not be removed.
+func (b *BlockingQueue) Clear() {
+	for b.q.Poll() != nil {
+	}
+}
@@ -0,0 +1,67 @@
+//go:build linux
+// +build linux
+
+package shm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+	"github.com/torchcc/data-structure/queue"
+)
+
+var _ queue.BlockingQueue = (*BlockingQueue)(nil)
+
+// a StringCodec which can't decode "bad".
+type pickyCodec struct {
+	queue.StringCodec
+}
+
+var errBad = errors.New("bad message")
+
+func (c pickyCodec) Decode(data []byte) (interface{}, error) {
+	if string(data) == "bad" {
+		return nil, errBad
+	}
+	return c.StringCodec.Decode(data)
+}
+
+func TestBlockingQueue_Codec(t *testing.T) {
+	a, b, _ := openPair(t, Options{Slots: 2, SlotSize: 8})
+	producer := NewBlockingQueue(a, queue.StringCodec{})
+	consumer := NewBlockingQueue(b, pickyCodec{})
+
+	if err := producer.Put("123456789"); err != IllegalArgumentError {
+		t.Fatalf("want IllegalArgumentError for a message larger than a slot, got %v", err)
+	}
+	if producer.Offer(42) || producer.Err() == nil {
+		t.Fatal("an element the codec can't encode should be refused, and the error kept")
+	}
+	if err := producer.Put("x"); err != nil {
+		t.Fatal(err)
+	}
+	if !producer.Offer("y") || producer.OfferTimout("z", 10*time.Millisecond) || producer.RemainingCapacity() != 0 {
+		t.Fatal("want a full queue of 2")
+	}
+	if x := consumer.Take(); x != "x" {
+		t.Fatalf("want x, got %v", x)
+	}
+	if x := consumer.PollTimeout(time.Second); x != "y" {
+		t.Fatalf("want y, got %v", x)
+	}
+	if x := consumer.PollTimeout(10 * time.Millisecond); x != nil || !consumer.IsEmpty() {
+		t.Fatalf("want nothing, got %v", x)
+	}
+
+	producer.Put("bad")
+	if x := consumer.Poll(); x != nil || consumer.Err() != errBad {
+		t.Fatalf("want the bad message dropped and the error kept, got %v %v", x, consumer.Err())
+	}
+	producer.Put("w")
+	consumer.Clear()
+	if consumer.Len() != 0 {
+		t.Fatal("want an empty queue after Clear")
+	}
+}
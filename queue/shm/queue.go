@@ -0,0 +1,652 @@
+//go:build linux
+// +build linux
+
+/**
+ * Package shm provides a bounded queue of byte messages in shared memory, for
+ * processes of the same host to exchange small messages.
+ */
+package shm
+
+import (
+	"bytes"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unsafe"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+const (
+	magic   = "SHMRINGQ"
+	version = 1
+
+	// the header takes the first page, the fields written concurrently are on cache lines of their own.
+	offMagic       = 0
+	offVersion     = 8
+	offSlotSize    = 12
+	offSlots       = 16
+	offInitialized = 24
+	offLastPeerID  = 28
+	offEnqueuePos  = 64
+	offDequeuePos  = 128
+	// a peer is [uint64 id<<32 | pid][uint64 heartbeat].
+	offPeers      = 192
+	peerEntrySize = 16
+	maxPeers      = 64
+	headerSize    = 4096
+
+	// a slot is [uint64 sequence][uint32 claimer peer id][uint32 length][data], 8 bytes aligned.
+	slotHeaderSize = 16
+	offSlotClaimer = 8
+	offSlotLength  = 12
+	// the length of a slot given up by a dead producer, the consumers skip it.
+	tombstone = ^uint32(0)
+
+	// how often the waiting methods look for dead peers.
+	peerCheckInterval = 100 * time.Millisecond
+	// how often a process bumps its heartbeat, and how long a heartbeat may stand still before its process is dead.
+	heartbeatInterval = 100 * time.Millisecond
+	peerTimeout       = time.Second
+	// how long Open waits for the creator of the file to initialize it.
+	attachTimeout = 5 * time.Second
+)
+
+// Options are the geometry of a queue, they are fixed when the file is created.
+type Options struct {
+	// the number of slots, a power of 2, 0 means 1024
+	Slots int
+	// the largest message, in bytes, 0 means 256
+	SlotSize int
+}
+
+/**
+ * A Queue is a bounded multi-producer multi-consumer FIFO queue of byte
+ * messages, in a file mapped into the memory of every process using it, a file
+ * in /dev/shm usually. it's the bounded queue of Dmitry Vyukov: the producers
+ * and the consumers claim slots by moving the enqueue and dequeue cursors with
+ * atomic operations, every slot has a sequence number telling whether it's free
+ * or holds a message.
+ *
+ * <p>Every process attached registers in a table in the file, under a peer id
+ * which is never reused, and bumps a heartbeat counter of its entry every 100ms
+ * until Close. a peer whose heartbeat has not moved for a second is dead, so the
+ * processes don't need to see each other's pids, they may be containers which
+ * don't share a PID namespace. a process stopped for a second counts as dead.
+ *
+ * <p>Before moving a cursor, a process stamps its peer id on the slot, with a
+ * compare-and-swap from 0, and it clears the stamp once the sequence of the slot
+ * is updated. so a slot whose cursor has moved always tells who is working on
+ * it, and a stamp left by a dead process can be taken over by another one.
+ *
+ * <p>There is no futex, the waiting methods poll with an increasing backoff, up
+ * to a millisecond.
+ *
+ * <p>While they wait, the methods look for dead peers every 100ms: a dead peer
+ * is removed from the table, and the waiting method returns PeerDeadError, to
+ * the first process noticing only. a slot claimed by a producer which died
+ * before publishing it is skipped by the consumers, and a slot claimed by a
+ * consumer which died before freeing it is freed by the producers, its message
+ * is lost, instead of blocking the queue forever.
+ *
+ * <p>A Queue is safe for concurrent use by the goroutines of a process too.
+ */
+type Queue struct {
+	file      *os.File
+	mem       []byte
+	slots     uint64
+	slotSize  int
+	slotBytes uint64
+	pid       uint32
+	id        uint32
+	// the entry of the peer table holding id
+	peer int
+	// stop the heartbeat goroutine, which closes beating when it returns
+	stop    chan struct{}
+	beating chan struct{}
+
+	lock sync.Mutex
+	// the heartbeats of the other peers, as last seen
+	beats map[uint32]heartbeat
+}
+
+type heartbeat struct {
+	count uint64
+	// when count was first seen
+	since time.Time
+}
+
+func (o Options) withDefaults() Options {
+	if o.Slots == 0 {
+		o.Slots = 1024
+	}
+	if o.SlotSize == 0 {
+		o.SlotSize = 256
+	}
+	return o
+}
+
+func slotBytes(slotSize int) uint64 {
+	return uint64(slotHeaderSize+slotSize+7) &^ 7
+}
+
+/**
+ * @Description: create the queue file at path, or attach to it if it exists.
+ * @param path the file, in /dev/shm to stay in memory
+ * @param opts the geometry of a new queue. when attaching, the geometry of the file wins,
+ *        unless opts are set and don't match it, then IllegalArgumentError is returned.
+ * @return *Queue
+ * @return error IllegalArgumentError for invalid options, IllegalStateError if the peer table is full,
+ *         TimeoutError if the creator didn't initialize the file in time, or the error of the system
+ */
+func Open(path string, opts Options) (*Queue, error) {
+	given := opts
+	opts = opts.withDefaults()
+	if opts.Slots < 2 || opts.Slots&(opts.Slots-1) != 0 || opts.SlotSize < 1 || opts.SlotSize >= int(tombstone) {
+		return nil, IllegalArgumentError
+	}
+	q := &Queue{pid: uint32(os.Getpid()), peer: -1, beats: make(map[uint32]heartbeat)}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err == nil {
+		q.file = f
+		err = q.create(opts)
+	} else if os.IsExist(err) {
+		if q.file, err = os.OpenFile(path, os.O_RDWR, 0600); err == nil {
+			err = q.attach(given)
+		}
+	}
+	if err == nil {
+		err = q.register()
+	}
+	if err != nil {
+		q.release()
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *Queue) mmap(size int) error {
+	mem, err := syscall.Mmap(int(q.file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	q.mem = mem
+	return nil
+}
+
+func (q *Queue) setGeometry(slots uint64, slotSize int) {
+	q.slots = slots
+	q.slotSize = slotSize
+	q.slotBytes = slotBytes(slotSize)
+}
+
+func (q *Queue) create(opts Options) error {
+	q.setGeometry(uint64(opts.Slots), opts.SlotSize)
+	size := headerSize + int(q.slots*q.slotBytes)
+	if err := q.file.Truncate(int64(size)); err != nil {
+		return err
+	}
+	if err := q.mmap(size); err != nil {
+		return err
+	}
+	copy(q.mem[offMagic:], magic)
+	*q.u32(offVersion) = version
+	*q.u32(offSlotSize) = uint32(opts.SlotSize)
+	*q.u64(offSlots) = q.slots
+	for i := uint64(0); i < q.slots; i++ {
+		atomic.StoreUint64(q.u64(q.slotOffset(i)), i)
+	}
+	// the attaching processes wait for this.
+	atomic.StoreUint32(q.u32(offInitialized), 1)
+	return nil
+}
+
+func (q *Queue) attach(opts Options) error {
+	deadline := time.Now().Add(attachTimeout)
+	var b backoff
+	for {
+		// the creator may not have sized the file yet.
+		if st, err := q.file.Stat(); err != nil {
+			return err
+		} else if st.Size() >= headerSize {
+			if q.mem == nil {
+				if err := q.mmap(headerSize); err != nil {
+					return err
+				}
+			}
+			if atomic.LoadUint32(q.u32(offInitialized)) == 1 {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			return TimeoutError
+		}
+		b.wait()
+	}
+	if !bytes.Equal(q.mem[offMagic:offMagic+len(magic)], []byte(magic)) || *q.u32(offVersion) != version {
+		return IllegalArgumentError
+	}
+	slots, slotSize := *q.u64(offSlots), int(*q.u32(offSlotSize))
+	if opts.Slots != 0 && uint64(opts.Slots) != slots || opts.SlotSize != 0 && opts.SlotSize != slotSize {
+		return IllegalArgumentError
+	}
+	q.setGeometry(slots, slotSize)
+	syscall.Munmap(q.mem)
+	q.mem = nil
+	return q.mmap(headerSize + int(q.slots*q.slotBytes))
+}
+
+func (q *Queue) u64(off uint64) *uint64 {
+	return (*uint64)(unsafe.Pointer(&q.mem[off]))
+}
+
+func (q *Queue) u32(off uint64) *uint32 {
+	return (*uint32)(unsafe.Pointer(&q.mem[off]))
+}
+
+func (q *Queue) slotOffset(pos uint64) uint64 {
+	return headerSize + (pos&(q.slots-1))*q.slotBytes
+}
+
+// the id and the pid of a peer, in one word so that they are set and cleared together.
+func (q *Queue) peerEntry(k int) *uint64 {
+	return q.u64(offPeers + uint64(k)*peerEntrySize)
+}
+
+func (q *Queue) heartbeatOf(k int) *uint64 {
+	return q.u64(offPeers + uint64(k)*peerEntrySize + 8)
+}
+
+func peerID(entry uint64) uint32 {
+	return uint32(entry >> 32)
+}
+
+// takes a free entry of the peer table, under a new peer id, and starts the heartbeat.
+func (q *Queue) register() error {
+	for q.id == 0 {
+		q.id = atomic.AddUint32(q.u32(offLastPeerID), 1)
+	}
+	entry := uint64(q.id)<<32 | uint64(q.pid)
+	for k := 0; k < maxPeers; k++ {
+		if atomic.CompareAndSwapUint64(q.peerEntry(k), 0, entry) {
+			q.peer = k
+			q.stop = make(chan struct{})
+			q.beating = make(chan struct{})
+			go q.heartbeat(q.heartbeatOf(k))
+			return nil
+		}
+	}
+	return IllegalStateError
+}
+
+func (q *Queue) heartbeat(count *uint64) {
+	defer close(q.beating)
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+			atomic.AddUint64(count, 1)
+		}
+	}
+}
+
+// whether the peer with the given id is attached, and its heartbeat has moved lately.
+func (q *Queue) alive(id uint32) bool {
+	if id == q.id {
+		return true
+	}
+	for k := 0; k < maxPeers; k++ {
+		if peerID(atomic.LoadUint64(q.peerEntry(k))) == id {
+			return q.beatingSince(id, atomic.LoadUint64(q.heartbeatOf(k)), time.Now())
+		}
+	}
+	// it has left the table, it's closed or found dead.
+	return false
+}
+
+// records the heartbeat count of a peer, and tells whether it has moved within peerTimeout.
+// the peers are timed by the clock of this process only, their counts are just compared.
+func (q *Queue) beatingSince(id uint32, count uint64, now time.Time) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if b, ok := q.beats[id]; ok && b.count == count {
+		return now.Sub(b.since) < peerTimeout
+	}
+	q.beats[id] = heartbeat{count: count, since: now}
+	return true
+}
+
+/**
+ * @Description: remove the dead processes from the peer table.
+ * @return []int the pids of the dead processes, as seen by the processes themselves, every dead peer
+ *         is returned once, to a single caller.
+ */
+func (q *Queue) DeadPeers() []int {
+	var dead []int
+	attached := make(map[uint32]bool)
+	for k := 0; k < maxPeers; k++ {
+		entry := atomic.LoadUint64(q.peerEntry(k))
+		if entry == 0 {
+			continue
+		}
+		if !q.alive(peerID(entry)) && atomic.CompareAndSwapUint64(q.peerEntry(k), entry, 0) {
+			dead = append(dead, int(uint32(entry)))
+		} else {
+			attached[peerID(entry)] = true
+		}
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for id := range q.beats {
+		if !attached[id] {
+			delete(q.beats, id)
+		}
+	}
+	return dead
+}
+
+// the number of processes attached, dead ones included until they are noticed.
+func (q *Queue) Peers() int {
+	n := 0
+	for k := 0; k < maxPeers; k++ {
+		if atomic.LoadUint64(q.peerEntry(k)) != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (q *Queue) claimer(off uint64) *uint32 {
+	return q.u32(off + offSlotClaimer)
+}
+
+// stamps the slot at off with the peer id, unless a live process has stamped it already.
+func (q *Queue) stamp(off uint64) bool {
+	c := atomic.LoadUint32(q.claimer(off))
+	if c != 0 && q.alive(c) {
+		return false
+	}
+	return atomic.CompareAndSwapUint32(q.claimer(off), c, q.id)
+}
+
+func (q *Queue) unstamp(off uint64) {
+	atomic.StoreUint32(q.claimer(off), 0)
+}
+
+func (q *Queue) tryEnqueue(b []byte) bool {
+	for {
+		pos := atomic.LoadUint64(q.u64(offEnqueuePos))
+		off := q.slotOffset(pos)
+		seq := atomic.LoadUint64(q.u64(off))
+		if diff := int64(seq - pos); diff < 0 {
+			// the slot still holds the message of the previous round, full, unless its consumer died.
+			if pos >= q.slots && atomic.LoadUint64(q.u64(offDequeuePos)) > pos-q.slots && q.reap(off, pos-q.slots+1, pos) {
+				continue
+			}
+			return false
+		} else if diff > 0 {
+			// another producer took pos.
+			continue
+		}
+		if !q.stamp(off) {
+			// another producer is taking pos.
+			runtime.Gosched()
+			continue
+		}
+		// the slot may have been taken, and even consumed, between the loads and the stamp.
+		if atomic.LoadUint64(q.u64(off)) != pos || !atomic.CompareAndSwapUint64(q.u64(offEnqueuePos), pos, pos+1) {
+			q.unstamp(off)
+			continue
+		}
+		copy(q.mem[off+slotHeaderSize:], b)
+		atomic.StoreUint32(q.u32(off+offSlotLength), uint32(len(b)))
+		atomic.StoreUint64(q.u64(off), pos+1)
+		q.unstamp(off)
+		return true
+	}
+}
+
+func (q *Queue) tryDequeue() ([]byte, bool) {
+	for {
+		pos := atomic.LoadUint64(q.u64(offDequeuePos))
+		off := q.slotOffset(pos)
+		seq := atomic.LoadUint64(q.u64(off))
+		if diff := int64(seq - (pos + 1)); diff < 0 {
+			// empty, or claimed by a producer which has not published yet, or died.
+			if atomic.LoadUint64(q.u64(offEnqueuePos)) > pos && q.reap(off, pos, pos+1) {
+				continue
+			}
+			return nil, false
+		} else if diff > 0 {
+			// another consumer took pos.
+			continue
+		}
+		if !q.stamp(off) {
+			// another consumer is taking pos, or the producer is clearing its stamp.
+			runtime.Gosched()
+			continue
+		}
+		if atomic.LoadUint64(q.u64(off)) != pos+1 || !atomic.CompareAndSwapUint64(q.u64(offDequeuePos), pos, pos+1) {
+			q.unstamp(off)
+			continue
+		}
+		n := atomic.LoadUint32(q.u32(off + offSlotLength))
+		var b []byte
+		if n != tombstone {
+			b = make([]byte, n)
+			copy(b, q.mem[off+slotHeaderSize:])
+		}
+		atomic.StoreUint64(q.u64(off), pos+q.slots)
+		q.unstamp(off)
+		if b != nil {
+			return b, true
+		}
+	}
+}
+
+/**
+ * @Description: move the sequence of the slot at off from seq to next, if the process whose stamp is on it
+ *               is dead. a slot left by a producer becomes a tombstone, the message of a slot left by a
+ *               consumer is lost.
+ * @return bool whether the slot was taken over
+ */
+func (q *Queue) reap(off, seq, next uint64) bool {
+	c := atomic.LoadUint32(q.claimer(off))
+	if c == 0 || q.alive(c) || !atomic.CompareAndSwapUint32(q.claimer(off), c, q.id) {
+		return false
+	}
+	if atomic.LoadUint64(q.u64(off)) == seq {
+		if next == seq+1 {
+			atomic.StoreUint32(q.u32(off+offSlotLength), tombstone)
+		}
+		atomic.StoreUint64(q.u64(off), next)
+	}
+	q.unstamp(off)
+	return true
+}
+
+type backoff struct {
+	n int
+}
+
+// spins a little, then sleeps longer and longer, up to a millisecond.
+func (b *backoff) wait() {
+	b.n++
+	if b.n < 10 {
+		runtime.Gosched()
+		return
+	}
+	d := time.Microsecond << uint(b.n-10)
+	if d > time.Millisecond {
+		d = time.Millisecond
+	}
+	time.Sleep(d)
+}
+
+/**
+ * @Description: poll f until it succeeds, the deadline passes, or a dead peer is noticed.
+ * @param deadline zero means no deadline
+ * @return error TimeoutError or PeerDeadError
+ */
+func (q *Queue) waitFor(f func() bool, deadline time.Time) error {
+	var b backoff
+	nextCheck := time.Now().Add(peerCheckInterval)
+	for !f() {
+		now := time.Now()
+		if !deadline.IsZero() && now.After(deadline) {
+			return TimeoutError
+		}
+		if now.After(nextCheck) {
+			if len(q.DeadPeers()) > 0 {
+				return PeerDeadError
+			}
+			nextCheck = now.Add(peerCheckInterval)
+		}
+		b.wait()
+	}
+	return nil
+}
+
+func (q *Queue) checkMessage(b []byte) {
+	if b == nil {
+		panic(NilPointerError)
+	}
+	if len(b) > q.slotSize {
+		panic(IllegalArgumentError)
+	}
+}
+
+// inserts a copy of b if the queue is not full. b longer than the slot size panics IllegalArgumentError.
+func (q *Queue) Offer(b []byte) bool {
+	q.checkMessage(b)
+	return q.tryEnqueue(b)
+}
+
+/**
+ * @Description: insert a copy of b, waiting if necessary for a free slot.
+ * @return error NilPointerError, IllegalArgumentError if b is longer than the slot size,
+ *         or PeerDeadError if a dead peer was noticed while waiting
+ */
+func (q *Queue) Put(b []byte) error {
+	if b == nil {
+		return NilPointerError
+	}
+	if len(b) > q.slotSize {
+		return IllegalArgumentError
+	}
+	return q.waitFor(func() bool { return q.tryEnqueue(b) }, time.Time{})
+}
+
+// returns false if the queue is still full after timeout, or if a dead peer was noticed.
+func (q *Queue) OfferTimeout(b []byte, timeout time.Duration) bool {
+	q.checkMessage(b)
+	return q.waitFor(func() bool { return q.tryEnqueue(b) }, time.Now().Add(timeout)) == nil
+}
+
+// takes the head, or returns nil if the queue is empty.
+func (q *Queue) Poll() []byte {
+	b, _ := q.tryDequeue()
+	return b
+}
+
+/**
+ * @Description: take the head, waiting if necessary until a message is available.
+ * @return error PeerDeadError if a dead peer was noticed while waiting
+ */
+func (q *Queue) Take() ([]byte, error) {
+	var b []byte
+	err := q.waitFor(func() bool {
+		var ok bool
+		b, ok = q.tryDequeue()
+		return ok
+	}, time.Time{})
+	return b, err
+}
+
+// takes the head, or returns nil if the queue is still empty after timeout, or if a dead peer was noticed.
+func (q *Queue) PollTimeout(timeout time.Duration) []byte {
+	var b []byte
+	q.waitFor(func() bool {
+		var ok bool
+		b, ok = q.tryDequeue()
+		return ok
+	}, time.Now().Add(timeout))
+	return b
+}
+
+// the number of messages, claimed slots not published yet included.
+func (q *Queue) Len() int {
+	deq := atomic.LoadUint64(q.u64(offDequeuePos))
+	enq := atomic.LoadUint64(q.u64(offEnqueuePos))
+	if enq <= deq {
+		return 0
+	}
+	if n := enq - deq; n < q.slots {
+		return int(n)
+	}
+	return int(q.slots)
+}
+
+func (q *Queue) IsEmpty() bool {
+	return q.Len() == 0
+}
+
+// the number of slots.
+func (q *Queue) Cap() int {
+	return int(q.slots)
+}
+
+func (q *Queue) RemainingCapacity() int {
+	return q.Cap() - q.Len()
+}
+
+// the largest message, in bytes.
+func (q *Queue) SlotSize() int {
+	return q.slotSize
+}
+
+// stops the heartbeat, and waits for it, the memory it writes to is about to be unmapped.
+func (q *Queue) stopHeartbeat() {
+	if q.stop != nil {
+		close(q.stop)
+		<-q.beating
+		q.stop = nil
+	}
+}
+
+func (q *Queue) release() {
+	q.stopHeartbeat()
+	if q.mem != nil {
+		syscall.Munmap(q.mem)
+		q.mem = nil
+	}
+	if q.file != nil {
+		q.file.Close()
+		q.file = nil
+	}
+}
+
+// leaves the peer table and unmaps the file, which is not removed.
+func (q *Queue) Close() error {
+	if q.mem == nil {
+		return nil
+	}
+	q.stopHeartbeat()
+	if q.peer >= 0 {
+		atomic.CompareAndSwapUint64(q.peerEntry(q.peer), uint64(q.id)<<32|uint64(q.pid), 0)
+		q.peer = -1
+	}
+	err := syscall.Munmap(q.mem)
+	q.mem = nil
+	if cerr := q.file.Close(); err == nil {
+		err = cerr
+	}
+	q.file = nil
+	return err
+}
@@ -0,0 +1,251 @@
+//go:build linux
+// +build linux
+
+package shm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+// run by a child process, see runPeer.
+func TestHelperProcess(t *testing.T) {
+	path := os.Getenv("SHM_QUEUE_PATH")
+	if path == "" {
+		return
+	}
+	q, err := Open(path, Options{})
+	if err != nil {
+		os.Exit(1)
+	}
+	q.Put([]byte("from child"))
+	// dies without Close.
+	os.Exit(0)
+}
+
+// starts a process which attaches to the queue, puts a message and dies, returns its pid.
+func runPeer(t *testing.T, path string) int {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+	cmd.Env = append(os.Environ(), "SHM_QUEUE_PATH="+path)
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+// the peer id of the process with the given pid, while it's in the peer table.
+func idOf(t *testing.T, q *Queue, pid int) uint32 {
+	for k := 0; k < maxPeers; k++ {
+		if entry := atomic.LoadUint64(q.peerEntry(k)); entry != 0 && int(uint32(entry)) == pid {
+			return peerID(entry)
+		}
+	}
+	t.Fatalf("pid %d is not a peer", pid)
+	return 0
+}
+
+func openPair(t *testing.T, opts Options) (*Queue, *Queue, string) {
+	path := filepath.Join(t.TempDir(), "queue")
+	a, err := Open(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Open(path, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return a, b, path
+}
+
+func TestQueue_FIFO(t *testing.T) {
+	a, b, _ := openPair(t, Options{Slots: 4, SlotSize: 8})
+	if b.Cap() != 4 || b.SlotSize() != 8 || a.Peers() != 2 {
+		t.Fatalf("geometry %d %d, peers %d", b.Cap(), b.SlotSize(), a.Peers())
+	}
+	for k := 0; k < 4; k++ {
+		if !a.Offer([]byte{byte(k)}) {
+			t.Fatalf("offer %d", k)
+		}
+	}
+	if a.Offer([]byte{9}) || b.Len() != 4 || b.RemainingCapacity() != 0 {
+		t.Fatalf("expected full, len %d", b.Len())
+	}
+	if a.OfferTimeout([]byte{9}, 10*time.Millisecond) {
+		t.Fatal("offered into a full queue")
+	}
+	for k := 0; k < 4; k++ {
+		if m := b.Poll(); !bytes.Equal(m, []byte{byte(k)}) {
+			t.Fatalf("poll %d: %v", k, m)
+		}
+	}
+	if b.Poll() != nil || b.PollTimeout(10*time.Millisecond) != nil || !a.IsEmpty() {
+		t.Fatal("expected empty")
+	}
+	if err := a.Put([]byte("123456789")); err != IllegalArgumentError {
+		t.Fatalf("put too large: %v", err)
+	}
+	if _, err := Open(a.file.Name(), Options{Slots: 8}); err != IllegalArgumentError {
+		t.Fatalf("open with another geometry: %v", err)
+	}
+}
+
+func TestQueue_Concurrent(t *testing.T) {
+	a, b, _ := openPair(t, Options{Slots: 16, SlotSize: 16})
+	const producers, perProducer = 4, 2000
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for k := 0; k < perProducer; k++ {
+				if err := a.Put([]byte(fmt.Sprintf("%d-%d", p, k))); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(p)
+	}
+	// every producer's messages come out in order.
+	last := make([]int, producers)
+	for k := range last {
+		last[k] = -1
+	}
+	for n := 0; n < producers*perProducer; n++ {
+		m, err := b.Take()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var p, k int
+		fmt.Sscanf(string(m), "%d-%d", &p, &k)
+		if k != last[p]+1 {
+			t.Fatalf("producer %d: got %d after %d", p, k, last[p])
+		}
+		last[p] = k
+	}
+	wg.Wait()
+}
+
+func TestQueue_DeadPeer(t *testing.T) {
+	a, _, path := openPair(t, Options{Slots: 4, SlotSize: 16})
+	id := idOf(t, a, runPeer(t, path))
+	if m, err := a.Take(); err != nil || string(m) != "from child" {
+		t.Fatalf("take %q %v", m, err)
+	}
+	// the heartbeat of the child stands still, the next waiting Take notices it.
+	if _, err := a.Take(); err != PeerDeadError {
+		t.Fatalf("expected PeerDeadError, got %v", err)
+	}
+	if a.Peers() != 2 || len(a.DeadPeers()) != 0 {
+		t.Fatal("the dead peer must be reported once")
+	}
+
+	// a slot claimed by the dead child and never published is skipped.
+	pos := atomic.AddUint64(a.u64(offEnqueuePos), 1) - 1
+	atomic.StoreUint32(a.claimer(a.slotOffset(pos)), id)
+	a.Offer([]byte("after"))
+	if m := a.Poll(); string(m) != "after" {
+		t.Fatalf("expected the claimed slot to be skipped, got %q", m)
+	}
+}
+
+func TestQueue_DeadClaimers(t *testing.T) {
+	a, _, path := openPair(t, Options{Slots: 2, SlotSize: 16})
+	id := idOf(t, a, runPeer(t, path))
+	if m := a.Poll(); string(m) != "from child" {
+		t.Fatalf("poll %q", m)
+	}
+	if _, err := a.Take(); err != PeerDeadError {
+		t.Fatalf("expected PeerDeadError, got %v", err)
+	}
+	enq, deq := a.u64(offEnqueuePos), a.u64(offDequeuePos)
+
+	// a producer died after stamping the slot, before moving the enqueue cursor.
+	atomic.StoreUint32(a.claimer(a.slotOffset(atomic.LoadUint64(enq))), id)
+	if !a.Offer([]byte("1")) {
+		t.Fatal("the stamp of a dead producer should be taken over")
+	}
+	// a consumer died after stamping the slot, before moving the dequeue cursor.
+	atomic.StoreUint32(a.claimer(a.slotOffset(atomic.LoadUint64(deq))), id)
+	if m := a.Poll(); string(m) != "1" {
+		t.Fatalf("the stamp of a dead consumer should be taken over, got %q", m)
+	}
+
+	// a consumer died after moving the dequeue cursor, before freeing the slot: its message is lost.
+	a.Offer([]byte("lost"))
+	pos := atomic.LoadUint64(deq)
+	atomic.StoreUint32(a.claimer(a.slotOffset(pos)), id)
+	atomic.StoreUint64(deq, pos+1)
+	if !a.Offer([]byte("2")) || !a.Offer([]byte("3")) {
+		t.Fatal("the slot of a dead consumer should be freed by the producers")
+	}
+	if m := a.Poll(); string(m) != "2" {
+		t.Fatalf("want 2, got %q", m)
+	}
+	if m := a.Poll(); string(m) != "3" {
+		t.Fatalf("want 3, got %q", m)
+	}
+	if !a.IsEmpty() || a.Poll() != nil {
+		t.Fatal("expected empty")
+	}
+}
+
+// a peer in another PID namespace: its pid means nothing here, its heartbeat tells that it's alive.
+func TestQueue_PeerWithInvisiblePid(t *testing.T) {
+	a, _, _ := openPair(t, Options{Slots: 4, SlotSize: 16})
+	// above the largest pid_max, no process of this namespace has it.
+	const pid = 1 << 30
+	id := atomic.AddUint32(a.u32(offLastPeerID), 1)
+	k := a.peer + 2
+	atomic.StoreUint64(a.peerEntry(k), uint64(id)<<32|pid)
+	stop := make(chan struct{})
+	beating := make(chan struct{})
+	go func() {
+		defer close(beating)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(heartbeatInterval):
+				atomic.AddUint64(a.heartbeatOf(k), 1)
+			}
+		}
+	}()
+
+	// the peer claims a slot, and is publishing it.
+	pos := atomic.AddUint64(a.u64(offEnqueuePos), 1) - 1
+	atomic.StoreUint32(a.claimer(a.slotOffset(pos)), id)
+	a.Offer([]byte("after"))
+	time.Sleep(peerTimeout + 2*heartbeatInterval)
+	if m := a.Poll(); m != nil || len(a.DeadPeers()) != 0 || a.Peers() != 3 {
+		t.Fatalf("a peer whose heartbeat moves is alive, polled %q", m)
+	}
+
+	// it stops beating, without leaving the table.
+	close(stop)
+	<-beating
+	deadline := time.Now().Add(peerTimeout + time.Second)
+	var dead []int
+	for len(dead) == 0 && time.Now().Before(deadline) {
+		dead = a.DeadPeers()
+		time.Sleep(heartbeatInterval)
+	}
+	if len(dead) != 1 || dead[0] != pid {
+		t.Fatalf("want the silent peer dead, got %v", dead)
+	}
+	if m := a.Poll(); string(m) != "after" {
+		t.Fatalf("expected the claimed slot to be skipped, got %q", m)
+	}
+}
@@ -0,0 +1,202 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"math"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * The snapshot format, big endian:
+ *
+ *   magic    [4]byte "DSQS"
+ *   version  uint16
+ *   capacity uint64
+ *   count    uint64
+ *   count times:
+ *     length  uint32
+ *     payload [length]byte, the element encoded by the Codec
+ *   checksum uint32, crc32 (IEEE) of all the bytes above
+ */
+const snapshotVersion = 1
+
+// the capacity recorded for the collections without one, it's the capacity of an unbounded LinkedBlockingQueue.
+const maxCapacity = math.MaxInt32
+
+var snapshotMagic = [4]byte{'D', 'S', 'Q', 'S'}
+
+/**
+ * @Description: write a snapshot of the elements of a collection, in the order of Range.
+ *               the snapshot is consistent if c.ToSlice is atomic, as for LinkedBlockingQueue.
+ * @param w
+ * @param c the capacity recorded is its length plus its remaining capacity if it has a RemainingCapacity
+ *        method, or math.MaxInt32 otherwise, and never more than math.MaxInt32
+ * @param codec encodes the elements
+ * @return error the error of codec or w
+ */
+func WriteCollectionSnapshot(w io.Writer, c Collection, codec Codec) error {
+	if c == nil || codec == nil {
+		return NilPointerError
+	}
+	elements := c.ToSlice()
+	capacity := maxCapacity
+	if b, ok := c.(interface{ RemainingCapacity() int }); ok {
+		// clamped, an unbounded ShardedBlockingQueue for example has room for math.MaxInt32 per shard.
+		if remaining := b.RemainingCapacity(); remaining < maxCapacity-len(elements) {
+			capacity = len(elements) + remaining
+		}
+	}
+	return writeSnapshot(w, capacity, elements, codec)
+}
+
+func writeSnapshot(w io.Writer, capacity int, elements []interface{}, codec Codec) error {
+	bw := bufio.NewWriter(w)
+	sum := crc32.NewIEEE()
+	out := io.MultiWriter(bw, sum)
+	var header [22]byte
+	copy(header[0:4], snapshotMagic[:])
+	binary.BigEndian.PutUint16(header[4:6], snapshotVersion)
+	binary.BigEndian.PutUint64(header[6:14], uint64(capacity))
+	binary.BigEndian.PutUint64(header[14:22], uint64(len(elements)))
+	if _, err := out.Write(header[:]); err != nil {
+		return err
+	}
+	var length [4]byte
+	for _, e := range elements {
+		payload, err := codec.Encode(e)
+		if err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint32(length[:], uint32(len(payload)))
+		if _, err := out.Write(length[:]); err != nil {
+			return err
+		}
+		if _, err := out.Write(payload); err != nil {
+			return err
+		}
+	}
+	var checksum [4]byte
+	binary.BigEndian.PutUint32(checksum[:], sum.Sum32())
+	if _, err := bw.Write(checksum[:]); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+/**
+ * @Description: read a snapshot written by WriteCollectionSnapshot or LinkedBlockingQueue.WriteSnapshot.
+ * @return int the capacity of the collection
+ * @return []interface{} the elements, in order
+ * @return error InvalidSnapshotError if the snapshot is not valid, io.ErrUnexpectedEOF if it's truncated,
+ *         or the error of codec or r
+ */
+func ReadCollectionSnapshot(r io.Reader, codec Codec) (int, []interface{}, error) {
+	if codec == nil {
+		return 0, nil, NilPointerError
+	}
+	sum := crc32.NewIEEE()
+	// not buffered, r is not read past the end of the snapshot.
+	in := io.TeeReader(r, sum)
+	var header [22]byte
+	if err := readSnapshotFull(in, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if !bytes.Equal(header[0:4], snapshotMagic[:]) || binary.BigEndian.Uint16(header[4:6]) != snapshotVersion {
+		return 0, nil, InvalidSnapshotError
+	}
+	capacity := binary.BigEndian.Uint64(header[6:14])
+	count := binary.BigEndian.Uint64(header[14:22])
+	if capacity > maxCapacity || count > capacity {
+		return 0, nil, InvalidSnapshotError
+	}
+	// the payloads are decoded once the checksum is verified, nothing is trusted before.
+	var payloads [][]byte
+	var length [4]byte
+	for k := uint64(0); k < count; k++ {
+		if err := readSnapshotFull(in, length[:]); err != nil {
+			return 0, nil, err
+		}
+		// copied through a buffer growing with the input, a corrupted length can't allocate gigabytes.
+		var payload bytes.Buffer
+		n := int64(binary.BigEndian.Uint32(length[:]))
+		if m, err := io.CopyN(&payload, in, n); m < n {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, nil, err
+		}
+		payloads = append(payloads, payload.Bytes())
+	}
+	if err := checkSnapshotSum(in, sum); err != nil {
+		return 0, nil, err
+	}
+	elements := make([]interface{}, 0, len(payloads))
+	for _, p := range payloads {
+		e, err := codec.Decode(p)
+		if err != nil {
+			return 0, nil, err
+		}
+		elements = append(elements, e)
+	}
+	return int(capacity), elements, nil
+}
+
+func readSnapshotFull(r io.Reader, buf []byte) error {
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+// reads the checksum, it's not part of what it sums.
+func checkSnapshotSum(in io.Reader, sum hash.Hash32) error {
+	want := sum.Sum32()
+	var checksum [4]byte
+	if err := readSnapshotFull(in, checksum[:]); err != nil {
+		return err
+	}
+	if binary.BigEndian.Uint32(checksum[:]) != want {
+		return InvalidSnapshotError
+	}
+	return nil
+}
+
+/**
+ * @Description: write a consistent snapshot of the queue, its capacity included.
+ *               the queue is locked while its elements are copied, not while they are encoded and written.
+ * @receiver q
+ * @param w
+ * @param codec encodes the elements
+ * @return error the error of codec or w
+ */
+func (q *LinkedBlockingQueue) WriteSnapshot(w io.Writer, codec Codec) error {
+	if codec == nil {
+		return NilPointerError
+	}
+	return writeSnapshot(w, q.capacity, q.ToSlice(), codec)
+}
+
+/**
+ * @Description: create a LinkedBlockingQueue from a snapshot, with the capacity and the elements
+ *               recorded, as FromSlice does.
+ * @param r
+ * @param codec decodes the elements
+ * @return *LinkedBlockingQueue
+ * @return error see ReadCollectionSnapshot
+ */
+func RestoreSnapshot(r io.Reader, codec Codec) (*LinkedBlockingQueue, error) {
+	capacity, elements, err := ReadCollectionSnapshot(r, codec)
+	if err != nil {
+		return nil, err
+	}
+	return FromSlice(elements, capacity)
+}
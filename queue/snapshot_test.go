@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+func TestLinkedBlockingQueue_Snapshot(t *testing.T) {
+	q, _ := FromSlice([]interface{}{"a", 1, 2.5}, 5)
+	var buf bytes.Buffer
+	if err := q.WriteSnapshot(&buf, GobCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := buf.Bytes()
+
+	restored, err := RestoreSnapshot(bytes.NewReader(snapshot), GobCodec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.String() != q.String() || restored.RemainingCapacity() != 2 {
+		t.Fatalf("want %v with room for 2, got %v with room for %d", q, restored, restored.RemainingCapacity())
+	}
+
+	corrupted := append([]byte(nil), snapshot...)
+	corrupted[len(corrupted)/2] ^= 0xff
+	if _, err := RestoreSnapshot(bytes.NewReader(corrupted), GobCodec{}); err != InvalidSnapshotError {
+		t.Fatalf("want InvalidSnapshotError, got %v", err)
+	}
+	if _, err := RestoreSnapshot(bytes.NewReader(snapshot[:len(snapshot)-3]), GobCodec{}); err != io.ErrUnexpectedEOF {
+		t.Fatalf("want io.ErrUnexpectedEOF, got %v", err)
+	}
+	if _, err := RestoreSnapshot(bytes.NewReader([]byte("not a snapshot at all....")), GobCodec{}); err != InvalidSnapshotError {
+		t.Fatalf("want InvalidSnapshotError, got %v", err)
+	}
+}
+
+func TestCollectionSnapshot(t *testing.T) {
+	d := NewArrayDeque(0)
+	d.Add("x")
+	d.Add("y")
+	var buf bytes.Buffer
+	if err := WriteCollectionSnapshot(&buf, d, StringCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	capacity, elements, err := ReadCollectionSnapshot(&buf, StringCodec{})
+	if err != nil || capacity != maxCapacity || len(elements) != 2 || elements[0] != "x" || elements[1] != "y" {
+		t.Fatalf("got %d %v %v", capacity, elements, err)
+	}
+}
+
+func TestCollectionSnapshot_UnboundedShardedQueue(t *testing.T) {
+	q := NewShardedBlockingQueue(4, 0, nil)
+	for _, s := range []string{"a", "b", "c"} {
+		q.Put(s)
+	}
+	var buf bytes.Buffer
+	if err := WriteCollectionSnapshot(&buf, q, StringCodec{}); err != nil {
+		t.Fatal(err)
+	}
+	capacity, elements, err := ReadCollectionSnapshot(&buf, StringCodec{})
+	if err != nil || capacity != maxCapacity || len(elements) != 3 {
+		t.Fatalf("the capacity should be clamped to %d, got %d %v %v", maxCapacity, capacity, elements, err)
+	}
+}
@@ -0,0 +1,446 @@
+package queue
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+// SpillQueueOptions are the options of a SpillQueue, the zero value means the defaults.
+type SpillQueueOptions struct {
+	// the spill files go to a temporary directory created in Dir, "" means os.TempDir()
+	Dir string
+	// the spill files may take at most MaxDiskBytes bytes, the queue is full beyond it. 0 means no limit
+	MaxDiskBytes int64
+	// the size of a spill segment, it must be less than MaxDiskBytes. a segment is deleted once all its
+	// elements are taken, and all of them once the spill is drained, so the room of a full spill comes
+	// back one segment at a time. 0 means DefaultDiskQueueSegmentSize, or a quarter of MaxDiskBytes if
+	// that's smaller
+	SegmentSize int64
+}
+
+/**
+ * A SpillQueue is a FIFO BlockingQueue keeping up to threshold elements in
+ * memory, like a LinkedBlockingQueue, and spilling the elements beyond it to a
+ * DiskQueue in a temporary directory, instead of blocking the producers.
+ *
+ * <p>The spilled elements are encoded by a Codec, and read back transparently:
+ * once something has been spilled, the new elements go to disk too, until the
+ * spill is drained, so that the order is kept. the queue is full, and Put waits,
+ * only when the spill files reach MaxDiskBytes.
+ *
+ * <p>The spill files are not meant to survive the process: they are written
+ * without fsync, and removed on Close, with the elements still in the queue.
+ * the elements on disk can't be removed from the middle, Remove, RemoveAll,
+ * RemoveIf and RetainAll panic UnsupportedOperationError.
+ */
+type SpillQueue struct {
+	// The number of items in the queue, in memory and on disk
+	count int64
+
+	threshold    int
+	codec        Codec
+	maxDiskBytes int64
+
+	// Main lock guarding all access
+	lock *sync.Mutex
+	// Condition for waiting takes
+	notEmpty *sync.Cond
+	// Condition for waiting puts
+	notFull *sync.Cond
+
+	// the oldest elements
+	memory *list.List
+	// the newest ones, once memory has been full
+	spill  *DiskQueue
+	closed bool
+}
+
+/**
+ * @Description: create a SpillQueue, and its temporary directory.
+ * @param threshold the number of elements kept in memory, if it's less than 1, IllegalArgumentError will be panic
+ * @param codec encodes the spilled elements, if it's nil, NilPointerError will be panic
+ * @param opts nil means the defaults, if they are not valid, IllegalArgumentError will be panic
+ * @return *SpillQueue
+ * @return error the error met while creating the spill files
+ */
+func NewSpillQueue(threshold int, codec Codec, opts *SpillQueueOptions) (*SpillQueue, error) {
+	if threshold < 1 {
+		panic(IllegalArgumentError)
+	}
+	if codec == nil {
+		panic(NilPointerError)
+	}
+	var o SpillQueueOptions
+	if opts != nil {
+		o = *opts
+	}
+	// a segment as large as the cap would fill the spill before it could be deleted.
+	if o.MaxDiskBytes < 0 || o.SegmentSize < 0 || o.MaxDiskBytes > 0 && o.SegmentSize >= o.MaxDiskBytes {
+		panic(IllegalArgumentError)
+	}
+	if o.SegmentSize == 0 {
+		o.SegmentSize = DefaultDiskQueueSegmentSize
+		if o.MaxDiskBytes > 0 && o.MaxDiskBytes/4 < o.SegmentSize {
+			o.SegmentSize = o.MaxDiskBytes / 4
+		}
+	}
+	dir, err := os.MkdirTemp(o.Dir, "spill-")
+	if err != nil {
+		return nil, err
+	}
+	spill, err := OpenDiskQueue(dir, 0, codec, &DiskQueueOptions{SegmentSize: o.SegmentSize, Sync: SyncNever})
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	lock := new(sync.Mutex)
+	return &SpillQueue{
+		threshold:    threshold,
+		codec:        codec,
+		maxDiskBytes: o.MaxDiskBytes,
+		lock:         lock,
+		notEmpty:     sync.NewCond(lock),
+		notFull:      sync.NewCond(lock),
+		memory:       list.New(),
+		spill:        spill,
+	}, nil
+}
+
+// helper functions below, they should be called with lock held.
+
+// appends i in memory, or to the spill, returns FullError if the spill is full.
+func (q *SpillQueue) enqueue(i interface{}) error {
+	if q.closed {
+		return IllegalStateError
+	}
+	if q.spill.IsEmpty() && q.memory.Len() < q.threshold {
+		q.memory.PushBack(i)
+	} else {
+		payload, err := q.codec.Encode(i)
+		if err != nil {
+			return err
+		}
+		if q.maxDiskBytes > 0 && q.spill.Size()+int64(diskQueueRecordHeaderSize+len(payload)) > q.maxDiskBytes {
+			return FullError
+		}
+		if _, err := q.spill.insertPayload(payload, false, time.Time{}); err != nil {
+			return err
+		}
+	}
+	atomic.AddInt64(&q.count, 1)
+	q.notEmpty.Signal()
+	return nil
+}
+
+// takes the head from memory, or from the spill once memory is empty, or returns nil.
+func (q *SpillQueue) dequeue() interface{} {
+	if q.closed {
+		return nil
+	}
+	var x interface{}
+	if e := q.memory.Front(); e != nil {
+		x = q.memory.Remove(e)
+	} else if x = q.spill.Poll(); x == nil {
+		return nil
+	} else if q.spill.IsEmpty() {
+		// the segment appended to is never deleted otherwise, see Err for a failure.
+		q.spill.truncateIfEmpty()
+	}
+	atomic.AddInt64(&q.count, -1)
+	q.notFull.Signal()
+	return x
+}
+
+// inserts i, waiting until deadline for room, or forever if deadline is zero.
+func (q *SpillQueue) insert(i interface{}, wait bool, deadline time.Time) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for {
+		err := q.enqueue(i)
+		if err != FullError || !wait {
+			return err
+		}
+		if deadline.IsZero() {
+			q.notFull.Wait()
+		} else if !waitUntil(q.notFull, deadline) {
+			return TimeoutError
+		}
+	}
+}
+
+// returns false if the queue is full, closed, or i can't be spilled, see Err.
+func (q *SpillQueue) Offer(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	return q.insert(i, false, time.Time{}) == nil
+}
+
+func (q *SpillQueue) Add(i interface{}) bool {
+	if q.Offer(i) {
+		return true
+	}
+	panic(IllegalStateError)
+}
+
+/**
+ * @Description: insert i, waiting if the spill files have reached MaxDiskBytes.
+ * @return error NilPointerError, IllegalStateError if the queue is closed,
+ *         or the error met while encoding or writing i
+ */
+func (q *SpillQueue) Put(i interface{}) error {
+	if i == nil {
+		return NilPointerError
+	}
+	return q.insert(i, true, time.Time{})
+}
+
+func (q *SpillQueue) OfferTimout(i interface{}, timeout time.Duration) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	return q.insert(i, true, time.Now().Add(timeout)) == nil
+}
+
+func (q *SpillQueue) Poll() interface{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.dequeue()
+}
+
+// waits for an element, returns nil once the queue is closed.
+func (q *SpillQueue) Take() interface{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for !q.closed {
+		if x := q.dequeue(); x != nil {
+			return x
+		}
+		q.notEmpty.Wait()
+	}
+	return nil
+}
+
+func (q *SpillQueue) PollTimeout(timeout time.Duration) interface{} {
+	deadline := time.Now().Add(timeout)
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for !q.closed {
+		if x := q.dequeue(); x != nil {
+			return x
+		}
+		if !waitUntil(q.notEmpty, deadline) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (q *SpillQueue) RemoveHead() interface{} {
+	if x := q.Poll(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (q *SpillQueue) Peek() interface{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.closed {
+		return nil
+	}
+	if e := q.memory.Front(); e != nil {
+		return e.Value
+	}
+	return q.spill.Peek()
+}
+
+func (q *SpillQueue) Element() interface{} {
+	if x := q.Peek(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+// the queue is bounded by the size of the spill files rather than by a number of elements.
+func (q *SpillQueue) RemainingCapacity() int {
+	if q.maxDiskBytes == 0 {
+		return maxCapacity - q.Len()
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.spill.Size() < q.maxDiskBytes {
+		return maxCapacity - q.Len()
+	}
+	return 0
+}
+
+func (q *SpillQueue) Len() int {
+	return int(atomic.LoadInt64(&q.count))
+}
+
+func (q *SpillQueue) IsEmpty() bool {
+	return q.Len() == 0
+}
+
+// the number of elements in memory.
+func (q *SpillQueue) MemoryLen() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.memory.Len()
+}
+
+// the number of elements on disk.
+func (q *SpillQueue) SpilledLen() int {
+	return q.spill.Len()
+}
+
+// the size of the spill files, in bytes.
+func (q *SpillQueue) DiskBytes() int64 {
+	return q.spill.Size()
+}
+
+// the error which stopped the spill, if any.
+func (q *SpillQueue) Err() error {
+	return q.spill.Err()
+}
+
+func (q *SpillQueue) Contains(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	found := false
+	q.Range(func(value interface{}) bool {
+		found = value == i
+		return !found
+	})
+	return found
+}
+
+/**
+ * @Description: iterate through the queue from head to tail, the spilled elements are decoded from disk.
+ *               the queue is locked during the iteration, f must not call back into the queue.
+ * @receiver q
+ * @param f return false to stop the iteration
+ */
+func (q *SpillQueue) Range(f func(value interface{}) bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.closed {
+		return
+	}
+	for e := q.memory.Front(); e != nil; e = e.Next() {
+		if !f(e.Value) {
+			return
+		}
+	}
+	q.spill.Range(f)
+}
+
+func (q *SpillQueue) ToSlice() []interface{} {
+	ret := make([]interface{}, 0, q.Len())
+	q.Range(func(value interface{}) bool {
+		ret = append(ret, value)
+		return true
+	})
+	return ret
+}
+
+func (q *SpillQueue) String() string {
+	return fmt.Sprintf("%v", q.ToSlice())
+}
+
+// lower performance
+func (q *SpillQueue) ContainsAll(c Collection) bool {
+	containsAll := true
+	c.Range(func(value interface{}) bool {
+		if !q.Contains(value) {
+			containsAll = false
+			return false
+		}
+		return true
+	})
+	return containsAll
+}
+
+/**
+ * @Description: offers all non-nil elements of c until the queue is full.
+ *               nil element in c will be skipped. it'll return FullError/ NilPointerError, or the error
+ *               of the spill, to indicate error.
+ * @receiver q
+ * @param c if c is nil, NilPointerError is returned
+ * @return bool indicates whether the queue has been changed or not when the func return
+ */
+func (q *SpillQueue) AddAll(c Collection) (modified bool, err error) {
+	if c == nil {
+		return false, NilPointerError
+	}
+	for _, e := range c.ToSlice() {
+		if e == nil {
+			err = NilPointerError
+			continue
+		}
+		if ierr := q.insert(e, false, time.Time{}); ierr != nil {
+			return modified, ierr
+		}
+		modified = true
+	}
+	return
+}
+
+func (q *SpillQueue) Remove(i interface{}) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (q *SpillQueue) RemoveAll(c Collection) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (q *SpillQueue) RemoveIf(filter func(value interface{}) bool) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (q *SpillQueue) RetainAll(c Collection) bool {
+	panic(UnsupportedOperationError)
+}
+
+/**
+ * Removes all of the elements from this queue, in memory and on disk.
+ */
+func (q *SpillQueue) Clear() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.closed {
+		return
+	}
+	q.memory.Init()
+	q.spill.Clear()
+	q.spill.truncateIfEmpty()
+	atomic.StoreInt64(&q.count, 0)
+	q.notFull.Broadcast()
+}
+
+/**
+ * @Description: drop the elements and remove the spill files, the waiting goroutines get IllegalStateError or nil.
+ * @return error the error met while removing the files, if any
+ */
+func (q *SpillQueue) Close() error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	q.memory.Init()
+	atomic.StoreInt64(&q.count, 0)
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+	q.spill.Close()
+	return os.RemoveAll(q.spill.Dir())
+}
@@ -0,0 +1,128 @@
+package queue
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSpillQueue_FIFO(t *testing.T) {
+	parent := t.TempDir()
+	q, err := NewSpillQueue(3, GobCodec{}, &SpillQueueOptions{Dir: parent, SegmentSize: 64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := 0; k < 10; k++ {
+		q.Put(k)
+	}
+	if q.MemoryLen() != 3 || q.SpilledLen() != 7 || q.Len() != 10 || q.DiskBytes() == 0 {
+		t.Fatalf("memory %d, spilled %d, len %d", q.MemoryLen(), q.SpilledLen(), q.Len())
+	}
+	if s := q.String(); s != "[0 1 2 3 4 5 6 7 8 9]" {
+		t.Fatalf("String: %s", s)
+	}
+	// memory has room again, but the new elements go after the spilled ones.
+	q.Poll()
+	q.Put(10)
+	for k := 1; k <= 10; k++ {
+		if x := q.Peek(); x != k {
+			t.Fatalf("peek %d: %v", k, x)
+		}
+		if x := q.Take(); x != k {
+			t.Fatalf("take %d: %v", k, x)
+		}
+	}
+	if !q.IsEmpty() || q.Poll() != nil {
+		t.Fatal("expected empty")
+	}
+	// the spill is drained, memory is used again.
+	q.Put(11)
+	if q.MemoryLen() != 1 || q.SpilledLen() != 0 {
+		t.Fatalf("memory %d, spilled %d", q.MemoryLen(), q.SpilledLen())
+	}
+	if q.Err() != nil {
+		t.Fatal(q.Err())
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(parent); len(entries) != 0 {
+		t.Fatalf("spill files left: %v", entries)
+	}
+	if q.Offer(12) || q.Poll() != nil {
+		t.Fatal("closed queue must be unusable")
+	}
+}
+
+func TestSpillQueue_MaxDiskBytes(t *testing.T) {
+	q, err := NewSpillQueue(2, StringCodec{}, &SpillQueueOptions{Dir: t.TempDir(), MaxDiskBytes: 4 * 9, SegmentSize: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	// a record of a one byte string takes 9 bytes.
+	for k := 0; k < 6; k++ {
+		if !q.Offer(string(rune('a' + k))) {
+			t.Fatalf("offer %d", k)
+		}
+	}
+	if q.Offer("g") || q.RemainingCapacity() != 0 {
+		t.Fatal("the spill must be full")
+	}
+	if q.OfferTimout("g", 10*time.Millisecond) {
+		t.Fatal("offered into a full spill")
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := q.Put("g"); err != nil {
+			t.Error(err)
+		}
+	}()
+	// taking from memory doesn't free disk space, taking a whole spill segment does.
+	for _, want := range []string{"a", "b", "c"} {
+		if x := q.Take(); x != want {
+			t.Fatalf("expected %s, got %v", want, x)
+		}
+	}
+	wg.Wait()
+	if s := q.String(); s != "[d e f g]" {
+		t.Fatalf("String: %s", s)
+	}
+}
+
+func TestSpillQueue_RefillAtCap(t *testing.T) {
+	q, err := NewSpillQueue(1, StringCodec{}, &SpillQueueOptions{Dir: t.TempDir(), MaxDiskBytes: 3 * 9, SegmentSize: 2 * 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	for round := 0; round < 3; round++ {
+		// 1 element in memory, and 3 records of 9 bytes on disk.
+		for k := 0; k < 4; k++ {
+			if !q.Offer(string(rune('a' + k))) {
+				t.Fatalf("round %d: offer %d, %d bytes on disk", round, k, q.DiskBytes())
+			}
+		}
+		if q.Offer("e") {
+			t.Fatalf("round %d: the spill must be full", round)
+		}
+		for k := 0; k < 4; k++ {
+			if x := q.Poll(); x != string(rune('a'+k)) {
+				t.Fatalf("round %d: poll %d: %v", round, k, x)
+			}
+		}
+		if q.DiskBytes() != 0 || q.Err() != nil {
+			t.Fatalf("round %d: a drained spill should take no space, %d bytes: %v", round, q.DiskBytes(), q.Err())
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("a segment as large as MaxDiskBytes should be rejected")
+		}
+	}()
+	NewSpillQueue(1, StringCodec{}, &SpillQueueOptions{Dir: t.TempDir(), MaxDiskBytes: 100, SegmentSize: 100})
+}
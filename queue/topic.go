@@ -0,0 +1,456 @@
+package queue
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+// SlowSubscriberPolicy tells what a Topic does when a subscriber is too far behind to publish.
+type SlowSubscriberPolicy int
+
+const (
+	// the publishers wait for the slowest subscriber.
+	SlowSubscriberBlock SlowSubscriberPolicy = iota
+	// the slow subscribers lose their oldest element, see Subscription.Dropped.
+	SlowSubscriberDrop
+	// the slow subscribers are unsubscribed.
+	SlowSubscriberDisconnect
+)
+
+/**
+ * A Topic broadcasts the published elements to all its subscribers.
+ *
+ * <p>The elements are appended once to a ring of capacity slots, and every
+ * Subscription reads them at its own pace, from its own position. a subscriber
+ * sees the elements published after it subscribed, in order. a subscriber can't
+ * be more than capacity elements behind the publishers, when publishing would
+ * take it further, the SlowSubscriberPolicy applies.
+ *
+ * <p>Elements published while there is no subscriber are lost.
+ */
+type Topic struct {
+	lock *sync.Mutex
+	// Condition for subscribers waiting for elements
+	notEmpty *sync.Cond
+	// Condition for publishers waiting for the slowest subscriber
+	notFull *sync.Cond
+
+	buf []interface{}
+	// the sequence of the next element to publish, the element of sequence s is in buf[s % len(buf)]
+	tail int64
+	// the sequence of the oldest element some subscriber has not taken, the slots before are cleared
+	head   int64
+	policy SlowSubscriberPolicy
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+/**
+ * @Description: create a Topic.
+ * @param capacity how far behind a subscriber may be, if it's less than 1, IllegalArgumentError will be panic
+ * @param policy what to do with the subscribers that are too far behind
+ * @return *Topic
+ */
+func NewTopic(capacity int, policy SlowSubscriberPolicy) *Topic {
+	if capacity < 1 || policy < SlowSubscriberBlock || policy > SlowSubscriberDisconnect {
+		panic(IllegalArgumentError)
+	}
+	lock := new(sync.Mutex)
+	return &Topic{
+		lock:     lock,
+		notEmpty: sync.NewCond(lock),
+		notFull:  sync.NewCond(lock),
+		buf:      make([]interface{}, capacity),
+		policy:   policy,
+		subs:     make(map[*Subscription]struct{}),
+	}
+}
+
+// returns a new Subscription, it starts with the next published element.
+func (t *Topic) Subscribe() *Subscription {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	s := &Subscription{topic: t, cursor: t.tail, closed: t.closed}
+	if !t.closed {
+		t.subs[s] = struct{}{}
+	}
+	return s
+}
+
+// the number of subscribers.
+func (t *Topic) Subscribers() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return len(t.subs)
+}
+
+// removes s from the subscribers, with lock held.
+func (t *Topic) unsubscribe(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(t.subs, s)
+	t.release()
+	// s may be the slowest subscriber, or be waiting.
+	t.notFull.Broadcast()
+	t.notEmpty.Broadcast()
+}
+
+// clears the slots all the subscribers are past, so that their elements can be collected, with lock held.
+func (t *Topic) release() {
+	oldest := t.tail
+	for s := range t.subs {
+		if s.cursor < oldest {
+			oldest = s.cursor
+		}
+	}
+	for ; t.head < oldest; t.head++ {
+		t.buf[t.head%int64(len(t.buf))] = nil
+	}
+}
+
+/**
+ * @Description: append i for all the subscribers, with lock held.
+ * @param wait whether to wait for the slowest subscriber, with SlowSubscriberBlock
+ * @param deadline how long to wait, forever if it's zero
+ * @return bool false if the publisher would have to wait, or waited until deadline
+ */
+func (t *Topic) publish(i interface{}, wait bool, deadline time.Time) (bool, error) {
+	for {
+		if t.closed {
+			return false, IllegalStateError
+		}
+		var slow []*Subscription
+		for s := range t.subs {
+			if t.tail-s.cursor >= int64(len(t.buf)) {
+				slow = append(slow, s)
+			}
+		}
+		if len(slow) == 0 {
+			break
+		}
+		if t.policy == SlowSubscriberBlock {
+			if !wait {
+				return false, nil
+			}
+			if deadline.IsZero() {
+				t.notFull.Wait()
+			} else if !waitUntil(t.notFull, deadline) {
+				return false, nil
+			}
+			continue
+		}
+		for _, s := range slow {
+			if t.policy == SlowSubscriberDrop {
+				s.cursor++
+				s.dropped++
+			} else {
+				t.unsubscribe(s)
+			}
+		}
+		break
+	}
+	// the slots of the dropped elements, before i may be stored in one of them.
+	t.release()
+	if len(t.subs) == 0 {
+		// nobody will take i.
+		t.tail++
+		t.head = t.tail
+		return true, nil
+	}
+	t.buf[t.tail%int64(len(t.buf))] = i
+	t.tail++
+	t.notEmpty.Broadcast()
+	return true, nil
+}
+
+/**
+ * @Description: publish i to all the subscribers, waiting for the slowest one if the policy says so.
+ * @return error NilPointerError, or IllegalStateError if the topic is closed
+ */
+func (t *Topic) Publish(i interface{}) error {
+	if i == nil {
+		return NilPointerError
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	_, err := t.publish(i, true, time.Time{})
+	return err
+}
+
+// like Publish, but returns false instead of waiting, or if the topic is closed.
+func (t *Topic) TryPublish(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ok, _ := t.publish(i, false, time.Time{})
+	return ok
+}
+
+// like Publish, but returns false if it had to wait longer than timeout, or if the topic is closed.
+func (t *Topic) PublishTimeout(i interface{}, timeout time.Duration) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	deadline := time.Now().Add(timeout)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	ok, _ := t.publish(i, true, deadline)
+	return ok
+}
+
+/**
+ * @Description: stop publishing. the subscribers still get the elements published before,
+ *               then their Take returns nil.
+ */
+func (t *Topic) Close() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.closed = true
+	t.notFull.Broadcast()
+	t.notEmpty.Broadcast()
+}
+
+/**
+ * A Subscription is the view of a subscriber on a Topic, it's a BlockingQueue
+ * whose elements are the published elements the subscriber has not taken yet.
+ *
+ * <p>The elements are inserted by publishing to the topic only, the insertions
+ * (Offer, Put ...) and the removals from the middle (Remove, RemoveIf ...) are
+ * not supported. Clear skips all the elements available.
+ *
+ * <p>Once unsubscribed, or disconnected by SlowSubscriberDisconnect, a
+ * Subscription is empty and Take returns nil.
+ */
+type Subscription struct {
+	topic *Topic
+	// the sequence of the next element to take, guarded by the lock of the topic
+	cursor  int64
+	dropped int64
+	closed  bool
+}
+
+// the number of elements available, with the lock of the topic held.
+func (s *Subscription) available() int64 {
+	if s.closed {
+		return 0
+	}
+	return s.topic.tail - s.cursor
+}
+
+// takes the next element, with the lock of the topic held.
+func (s *Subscription) dequeue() interface{} {
+	if s.available() == 0 {
+		return nil
+	}
+	t := s.topic
+	x := t.buf[s.cursor%int64(len(t.buf))]
+	s.cursor++
+	if s.cursor-1 == t.head {
+		// s may have been the slowest subscriber.
+		t.release()
+	}
+	t.notFull.Broadcast()
+	return x
+}
+
+// whether Take should wait, with the lock of the topic held.
+func (s *Subscription) mustWait() bool {
+	return s.available() == 0 && !s.closed && !s.topic.closed
+}
+
+// stops the subscription, the elements not taken yet are lost.
+func (s *Subscription) Unsubscribe() {
+	s.topic.lock.Lock()
+	defer s.topic.lock.Unlock()
+	s.topic.unsubscribe(s)
+}
+
+// whether the subscription has been unsubscribed or disconnected.
+func (s *Subscription) Closed() bool {
+	s.topic.lock.Lock()
+	defer s.topic.lock.Unlock()
+	return s.closed
+}
+
+// the number of elements lost to SlowSubscriberDrop.
+func (s *Subscription) Dropped() int {
+	s.topic.lock.Lock()
+	defer s.topic.lock.Unlock()
+	return int(s.dropped)
+}
+
+/**
+ * @Description: take the next element, waiting if necessary until one is published.
+ * @return interface{} nil once the subscription or the topic is closed, and no element is left.
+ */
+func (s *Subscription) Take() interface{} {
+	s.topic.lock.Lock()
+	defer s.topic.lock.Unlock()
+	for s.mustWait() {
+		s.topic.notEmpty.Wait()
+	}
+	return s.dequeue()
+}
+
+func (s *Subscription) Poll() interface{} {
+	s.topic.lock.Lock()
+	defer s.topic.lock.Unlock()
+	return s.dequeue()
+}
+
+func (s *Subscription) PollTimeout(timeout time.Duration) interface{} {
+	deadline := time.Now().Add(timeout)
+	s.topic.lock.Lock()
+	defer s.topic.lock.Unlock()
+	for s.mustWait() {
+		if !waitUntil(s.topic.notEmpty, deadline) {
+			break
+		}
+	}
+	return s.dequeue()
+}
+
+func (s *Subscription) RemoveHead() interface{} {
+	if x := s.Poll(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (s *Subscription) Peek() interface{} {
+	var x interface{}
+	s.Range(func(value interface{}) bool {
+		x = value
+		return false
+	})
+	return x
+}
+
+func (s *Subscription) Element() interface{} {
+	if x := s.Peek(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (s *Subscription) Len() int {
+	s.topic.lock.Lock()
+	defer s.topic.lock.Unlock()
+	return int(s.available())
+}
+
+func (s *Subscription) IsEmpty() bool {
+	return s.Len() == 0
+}
+
+// how many more elements may be published before the subscription is too far behind.
+func (s *Subscription) RemainingCapacity() int {
+	return len(s.topic.buf) - s.Len()
+}
+
+func (s *Subscription) Contains(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	found := false
+	s.Range(func(value interface{}) bool {
+		found = value == i
+		return !found
+	})
+	return found
+}
+
+/**
+ * @Description: iterate through the elements available, from the next one to take.
+ *               the topic is locked during the iteration, f must not call back into it.
+ * @receiver s
+ * @param f return false to stop the iteration
+ */
+func (s *Subscription) Range(f func(value interface{}) bool) {
+	s.topic.lock.Lock()
+	defer s.topic.lock.Unlock()
+	buf := s.topic.buf
+	for seq := s.cursor; seq < s.cursor+s.available(); seq++ {
+		if !f(buf[seq%int64(len(buf))]) {
+			return
+		}
+	}
+}
+
+func (s *Subscription) ToSlice() []interface{} {
+	ret := make([]interface{}, 0, s.Len())
+	s.Range(func(value interface{}) bool {
+		ret = append(ret, value)
+		return true
+	})
+	return ret
+}
+
+func (s *Subscription) String() string {
+	return fmt.Sprintf("%v", s.ToSlice())
+}
+
+func (s *Subscription) ContainsAll(c Collection) bool {
+	containsAll := true
+	c.Range(func(value interface{}) bool {
+		if !s.Contains(value) {
+			containsAll = false
+			return false
+		}
+		return true
+	})
+	return containsAll
+}
+
+// skips all the elements available.
+func (s *Subscription) Clear() {
+	s.topic.lock.Lock()
+	defer s.topic.lock.Unlock()
+	s.cursor += s.available()
+	s.topic.release()
+	s.topic.notFull.Broadcast()
+}
+
+func (s *Subscription) Offer(i interface{}) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (s *Subscription) Add(i interface{}) bool {
+	panic(UnsupportedOperationError)
+}
+
+// returns UnsupportedOperationError, publish to the topic instead.
+func (s *Subscription) Put(i interface{}) error {
+	return UnsupportedOperationError
+}
+
+func (s *Subscription) OfferTimout(i interface{}, timeout time.Duration) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (s *Subscription) AddAll(c Collection) (bool, error) {
+	return false, UnsupportedOperationError
+}
+
+func (s *Subscription) Remove(i interface{}) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (s *Subscription) RemoveAll(c Collection) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (s *Subscription) RemoveIf(filter func(value interface{}) bool) bool {
+	panic(UnsupportedOperationError)
+}
+
+func (s *Subscription) RetainAll(c Collection) bool {
+	panic(UnsupportedOperationError)
+}
@@ -0,0 +1,113 @@
+package queue
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+var _ BlockingQueue = (*Subscription)(nil)
+
+func TestTopic_Broadcast(t *testing.T) {
+	topic := NewTopic(16, SlowSubscriberBlock)
+	const subscribers, n = 3, 100
+	var wg sync.WaitGroup
+	for k := 0; k < subscribers; k++ {
+		s := topic.Subscribe()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			next := 0
+			for x := s.Take(); x != nil; x = s.Take() {
+				if x != next {
+					t.Errorf("want %d, got %v", next, x)
+					return
+				}
+				next++
+			}
+			if next != n {
+				t.Errorf("got %d elements only", next)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if err := topic.Publish(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	topic.Close()
+	wg.Wait()
+	if topic.Publish(n) == nil {
+		t.Fatal("Publish to a closed topic should fail")
+	}
+}
+
+func TestTopic_SlowSubscriberPolicies(t *testing.T) {
+	blocking := NewTopic(2, SlowSubscriberBlock)
+	s := blocking.Subscribe()
+	blocking.Publish(1)
+	blocking.Publish(2)
+	if blocking.TryPublish(3) || blocking.PublishTimeout(3, 10*time.Millisecond) {
+		t.Fatal("the publisher should wait for the slow subscriber")
+	}
+	if s.Take() != 1 || !blocking.TryPublish(3) || s.String() != "[2 3]" {
+		t.Fatalf("subscription: %v", s)
+	}
+
+	dropping := NewTopic(2, SlowSubscriberDrop)
+	slow, fast := dropping.Subscribe(), dropping.Subscribe()
+	for i := 1; i <= 5; i++ {
+		dropping.Publish(i)
+		fast.Poll()
+	}
+	if slow.String() != "[4 5]" || slow.Dropped() != 3 || fast.Dropped() != 0 {
+		t.Fatalf("slow: %v, dropped %d", slow, slow.Dropped())
+	}
+
+	disconnecting := NewTopic(2, SlowSubscriberDisconnect)
+	slow, fast = disconnecting.Subscribe(), disconnecting.Subscribe()
+	for i := 1; i <= 3; i++ {
+		disconnecting.Publish(i)
+		fast.Poll()
+	}
+	if !slow.Closed() || slow.Take() != nil || disconnecting.Subscribers() != 1 {
+		t.Fatal("the slow subscriber should be disconnected")
+	}
+
+	fast.Unsubscribe()
+	if disconnecting.Subscribers() != 0 || !fast.IsEmpty() || fast.PollTimeout(time.Second) != nil {
+		t.Fatal("Unsubscribe")
+	}
+}
+
+func TestTopic_ReleasesTakenElements(t *testing.T) {
+	topic := NewTopic(4, SlowSubscriberDrop)
+	fast, slow := topic.Subscribe(), topic.Subscribe()
+	for k := 1; k <= 3; k++ {
+		topic.Publish(k)
+	}
+	fast.Clear()
+	if topic.buf[0] == nil {
+		t.Fatal("the slow subscriber still needs the first element")
+	}
+	slow.Take()
+	if topic.buf[0] != nil || topic.buf[1] == nil {
+		t.Fatalf("only the slot taken by both subscribers should be cleared, got %v", topic.buf)
+	}
+	// the slow subscriber loses 2 and 3, whose slots are cleared as well.
+	for k := 4; k <= 8; k++ {
+		topic.Publish(k)
+	}
+	fast.Clear()
+	slow.Unsubscribe()
+	for k, x := range topic.buf {
+		if x != nil {
+			t.Fatalf("slot %d holds %v after all the subscribers passed it", k, x)
+		}
+	}
+	fast.Unsubscribe()
+	topic.Publish(9)
+	if x := topic.buf[8%len(topic.buf)]; x != nil {
+		t.Fatalf("an element published without subscriber should not be held, got %v", x)
+	}
+}
@@ -0,0 +1,104 @@
+package queue
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * A TypeRegistry maps Go types to tags and back, so that the elements of a
+ * queue can be written along with their type, and read back as values of the
+ * same type, see LinkedBlockingQueue.MarshalJSON.
+ */
+type TypeRegistry struct {
+	lock   sync.RWMutex
+	byTag  map[string]reflect.Type
+	byType map[reflect.Type]string
+}
+
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{
+		byTag:  make(map[string]reflect.Type),
+		byType: make(map[reflect.Type]string),
+	}
+}
+
+/**
+ * @Description: map the type of sample to tag.
+ * @param tag
+ * @param sample a value of the type, a pointer type is registered as such
+ * @return error IllegalArgumentError if the tag or the type is already registered with another type or tag
+ */
+func (r *TypeRegistry) Register(tag string, sample interface{}) error {
+	if sample == nil {
+		return NilPointerError
+	}
+	t := reflect.TypeOf(sample)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if known, ok := r.byTag[tag]; ok && known != t {
+		return IllegalArgumentError
+	}
+	if known, ok := r.byType[t]; ok && known != tag {
+		return IllegalArgumentError
+	}
+	r.byTag[tag] = t
+	r.byType[t] = tag
+	return nil
+}
+
+// like Register, but panics on error, for package initialization.
+func (r *TypeRegistry) MustRegister(tag string, sample interface{}) {
+	if err := r.Register(tag, sample); err != nil {
+		panic(err)
+	}
+}
+
+// returns the tag of the type of i, the error wraps UnregisteredTypeError.
+func (r *TypeRegistry) TagOf(i interface{}) (string, error) {
+	t := reflect.TypeOf(i)
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	tag, ok := r.byType[t]
+	if !ok {
+		return "", fmt.Errorf("%w: type %v", UnregisteredTypeError, t)
+	}
+	return tag, nil
+}
+
+// returns the type registered with tag, the error wraps UnregisteredTypeError.
+func (r *TypeRegistry) TypeOf(tag string) (reflect.Type, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	t, ok := r.byTag[tag]
+	if !ok {
+		return nil, fmt.Errorf("%w: tag %q", UnregisteredTypeError, tag)
+	}
+	return t, nil
+}
+
+/**
+ * DefaultTypeRegistry is used by LinkedBlockingQueue.MarshalJSON and UnmarshalJSON.
+ * the basic types are registered under their Go name, and []byte as "bytes".
+ * register the other types from an init func, with the same tags on every side.
+ */
+var DefaultTypeRegistry = NewTypeRegistry()
+
+func init() {
+	for tag, sample := range map[string]interface{}{
+		"bool": false, "string": "", "bytes": []byte(nil),
+		"int": int(0), "int8": int8(0), "int16": int16(0), "int32": int32(0), "int64": int64(0),
+		"uint": uint(0), "uint8": uint8(0), "uint16": uint16(0), "uint32": uint32(0), "uint64": uint64(0),
+		"float32": float32(0), "float64": float64(0),
+	} {
+		DefaultTypeRegistry.MustRegister(tag, sample)
+	}
+}
+
+// registers a type in DefaultTypeRegistry.
+func RegisterType(tag string, sample interface{}) error {
+	return DefaultTypeRegistry.Register(tag, sample)
+}
@@ -0,0 +1,430 @@
+package queue
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+/**
+ * An optionally-bounded FIFO BlockingQueue holding every element at most once,
+ * backed by a linked list and a hash index of the elements.
+ *
+ * <p>Inserting an element already in the queue is a no-op: Put returns
+ * DuplicateElementError at once, even if the queue is full, Offer, OfferTimout
+ * and Add return false (use TryOffer or TryOfferTimeout to tell a duplicate from
+ * a full queue). once taken out, an element may be inserted again.
+ *
+ * <p>Contains and Remove take constant time. The elements are keys of a map,
+ * so they must be comparable, inserting a slice, a map or a func panics.
+ */
+type UniqueQueue struct {
+	// The number of items in the queue
+	count int64
+
+	// the capacity set
+	capacity int
+
+	// Main lock guarding all access
+	lock *sync.Mutex
+	// Condition for waiting takes
+	notEmpty *sync.Cond
+	// Condition for waiting puts
+	notFull *sync.Cond
+
+	list *list.List
+	// the list element of every element in the queue
+	index map[interface{}]*list.Element
+}
+
+/**
+ * @Description: create a UniqueQueue.
+ * @param capacity if capacity is 0, it'll be replace by math.MaxInt32,
+ *        if capacity is less than 0, IllegalArgumentError will be panic
+ * @return *UniqueQueue
+ */
+func NewUniqueQueue(capacity int) *UniqueQueue {
+	if capacity < 0 {
+		panic(IllegalArgumentError)
+	}
+	if capacity == 0 {
+		capacity = math.MaxInt32
+	}
+	lock := new(sync.Mutex)
+	return &UniqueQueue{
+		capacity: capacity,
+		lock:     lock,
+		notEmpty: sync.NewCond(lock),
+		notFull:  sync.NewCond(lock),
+		list:     list.New(),
+		index:    make(map[interface{}]*list.Element),
+	}
+}
+
+// helper functions below, they should be called with lock held.
+
+func (q *UniqueQueue) enqueue(i interface{}) {
+	q.index[i] = q.list.PushBack(i)
+	atomic.AddInt64(&q.count, 1)
+	q.notEmpty.Signal()
+}
+
+func (q *UniqueQueue) unlink(e *list.Element) interface{} {
+	x := q.list.Remove(e)
+	delete(q.index, x)
+	atomic.AddInt64(&q.count, -1)
+	q.notFull.Signal()
+	return x
+}
+
+func (q *UniqueQueue) dequeue() interface{} {
+	if e := q.list.Front(); e != nil {
+		return q.unlink(e)
+	}
+	return nil
+}
+
+/**
+ * @Description: insert i at the tail, waiting until deadline for space to become available,
+ *               or forever if deadline is zero.
+ * @return error DuplicateElementError, or TimeoutError
+ */
+func (q *UniqueQueue) insert(i interface{}, deadline time.Time) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for {
+		// checked on every round, the element may have been inserted by someone else meanwhile.
+		if _, ok := q.index[i]; ok {
+			return DuplicateElementError
+		}
+		if q.Len() < q.capacity {
+			q.enqueue(i)
+			return nil
+		}
+		if deadline.IsZero() {
+			q.notFull.Wait()
+		} else if !waitUntil(q.notFull, deadline) {
+			return TimeoutError
+		}
+	}
+}
+
+// returns false if the queue is full or already contains i.
+func (q *UniqueQueue) Offer(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if _, ok := q.index[i]; ok || q.Len() >= q.capacity {
+		return false
+	}
+	q.enqueue(i)
+	return true
+}
+
+/**
+ * @Description: like Offer, but tells a duplicate from a full queue.
+ * @param i if i is nil, NilPointerError will be panic
+ * @return bool whether i has been inserted
+ * @return error DuplicateElementError if the queue already contains i, even if it's full,
+ *         nil if i has been inserted or the queue is full
+ */
+func (q *UniqueQueue) TryOffer(i interface{}) (bool, error) {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if _, ok := q.index[i]; ok {
+		return false, DuplicateElementError
+	}
+	if q.Len() >= q.capacity {
+		return false, nil
+	}
+	q.enqueue(i)
+	return true, nil
+}
+
+/**
+ * Inserts the specified element at the tail of this queue, unless it's already there.
+ * returns false if the queue already contains i, panics IllegalStateError if it's full.
+ */
+func (q *UniqueQueue) Add(i interface{}) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if _, ok := q.index[i]; ok {
+		return false
+	}
+	if q.Len() >= q.capacity {
+		panic(IllegalStateError)
+	}
+	q.enqueue(i)
+	return true
+}
+
+/**
+ * Inserts the specified element at the tail of this queue, waiting if
+ * necessary for space to become available.
+ * returns DuplicateElementError without waiting if the queue already contains i.
+ */
+func (q *UniqueQueue) Put(i interface{}) error {
+	if i == nil {
+		return NilPointerError
+	}
+	return q.insert(i, time.Time{})
+}
+
+// returns false if the queue already contains i, or if it's still full after timeout.
+func (q *UniqueQueue) OfferTimout(i interface{}, timeout time.Duration) bool {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	return q.insert(i, time.Now().Add(timeout)) == nil
+}
+
+/**
+ * @Description: like OfferTimout, but tells a duplicate from a queue still full after timeout.
+ * @param i if i is nil, NilPointerError will be panic
+ * @return bool whether i has been inserted
+ * @return error DuplicateElementError without waiting if the queue already contains i,
+ *         nil if i has been inserted or the queue is still full after timeout
+ */
+func (q *UniqueQueue) TryOfferTimeout(i interface{}, timeout time.Duration) (bool, error) {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	switch err := q.insert(i, time.Now().Add(timeout)); err {
+	case nil:
+		return true, nil
+	case TimeoutError:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (q *UniqueQueue) Poll() interface{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.dequeue()
+}
+
+func (q *UniqueQueue) Take() interface{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for q.list.Len() == 0 {
+		q.notEmpty.Wait()
+	}
+	return q.dequeue()
+}
+
+func (q *UniqueQueue) PollTimeout(timeout time.Duration) interface{} {
+	deadline := time.Now().Add(timeout)
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for q.list.Len() == 0 {
+		if !waitUntil(q.notEmpty, deadline) {
+			return nil
+		}
+	}
+	return q.dequeue()
+}
+
+func (q *UniqueQueue) RemoveHead() interface{} {
+	if x := q.Poll(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (q *UniqueQueue) Peek() interface{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if e := q.list.Front(); e != nil {
+		return e.Value
+	}
+	return nil
+}
+
+func (q *UniqueQueue) Element() interface{} {
+	if x := q.Peek(); x != nil {
+		return x
+	}
+	panic(NoSuchElementError)
+}
+
+func (q *UniqueQueue) RemainingCapacity() int {
+	return q.capacity - q.Len()
+}
+
+func (q *UniqueQueue) Len() int {
+	return int(atomic.LoadInt64(&q.count))
+}
+
+func (q *UniqueQueue) IsEmpty() bool {
+	return q.Len() == 0
+}
+
+// takes constant time.
+func (q *UniqueQueue) Contains(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	_, ok := q.index[i]
+	return ok
+}
+
+/**
+ * @Description: iterate through the queue from head to tail.
+ *               the queue is locked during the iteration, f must not call back into the queue.
+ * @receiver q
+ * @param f return false to stop the iteration
+ */
+func (q *UniqueQueue) Range(f func(value interface{}) bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for e := q.list.Front(); e != nil; e = e.Next() {
+		if !f(e.Value) {
+			return
+		}
+	}
+}
+
+func (q *UniqueQueue) ToSlice() []interface{} {
+	ret := make([]interface{}, 0, q.Len())
+	q.Range(func(value interface{}) bool {
+		ret = append(ret, value)
+		return true
+	})
+	return ret
+}
+
+func (q *UniqueQueue) String() string {
+	return fmt.Sprintf("%v", q.ToSlice())
+}
+
+// takes constant time.
+func (q *UniqueQueue) Remove(i interface{}) bool {
+	if i == nil {
+		return false
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	e, ok := q.index[i]
+	if !ok {
+		return false
+	}
+	q.unlink(e)
+	return true
+}
+
+func (q *UniqueQueue) ContainsAll(c Collection) bool {
+	containsAll := true
+	c.Range(func(value interface{}) bool {
+		if !q.Contains(value) {
+			containsAll = false
+			return false
+		}
+		return true
+	})
+	return containsAll
+}
+
+/**
+ * @Description: adds all non-nil elements of c that are not in the queue yet, until the queue is full.
+ *               nil element in c will be skipped. it'll return FullError/ NilPointerError to indicate error.
+ * @receiver q
+ * @param c if c is nil, NilPointerError is returned
+ * @return bool indicates whether the queue has been changed or not when the func return
+ */
+func (q *UniqueQueue) AddAll(c Collection) (modified bool, err error) {
+	if c == nil {
+		return false, NilPointerError
+	}
+	elements := c.ToSlice()
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for _, e := range elements {
+		if e == nil {
+			err = NilPointerError
+			continue
+		}
+		if _, ok := q.index[e]; ok {
+			continue
+		}
+		if q.Len() >= q.capacity {
+			return modified, FullError
+		}
+		q.enqueue(e)
+		modified = true
+	}
+	return
+}
+
+func (q *UniqueQueue) RemoveAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	elements := c.ToSlice()
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	removed := false
+	for _, x := range elements {
+		if e, ok := q.index[x]; ok {
+			q.unlink(e)
+			removed = true
+		}
+	}
+	return removed
+}
+
+func (q *UniqueQueue) RemoveIf(filter func(value interface{}) bool) bool {
+	if filter == nil {
+		panic(NilPointerError)
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	removed := false
+	for e := q.list.Front(); e != nil; {
+		next := e.Next()
+		if filter(e.Value) {
+			q.unlink(e)
+			removed = true
+		}
+		e = next
+	}
+	return removed
+}
+
+func (q *UniqueQueue) RetainAll(c Collection) bool {
+	if c == nil {
+		panic(NilPointerError)
+	}
+	elements := c.ToSlice()
+	return q.RemoveIf(func(value interface{}) bool {
+		return !sliceContains(elements, value)
+	})
+}
+
+/**
+ * Atomically removes all of the elements from this queue.
+ */
+func (q *UniqueQueue) Clear() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.list.Init()
+	q.index = make(map[interface{}]*list.Element)
+	atomic.StoreInt64(&q.count, 0)
+	q.notFull.Broadcast()
+}
@@ -0,0 +1,84 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+var _ BlockingQueue = (*UniqueQueue)(nil)
+
+func TestUniqueQueue_Duplicates(t *testing.T) {
+	q := NewUniqueQueue(3)
+	for _, id := range []string{"a", "b", "a", "c", "b"} {
+		q.Offer(id)
+	}
+	if q.String() != "[a b c]" {
+		t.Fatalf("want [a b c], got %v", q)
+	}
+	// a duplicate is reported at once, even though the queue is full.
+	if err := q.Put("a"); err != DuplicateElementError {
+		t.Fatalf("want DuplicateElementError, got %v", err)
+	}
+	if q.Add("b") || q.OfferTimout("c", time.Hour) {
+		t.Fatal("Add and OfferTimout of a duplicate should return false")
+	}
+	if !q.Contains("b") || !q.Remove("b") || q.Contains("b") {
+		t.Fatal("Contains / Remove")
+	}
+	if q.Take() != "a" || !q.Offer("a") || q.String() != "[c a]" {
+		t.Fatalf("a taken element may be inserted again, got %v", q)
+	}
+}
+
+func TestUniqueQueue_PutWaitsForRoom(t *testing.T) {
+	q := NewUniqueQueue(1)
+	q.Put(1)
+	done := make(chan error)
+	go func() { done <- q.Put(2) }()
+	time.Sleep(10 * time.Millisecond)
+	if q.Take() != 1 {
+		t.Fatal("Take")
+	}
+	if err := <-done; err != nil || q.Peek() != 2 {
+		t.Fatalf("Put: %v, queue %v", err, q)
+	}
+	if q.OfferTimout(3, 10*time.Millisecond) {
+		t.Fatal("OfferTimout should time out")
+	}
+}
+
+func TestUniqueQueue_TryOffer(t *testing.T) {
+	q := NewUniqueQueue(2)
+	if ok, err := q.TryOffer("a"); !ok || err != nil {
+		t.Fatalf("want true, nil, got %v, %v", ok, err)
+	}
+	if q.Offer("a") {
+		t.Fatal("Offer of a duplicate should return false")
+	}
+	if ok, err := q.TryOffer("a"); ok || err != DuplicateElementError {
+		t.Fatalf("duplicate: want false, DuplicateElementError, got %v, %v", ok, err)
+	}
+	q.Offer("b")
+	if q.Offer("c") {
+		t.Fatal("Offer on a full queue should return false")
+	}
+	if ok, err := q.TryOffer("c"); ok || err != nil {
+		t.Fatalf("full: want false, nil, got %v, %v", ok, err)
+	}
+	// a duplicate is told apart even when the queue is full.
+	if ok, err := q.TryOffer("b"); ok || err != DuplicateElementError {
+		t.Fatalf("duplicate in a full queue: want false, DuplicateElementError, got %v, %v", ok, err)
+	}
+	if ok, err := q.TryOfferTimeout("c", 10*time.Millisecond); ok || err != nil {
+		t.Fatalf("full after timeout: want false, nil, got %v, %v", ok, err)
+	}
+	if ok, err := q.TryOfferTimeout("a", time.Hour); ok || err != DuplicateElementError {
+		t.Fatalf("timed duplicate: want false, DuplicateElementError, got %v, %v", ok, err)
+	}
+	q.Poll()
+	if ok, err := q.TryOfferTimeout("c", time.Hour); !ok || err != nil || q.String() != "[b c]" {
+		t.Fatalf("want true, nil and [b c], got %v, %v and %v", ok, err, q)
+	}
+}
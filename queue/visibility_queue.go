@@ -0,0 +1,206 @@
+package queue
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+// ReceiptHandle identifies one delivery of an element by a VisibilityQueue.
+type ReceiptHandle uint64
+
+/**
+ * A VisibilityQueue gives at-least-once delivery in the manner of Amazon SQS.
+ *
+ * <p>Receive hands out the head along with a ReceiptHandle, and hides it for
+ * the visibility timeout. Ack deletes it for good. Nack, or the end of the
+ * visibility timeout, makes it visible again, at the tail. once an element has
+ * been delivered maxReceives times without being acked, it's moved to the
+ * dead-letter queue instead of being made visible again, unless the
+ * dead-letter queue refuses it.
+ *
+ * <p>Every delivery has its own receipt handle: once the element is visible
+ * again, the handles of its previous deliveries are invalid.
+ */
+type VisibilityQueue struct {
+	// the visible elements, as *visibilityEntry
+	visible *LinkedBlockingQueue
+
+	visibilityTimeout time.Duration
+	maxReceives       int
+	deadLetter        BlockingQueue
+
+	lock *sync.Mutex
+	// the deliveries not acked yet
+	inFlight    map[ReceiptHandle]*visibilityDelivery
+	lastReceipt uint64
+}
+
+type visibilityEntry struct {
+	body     interface{}
+	receives int
+}
+
+type visibilityDelivery struct {
+	entry *visibilityEntry
+	timer *time.Timer
+}
+
+/**
+ * @Description: create a VisibilityQueue.
+ * @param visibilityTimeout how long a received element stays hidden, if it's not positive,
+ *        IllegalArgumentError will be panic
+ * @param maxReceives the number of deliveries after which an element is dead-lettered, 0 means no limit
+ * @param deadLetter the dead-letter queue, if it's nil, the dead-lettered elements are dropped
+ * @return *VisibilityQueue
+ */
+func NewVisibilityQueue(visibilityTimeout time.Duration, maxReceives int, deadLetter BlockingQueue) *VisibilityQueue {
+	if visibilityTimeout <= 0 || maxReceives < 0 {
+		panic(IllegalArgumentError)
+	}
+	return &VisibilityQueue{
+		visible:           NewLinkedBlockingQueue(0),
+		visibilityTimeout: visibilityTimeout,
+		maxReceives:       maxReceives,
+		deadLetter:        deadLetter,
+		lock:              new(sync.Mutex),
+		inFlight:          make(map[ReceiptHandle]*visibilityDelivery),
+	}
+}
+
+// inserts an element at the tail, visible at once.
+func (q *VisibilityQueue) Send(body interface{}) error {
+	if body == nil {
+		return NilPointerError
+	}
+	return q.visible.Put(&visibilityEntry{body: body})
+}
+
+// hides entry for the visibility timeout, and returns the handle of the delivery.
+func (q *VisibilityQueue) deliver(entry *visibilityEntry) (interface{}, ReceiptHandle) {
+	receipt := ReceiptHandle(atomic.AddUint64(&q.lastReceipt, 1))
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	entry.receives++
+	q.inFlight[receipt] = &visibilityDelivery{
+		entry: entry,
+		timer: time.AfterFunc(q.visibilityTimeout, func() {
+			// the delivery may have been acked or nacked meanwhile.
+			if d := q.take(receipt); d != nil {
+				q.makeVisible(d.entry)
+			}
+		}),
+	}
+	return entry.body, receipt
+}
+
+// removes a delivery from the in-flight ones and stops its timer, returns nil if it's not in flight.
+func (q *VisibilityQueue) take(receipt ReceiptHandle) *visibilityDelivery {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	d, ok := q.inFlight[receipt]
+	if !ok {
+		return nil
+	}
+	delete(q.inFlight, receipt)
+	d.timer.Stop()
+	return d
+}
+
+// puts an element back at the tail, or into the dead-letter queue once it has been received too many times.
+// the dead-letter queue is offered to without waiting, so that neither the timer nor Nack blocks on it,
+// if it refuses the element, because it's full or closed, the element stays visible at the tail.
+func (q *VisibilityQueue) makeVisible(entry *visibilityEntry) {
+	if q.maxReceives > 0 && entry.receives >= q.maxReceives {
+		if q.deadLetter == nil || q.deadLetter.Offer(entry.body) {
+			return
+		}
+	}
+	q.visible.Put(entry)
+}
+
+/**
+ * @Description: receive the head, waiting if necessary until an element becomes visible.
+ * @return interface{} the element
+ * @return ReceiptHandle the handle to Ack or Nack the delivery with
+ */
+func (q *VisibilityQueue) Receive() (interface{}, ReceiptHandle) {
+	return q.deliver(q.visible.Take().(*visibilityEntry))
+}
+
+// like Receive, but returns a nil element at once if nothing is visible.
+func (q *VisibilityQueue) TryReceive() (interface{}, ReceiptHandle) {
+	x := q.visible.Poll()
+	if x == nil {
+		return nil, 0
+	}
+	return q.deliver(x.(*visibilityEntry))
+}
+
+// like Receive, but returns a nil element if nothing is visible after timeout.
+func (q *VisibilityQueue) ReceiveTimeout(timeout time.Duration) (interface{}, ReceiptHandle) {
+	x := q.visible.PollTimeout(timeout)
+	if x == nil {
+		return nil, 0
+	}
+	return q.deliver(x.(*visibilityEntry))
+}
+
+/**
+ * @Description: acknowledge a delivery, the element is deleted for good.
+ * @return error InvalidReceiptError if the delivery is not in flight: it has been acked or nacked
+ *         already, or its visibility timeout is over.
+ */
+func (q *VisibilityQueue) Ack(receipt ReceiptHandle) error {
+	if q.take(receipt) == nil {
+		return InvalidReceiptError
+	}
+	return nil
+}
+
+/**
+ * @Description: give a delivery up, the element is visible again at once, or dead-lettered.
+ * @return error InvalidReceiptError if the delivery is not in flight
+ */
+func (q *VisibilityQueue) Nack(receipt ReceiptHandle) error {
+	d := q.take(receipt)
+	if d == nil {
+		return InvalidReceiptError
+	}
+	q.makeVisible(d.entry)
+	return nil
+}
+
+/**
+ * @Description: hide an element for timeout from now on, instead of what's left of its visibility timeout.
+ * @return error InvalidReceiptError if the delivery is not in flight
+ */
+func (q *VisibilityQueue) ChangeVisibility(receipt ReceiptHandle, timeout time.Duration) error {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	d, ok := q.inFlight[receipt]
+	if !ok || !d.timer.Stop() {
+		// a timer that can't be stopped is firing, the delivery is about to expire.
+		return InvalidReceiptError
+	}
+	d.timer.Reset(timeout)
+	return nil
+}
+
+// the number of visible elements.
+func (q *VisibilityQueue) Len() int {
+	return q.visible.Len()
+}
+
+// the number of elements received and neither acked nor visible again yet.
+func (q *VisibilityQueue) InFlight() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.inFlight)
+}
+
+func (q *VisibilityQueue) IsEmpty() bool {
+	return q.Len() == 0 && q.InFlight() == 0
+}
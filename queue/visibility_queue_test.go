@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+func TestVisibilityQueue_AckNack(t *testing.T) {
+	q := NewVisibilityQueue(time.Hour, 0, nil)
+	q.Send("a")
+	q.Send("b")
+	body, receipt := q.Receive()
+	if body != "a" || q.Len() != 1 || q.InFlight() != 1 {
+		t.Fatalf("want a in flight, got %v", body)
+	}
+	if err := q.Nack(receipt); err != nil {
+		t.Fatal(err)
+	}
+	// a is visible again, behind b.
+	if body, receipt = q.TryReceive(); body != "b" {
+		t.Fatalf("want b, got %v", body)
+	}
+	if err := q.Ack(receipt); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Ack(receipt); err != InvalidReceiptError {
+		t.Fatalf("a second Ack should return InvalidReceiptError, got %v", err)
+	}
+	body, receipt = q.ReceiveTimeout(time.Second)
+	if body != "a" || q.Ack(receipt) != nil || !q.IsEmpty() {
+		t.Fatal("a should be deleted")
+	}
+	if body, _ = q.ReceiveTimeout(10 * time.Millisecond); body != nil {
+		t.Fatalf("want nothing, got %v", body)
+	}
+}
+
+func TestVisibilityQueue_TimeoutAndDeadLetter(t *testing.T) {
+	deadLetter := NewLinkedBlockingQueue(0)
+	q := NewVisibilityQueue(20*time.Millisecond, 2, deadLetter)
+	q.Send("poison")
+
+	_, first := q.Receive()
+	if q.Len() != 0 {
+		t.Fatal("a received element should be hidden")
+	}
+	// the visibility timeout makes it visible again.
+	body, second := q.ReceiveTimeout(time.Second)
+	if body != "poison" {
+		t.Fatalf("want the element back after the timeout, got %v", body)
+	}
+	if q.Ack(first) != InvalidReceiptError {
+		t.Fatal("the receipt of an expired delivery should be invalid")
+	}
+	if err := q.ChangeVisibility(second, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if q.InFlight() != 1 {
+		t.Fatal("ChangeVisibility should extend the visibility timeout")
+	}
+	// the second delivery fails too, that's the last one.
+	q.Nack(second)
+	if q.Len() != 0 || q.InFlight() != 0 {
+		t.Fatal("the element should not be visible anymore")
+	}
+	if x := deadLetter.Poll(); x != "poison" {
+		t.Fatalf("want the element in the dead-letter queue, got %v", x)
+	}
+}
+
+func TestVisibilityQueue_FullDeadLetter(t *testing.T) {
+	deadLetter := NewLinkedBlockingQueue(1)
+	deadLetter.Put("occupant")
+	q := NewVisibilityQueue(time.Hour, 1, deadLetter)
+	q.Send("poison")
+
+	_, receipt := q.Receive()
+	done := make(chan error, 1)
+	go func() { done <- q.Nack(receipt) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Nack blocked on the full dead-letter queue")
+	}
+	// the element is kept visible rather than lost.
+	if q.Len() != 1 || deadLetter.Len() != 1 {
+		t.Fatalf("want the element still visible, got %d visible, %d dead-lettered", q.Len(), deadLetter.Len())
+	}
+	// once there is room, the next give-up dead-letters it.
+	deadLetter.Poll()
+	body, receipt := q.Receive()
+	if body != "poison" || q.Nack(receipt) != nil || deadLetter.Peek() != "poison" || !q.IsEmpty() {
+		t.Fatalf("want poison dead-lettered, got %v", deadLetter)
+	}
+}
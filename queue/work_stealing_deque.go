@@ -0,0 +1,151 @@
+package queue
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	. "github.com/torchcc/data-structure/error"
+)
+
+// the initial capacity of a WorkStealingDeque is 1 << wsdInitialLogSize.
+const wsdInitialLogSize = 5
+
+/**
+ * A work-stealing deque after Chase & Lev, "Dynamic Circular Work-Stealing Deque",
+ * with the memory orderings of Lê et al., "Correct and Efficient Work-Stealing
+ * for Weak Memory Models" (Go atomics are sequentially consistent).
+ *
+ * <p>The deque has a single owner, the only goroutine allowed to call PushBottom
+ * and PopBottom, it uses the bottom end as a LIFO stack. any goroutine may call
+ * Steal to take an element from the top end, so thieves see the elements in FIFO
+ * order. the owner never takes a lock or competes with thieves, except for the
+ * last element.
+ *
+ * <p>The elements live in a circular array that doubles when it's full. old
+ * arrays are left to thieves still reading them and to the garbage collector.
+ */
+type WorkStealingDeque struct {
+	// the index of the next element to steal, only thieves and the owner taking the last element move it.
+	top int64
+	// the index of the next element to push, only the owner moves it.
+	bottom int64
+	// *wsdArray
+	array unsafe.Pointer
+}
+
+type wsdArray struct {
+	logSize uint
+	// *interface{}
+	buf []unsafe.Pointer
+}
+
+func newWsdArray(logSize uint) *wsdArray {
+	return &wsdArray{logSize: logSize, buf: make([]unsafe.Pointer, 1<<logSize)}
+}
+
+func (a *wsdArray) size() int64 {
+	return 1 << a.logSize
+}
+
+func (a *wsdArray) get(i int64) unsafe.Pointer {
+	return atomic.LoadPointer(&a.buf[i&(a.size()-1)])
+}
+
+func (a *wsdArray) put(i int64, p unsafe.Pointer) {
+	atomic.StorePointer(&a.buf[i&(a.size()-1)], p)
+}
+
+// returns a twice larger array holding the elements from top to bottom.
+func (a *wsdArray) grow(bottom, top int64) *wsdArray {
+	n := newWsdArray(a.logSize + 1)
+	for i := top; i < bottom; i++ {
+		n.put(i, a.get(i))
+	}
+	return n
+}
+
+func (d *WorkStealingDeque) loadArray() *wsdArray {
+	return (*wsdArray)(atomic.LoadPointer(&d.array))
+}
+
+/**
+ * @Description: push an element at the bottom, only the owner may call it.
+ * @param i if i is nil, NilPointerError will be panic
+ */
+func (d *WorkStealingDeque) PushBottom(i interface{}) {
+	if i == nil {
+		panic(NilPointerError)
+	}
+	b := atomic.LoadInt64(&d.bottom)
+	t := atomic.LoadInt64(&d.top)
+	a := d.loadArray()
+	if b-t > a.size()-1 {
+		a = a.grow(b, t)
+		atomic.StorePointer(&d.array, unsafe.Pointer(a))
+	}
+	a.put(b, unsafe.Pointer(&i))
+	atomic.StoreInt64(&d.bottom, b+1)
+}
+
+/**
+ * @Description: take the most recently pushed element, only the owner may call it.
+ * @return interface{} nil if the deque is empty, or a thief took the last element
+ */
+func (d *WorkStealingDeque) PopBottom() interface{} {
+	b := atomic.LoadInt64(&d.bottom) - 1
+	a := d.loadArray()
+	// publishing the decremented bottom first keeps thieves off the element, unless it's the last one.
+	atomic.StoreInt64(&d.bottom, b)
+	t := atomic.LoadInt64(&d.top)
+	if t > b {
+		// empty
+		atomic.StoreInt64(&d.bottom, b+1)
+		return nil
+	}
+	x := *(*interface{})(a.get(b))
+	if t == b {
+		// the last element, race the thieves for it.
+		if !atomic.CompareAndSwapInt64(&d.top, t, t+1) {
+			x = nil
+		}
+		atomic.StoreInt64(&d.bottom, b+1)
+	}
+	return x
+}
+
+/**
+ * @Description: take the least recently pushed element, any goroutine may call it.
+ *               if another thief or the owner wins the race for the top element, it tries again.
+ * @return interface{} nil if the deque is empty
+ */
+func (d *WorkStealingDeque) Steal() interface{} {
+	for {
+		t := atomic.LoadInt64(&d.top)
+		b := atomic.LoadInt64(&d.bottom)
+		if t >= b {
+			return nil
+		}
+		a := d.loadArray()
+		p := a.get(t)
+		if atomic.CompareAndSwapInt64(&d.top, t, t+1) {
+			return *(*interface{})(p)
+		}
+	}
+}
+
+// the number of elements, it may be stale by the time it returns.
+func (d *WorkStealingDeque) Len() int {
+	n := atomic.LoadInt64(&d.bottom) - atomic.LoadInt64(&d.top)
+	if n < 0 {
+		return 0
+	}
+	return int(n)
+}
+
+func (d *WorkStealingDeque) IsEmpty() bool {
+	return d.Len() == 0
+}
+
+func NewWorkStealingDeque() *WorkStealingDeque {
+	return &WorkStealingDeque{array: unsafe.Pointer(newWsdArray(wsdInitialLogSize))}
+}
@@ -0,0 +1,91 @@
+package queue
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// the owner alone, checked against a slice used as the model.
+func TestWorkStealingDeque_Model(t *testing.T) {
+	d := NewWorkStealingDeque()
+	var model []int
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		switch r.Intn(3) {
+		case 0, 1:
+			d.PushBottom(i)
+			model = append(model, i)
+		case 2:
+			var want interface{}
+			if len(model) > 0 {
+				want = model[len(model)-1]
+				model = model[:len(model)-1]
+			}
+			if x := d.PopBottom(); x != want {
+				t.Fatalf("PopBottom: want %v, got %v", want, x)
+			}
+		}
+		if r.Intn(5) == 0 {
+			var want interface{}
+			if len(model) > 0 {
+				want = model[0]
+				model = model[1:]
+			}
+			if x := d.Steal(); x != want {
+				t.Fatalf("Steal: want %v, got %v", want, x)
+			}
+		}
+		if d.Len() != len(model) {
+			t.Fatalf("Len: want %d, got %d", len(model), d.Len())
+		}
+	}
+}
+
+// the owner pushes increasing numbers and pops some of them back while thieves steal:
+// every number must come out exactly once, and every thief must see increasing numbers.
+func TestWorkStealingDeque_Concurrent(t *testing.T) {
+	const n, thieves = 20000, 3
+	d := NewWorkStealingDeque()
+	var taken [n]int32
+	var pushed int32
+	var wg sync.WaitGroup
+	for th := 0; th < thieves; th++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := -1
+			for atomic.LoadInt32(&pushed) == 0 || !d.IsEmpty() {
+				x := d.Steal()
+				if x == nil {
+					continue
+				}
+				if x.(int) <= last {
+					t.Errorf("thief saw %v after %d", x, last)
+				}
+				last = x.(int)
+				atomic.AddInt32(&taken[last], 1)
+			}
+		}()
+	}
+	r := rand.New(rand.NewSource(2))
+	for i := 0; i < n; i++ {
+		d.PushBottom(i)
+		if r.Intn(3) == 0 {
+			if x := d.PopBottom(); x != nil {
+				atomic.AddInt32(&taken[x.(int)], 1)
+			}
+		}
+	}
+	atomic.StoreInt32(&pushed, 1)
+	for x := d.PopBottom(); x != nil; x = d.PopBottom() {
+		atomic.AddInt32(&taken[x.(int)], 1)
+	}
+	wg.Wait()
+	for i := range taken {
+		if taken[i] != 1 {
+			t.Fatalf("%d taken %d times", i, taken[i])
+		}
+	}
+}